@@ -1,13 +1,30 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/HalilFocic/gitgo/internal/commands"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
 	"github.com/HalilFocic/gitgo/internal/repository"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
+// stringList collects the values of a flag that may be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
 	if len(os.Args) < 2 {
 		fmt.Println("usage: gitgo <command> [<args>]")
@@ -23,8 +40,20 @@ func main() {
 	switch os.Args[1] {
 	case "init":
 		initCmd := flag.NewFlagSet("init", flag.ExitOnError)
+		objectFormat := initCmd.String("object-format", "sha1", "hash algorithm for object IDs (sha1 or sha256)")
+		encrypt := initCmd.Bool("encrypt", false, "encrypt objects with AES-256-GCM")
+		keyFile := initCmd.String("key-file", "", "file holding the encryption key, created if missing")
 		initCmd.Parse(os.Args[2:])
-		_, err := repository.Init(cwd)
+		format, err := objectformat.Parse(*objectFormat)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+		if *encrypt || *keyFile != "" {
+			_, err = repository.InitEncrypted(cwd, format, *keyFile)
+		} else {
+			_, err = repository.InitWithObjectFormat(cwd, format)
+		}
 		if err != nil {
 			fmt.Printf("error: %v\n", err)
 			os.Exit(1)
@@ -65,12 +94,13 @@ func main() {
 	case "commit":
 		commitCmd := flag.NewFlagSet("commit", flag.ExitOnError)
 		message := commitCmd.String("m", "", "commit message")
+		sign := commitCmd.Bool("S", false, "sign the commit with the SSH key in user.signingKey")
 		commitCmd.Parse(os.Args[2:])
 		if *message == "" {
 			fmt.Println("error: -m flag required")
 			os.Exit(1)
 		}
-		cmd := commands.NewCommitCommand(cwd, *message, "User <user@example.com>")
+		cmd := commands.NewCommitCommand(cwd, *message, "", *sign)
 		if err := cmd.Execute(); err != nil {
 			fmt.Printf("error: %v\n", err)
 			os.Exit(1)
@@ -103,11 +133,42 @@ func main() {
 			}
 		}
 
+	case "tag":
+		tagCmd := flag.NewFlagSet("tag", flag.ExitOnError)
+		annotate := tagCmd.Bool("a", false, "create an annotated tag")
+		message := tagCmd.String("m", "", "tag message, implies -a")
+		delete := tagCmd.Bool("d", false, "delete tag")
+		show := tagCmd.Bool("show", false, "show tag and the object it points at")
+		tagCmd.Parse(os.Args[2:])
+
+		var cmd *commands.TagCommand
+		switch {
+		case *delete && tagCmd.NArg() == 1:
+			cmd = commands.NewTagCommand(cwd, tagCmd.Arg(0), "", "", "", "delete")
+		case *show && tagCmd.NArg() == 1:
+			cmd = commands.NewTagCommand(cwd, tagCmd.Arg(0), "", "", "", "show")
+		case tagCmd.NArg() == 0:
+			cmd = commands.NewTagCommand(cwd, "", "", "", "", "list")
+		case tagCmd.NArg() <= 2:
+			if *annotate && *message == "" {
+				fmt.Println("error: -m flag required for annotated tags")
+				os.Exit(1)
+			}
+			cmd = commands.NewTagCommand(cwd, tagCmd.Arg(0), tagCmd.Arg(1), *message, "", "create")
+		default:
+			fmt.Println("error: usage: gitgo tag [-a -m <message>] <name> [<target>] | -d <name> | -show <name>")
+			os.Exit(1)
+		}
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+
 	case "checkout":
 		checkoutCmd := flag.NewFlagSet("checkout", flag.ExitOnError)
 		checkoutCmd.Parse(os.Args[2:])
 		if checkoutCmd.NArg() != 1 {
-			fmt.Println("error: branch name or commit hash required")
+			fmt.Println("error: branch, tag or commit required")
 			os.Exit(1)
 		}
 		cmd := commands.NewCheckoutCommand(cwd, checkoutCmd.Arg(0))
@@ -118,9 +179,249 @@ func main() {
 	case "log":
 		logCmd := flag.NewFlagSet("log", flag.ExitOnError)
 		maxCount := logCmd.Int("n", -1, "limit number of commits")
+		abbrev := logCmd.Bool("abbrev", false, "abbreviate commit IDs to 7 characters, or more where ambiguous")
+		showSignature := logCmd.Bool("show-signature", false, "verify the SSH signatures of signed commits")
 		logCmd.Parse(os.Args[2:])
+		if logCmd.NArg() > 1 {
+			fmt.Println("error: usage: gitgo log [-n <count>] [-abbrev] [-show-signature] [<revision>]")
+			os.Exit(1)
+		}
 
-		cmd := commands.NewLogCommand(cwd, *maxCount)
+		cmd := commands.NewLogCommand(cwd, *maxCount, logCmd.Arg(0), *abbrev, *showSignature)
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "verify-commit":
+		verifyCmd := flag.NewFlagSet("verify-commit", flag.ExitOnError)
+		verifyCmd.Parse(os.Args[2:])
+		if verifyCmd.NArg() != 1 {
+			fmt.Println("error: usage: gitgo verify-commit <commit>")
+			os.Exit(1)
+		}
+		cmd := commands.NewVerifyCommitCommand(cwd, verifyCmd.Arg(0))
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "cat-file":
+		catFileCmd := flag.NewFlagSet("cat-file", flag.ExitOnError)
+		showType := catFileCmd.Bool("t", false, "show object type")
+		showSize := catFileCmd.Bool("s", false, "show object size")
+		pretty := catFileCmd.Bool("p", false, "pretty-print object content")
+		catFileCmd.Parse(os.Args[2:])
+		if catFileCmd.NArg() != 1 {
+			fmt.Println("error: object name required")
+			os.Exit(1)
+		}
+
+		mode := ""
+		switch {
+		case *showType:
+			mode = "type"
+		case *showSize:
+			mode = "size"
+		case *pretty:
+			mode = "pretty"
+		default:
+			fmt.Println("error: one of -t, -s or -p required")
+			os.Exit(1)
+		}
+		cmd := commands.NewCatFileCommand(cwd, catFileCmd.Arg(0), mode)
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "fsck":
+		fsckCmd := flag.NewFlagSet("fsck", flag.ExitOnError)
+		asJSON := fsckCmd.Bool("json", false, "print the report as JSON")
+		fsckCmd.Parse(os.Args[2:])
+		cmd := commands.NewFsckCommand(cwd, *asJSON)
+		if err := cmd.Execute(); err != nil {
+			// The JSON report already describes the failure.
+			if !*asJSON || !errors.Is(err, commands.ErrFsckFailed) {
+				fmt.Printf("error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	case "migrate-objects":
+		migrateCmd := flag.NewFlagSet("migrate-objects", flag.ExitOnError)
+		migrateCmd.Parse(os.Args[2:])
+		cmd := commands.NewMigrateObjectsCommand(cwd)
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "gc":
+		gcCmd := flag.NewFlagSet("gc", flag.ExitOnError)
+		pruneExpire := gcCmd.String("prune", "", "prune unreachable loose objects older than this (default gc.pruneExpire or 2.weeks.ago)")
+		gcCmd.Parse(os.Args[2:])
+		cmd := commands.NewGCCommand(cwd, *pruneExpire)
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "prune":
+		pruneCmd := flag.NewFlagSet("prune", flag.ExitOnError)
+		expire := pruneCmd.String("expire", "", "only prune objects older than this (default gc.pruneExpire or 2.weeks.ago)")
+		dryRun := pruneCmd.Bool("dry-run", false, "list the objects that would be pruned")
+		pruneCmd.Parse(os.Args[2:])
+		cmd := commands.NewPruneCommand(cwd, *expire, *dryRun)
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "repack":
+		repackCmd := flag.NewFlagSet("repack", flag.ExitOnError)
+		all := repackCmd.Bool("a", false, "also copy objects borrowed from alternates into the pack")
+		repackCmd.Parse(os.Args[2:])
+		cmd := commands.NewRepackCommand(cwd, *all)
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "clone":
+		cloneCmd := flag.NewFlagSet("clone", flag.ExitOnError)
+		shared := cloneCmd.Bool("shared", false, "borrow the source's objects through objects/info/alternates instead of copying them")
+		cloneCmd.Parse(os.Args[2:])
+		if cloneCmd.NArg() < 1 || cloneCmd.NArg() > 2 {
+			fmt.Println("error: usage: gitgo clone [--shared] <source> [<directory>]")
+			os.Exit(1)
+		}
+		source := cloneCmd.Arg(0)
+		target := filepath.Base(filepath.Clean(source))
+		if cloneCmd.NArg() == 2 {
+			target = cloneCmd.Arg(1)
+		}
+		cmd := commands.NewCloneCommand(source, target, *shared)
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "fast-export":
+		fastExportCmd := flag.NewFlagSet("fast-export", flag.ExitOnError)
+		all := fastExportCmd.Bool("all", false, "export every reference")
+		importMarks := fastExportCmd.String("import-marks", "", "read the marks of an earlier export and skip what it exported")
+		exportMarks := fastExportCmd.String("export-marks", "", "write the marks of every exported object to this file")
+		fastExportCmd.Parse(os.Args[2:])
+		if !*all && fastExportCmd.NArg() == 0 {
+			fmt.Println("error: usage: gitgo fast-export [--all] [--import-marks=<file>] [--export-marks=<file>] [<ref>...]")
+			os.Exit(1)
+		}
+		cmd := commands.NewFastExportCommand(cwd, fastExportCmd.Args(), *all, *importMarks, *exportMarks, os.Stdout)
+		if err := cmd.Execute(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "fast-import":
+		fastImportCmd := flag.NewFlagSet("fast-import", flag.ExitOnError)
+		importMarks := fastImportCmd.String("import-marks", "", "load marks from an earlier import")
+		exportMarks := fastImportCmd.String("export-marks", "", "write every mark to this file when done")
+		fastImportCmd.Parse(os.Args[2:])
+		cmd := commands.NewFastImportCommand(cwd, os.Stdin, *importMarks, *exportMarks)
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "bundle":
+		bundleCmd := flag.NewFlagSet("bundle", flag.ExitOnError)
+		bundleCmd.Parse(os.Args[2:])
+		args := bundleCmd.Args()
+		valid := len(args) == 2 && (args[0] == "verify" || args[0] == "unbundle")
+		if !valid && !(len(args) >= 3 && args[0] == "create") {
+			fmt.Println("error: usage: gitgo bundle create <file> <rev-range>... | verify <file> | unbundle <file>")
+			os.Exit(1)
+		}
+		cmd := commands.NewBundleCommand(cwd, args[0], args[1], args[2:])
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "commit-graph":
+		commitGraphCmd := flag.NewFlagSet("commit-graph", flag.ExitOnError)
+		commitGraphCmd.Parse(os.Args[2:])
+		if commitGraphCmd.NArg() != 1 || (commitGraphCmd.Arg(0) != "write" && commitGraphCmd.Arg(0) != "verify") {
+			fmt.Println("error: usage: gitgo commit-graph write|verify")
+			os.Exit(1)
+		}
+		cmd := commands.NewCommitGraphCommand(cwd, commitGraphCmd.Arg(0))
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "merge-base":
+		mergeBaseCmd := flag.NewFlagSet("merge-base", flag.ExitOnError)
+		isAncestor := mergeBaseCmd.Bool("is-ancestor", false, "exit with status 0 if the first commit is an ancestor of the second, 1 otherwise")
+		mergeBaseCmd.Parse(os.Args[2:])
+		if mergeBaseCmd.NArg() != 2 {
+			fmt.Println("error: usage: gitgo merge-base [--is-ancestor] <commit> <commit>")
+			os.Exit(1)
+		}
+		cmd := commands.NewMergeBaseCommand(cwd, mergeBaseCmd.Arg(0), mergeBaseCmd.Arg(1), *isAncestor)
+		if err := cmd.Execute(); err != nil {
+			if !errors.Is(err, commands.ErrNotAncestor) {
+				fmt.Printf("error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	case "hash-object":
+		hashObjectCmd := flag.NewFlagSet("hash-object", flag.ExitOnError)
+		write := hashObjectCmd.Bool("w", false, "write the object into the object store")
+		stdin := hashObjectCmd.Bool("stdin", false, "read the object from standard input")
+		hashObjectCmd.Parse(os.Args[2:])
+		if !*stdin && hashObjectCmd.NArg() == 0 {
+			fmt.Println("error: usage: gitgo hash-object [-w] [--stdin] [<file>...]")
+			os.Exit(1)
+		}
+		var input io.Reader
+		if *stdin {
+			input = os.Stdin
+		}
+		cmd := commands.NewHashObjectCommand(cwd, hashObjectCmd.Args(), input, *write)
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "write-tree":
+		writeTreeCmd := flag.NewFlagSet("write-tree", flag.ExitOnError)
+		writeTreeCmd.Parse(os.Args[2:])
+		cmd := commands.NewWriteTreeCommand(cwd)
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "commit-tree":
+		commitTreeCmd := flag.NewFlagSet("commit-tree", flag.ExitOnError)
+		var parents stringList
+		commitTreeCmd.Var(&parents, "p", "parent commit, may be repeated")
+		message := commitTreeCmd.String("m", "", "commit message, read from standard input when omitted")
+		if len(os.Args) < 3 {
+			fmt.Println("error: usage: gitgo commit-tree <tree> [-p <parent>]... [-m <message>]")
+			os.Exit(1)
+		}
+		// The tree comes first, as in git, so parse the flags after it.
+		commitTreeCmd.Parse(os.Args[3:])
+		if *message == "" {
+			content, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Printf("error: %v\n", err)
+				os.Exit(1)
+			}
+			*message = string(content)
+		}
+		cmd := commands.NewCommitTreeCommand(cwd, os.Args[2], parents, *message)
+		if err := cmd.Execute(); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(1)
+		}
+	case "update-ref":
+		updateRefCmd := flag.NewFlagSet("update-ref", flag.ExitOnError)
+		updateRefCmd.Parse(os.Args[2:])
+		if updateRefCmd.NArg() < 2 || updateRefCmd.NArg() > 3 {
+			fmt.Println("error: usage: gitgo update-ref <ref> <new> [<old>]")
+			os.Exit(1)
+		}
+		cmd := commands.NewUpdateRefCommand(cwd, updateRefCmd.Arg(0), updateRefCmd.Arg(1), updateRefCmd.Arg(2))
 		if err := cmd.Execute(); err != nil {
 			fmt.Printf("error: %v\n", err)
 			os.Exit(1)
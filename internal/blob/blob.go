@@ -1,14 +1,13 @@
 package blob
 
 import (
-	"bytes"
-	"compress/zlib"
-	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
-	"os"
-	"path/filepath"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
 )
 
 type Blob struct {
@@ -17,108 +16,108 @@ type Blob struct {
 }
 
 func (b *Blob) Hash() string {
-    return b.hash
+	return b.hash
 }
 
 func (b *Blob) Content() []byte {
-    return b.content
+	return b.content
 }
 
+// New hashes content with SHA-1. Use NewWithFormat for repositories that
+// use another object format.
 func New(content []byte) (*Blob, error) {
-	header := fmt.Sprintf("blob %d%c", len(content), 0)
-	combined := append([]byte(header), content...)
-	sumResult := sha1.Sum(combined)
-	hash := hex.EncodeToString(sumResult[:])
+	return NewWithFormat(content, objectformat.SHA1)
+}
+
+func NewWithFormat(content []byte, format objectformat.Format) (*Blob, error) {
 	b := Blob{
-		hash:    hash,
+		hash:    object.Hash(format, object.TypeBlob, content),
 		content: content,
 	}
 	return &b, nil
 }
 
-func (b *Blob) Store(objectsDir string) error {
-	directory := b.hash[:2]
-	fileName := b.hash[2:]
-	directoryPath := filepath.Join(objectsDir, directory)
-
-	err := os.MkdirAll(directoryPath, 0755) // 0755 is mode for directory
+func (b *Blob) Store(store object.ObjectStore) error {
+	hash, err := store.Put(object.TypeBlob, b.content)
 	if err != nil {
 		return err
 	}
-
-	filePath := filepath.Join(directoryPath, fileName)
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
+	if hash != b.hash {
+		return fmt.Errorf("blob %s was hashed with a different object format than the repository", b.hash)
 	}
-	defer file.Close()
-
-	writer := zlib.NewWriter(file)
-	defer writer.Close()
-
-	header := fmt.Sprintf("blob %d%c", len(b.content), 0)
-	combined := append([]byte(header), b.content...)
+	return nil
+}
 
-	_, err = writer.Write(combined)
-	return err
+// StoreFrom streams size bytes from r into a new blob object and returns
+// its hash. Unlike New and Store it never holds the whole content in
+// memory, which keeps adding large files cheap.
+func StoreFrom(store object.ObjectStore, r io.Reader, size int64) (string, error) {
+	return object.PutFrom(store, object.TypeBlob, r, size)
 }
 
-func Read(objectsDir, hash string) (*Blob, error) {
-	directory := hash[:2]
-	fileName := hash[2:]
-	fullFilePath := filepath.Join(objectsDir, directory, fileName)
+// HashFrom returns the ID size bytes from r would be stored under,
+// without storing them.
+func HashFrom(format objectformat.Format, r io.Reader, size int64) (string, error) {
+	return object.HashFrom(format, object.TypeBlob, r, size)
+}
 
-	file, err := os.OpenFile(fullFilePath, os.O_RDONLY, 0644)
+// Open returns a reader over the content of blob hash and its size. The
+// content is checked against hash once the reader reaches EOF.
+func Open(store object.ObjectStore, hash string) (io.ReadCloser, int64, error) {
+	objectType, size, reader, err := object.Open(store, hash)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	defer file.Close()
-
-	reader, err := zlib.NewReader(file)
-	if err != nil {
-		return nil, err
+	if objectType != object.TypeBlob {
+		reader.Close()
+		return nil, 0, fmt.Errorf("Invalid blob header: object %s is a %s", hash, objectType)
 	}
-	defer reader.Close()
-
-	content, err := io.ReadAll(reader)
+	format, err := objectformat.ForHash(hash)
 	if err != nil {
-		return nil, err
+		reader.Close()
+		return nil, 0, err
 	}
+	hasher := format.New()
+	fmt.Fprintf(hasher, "%s %d\x00", object.TypeBlob, size)
+	return &verifyingReader{ReadCloser: reader, hasher: hasher, hash: hash}, size, nil
+}
 
-	if !bytes.HasPrefix(content, []byte("blob ")) {
-		return nil, fmt.Errorf("Invalid blob header: doesn't start with 'blob'")
-	}
+type verifyingReader struct {
+	io.ReadCloser
+	hasher hash.Hash
+	hash   string
+}
 
-	nullIndex := bytes.IndexByte(content, 0)
-	if nullIndex == -1 {
-		return nil, fmt.Errorf("Invalid blob header: no null byte found")
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hasher.Write(p[:n])
+	if err == io.EOF {
+		if got := hex.EncodeToString(r.hasher.Sum(nil)); got != r.hash {
+			return n, fmt.Errorf("Hash mismatch, expected %s, got %s", r.hash, got)
+		}
 	}
+	return n, err
+}
 
-	header := string(content[:nullIndex])
-	var length int
-	_, err = fmt.Sscanf(header, "blob %d", &length)
+func Read(store object.ObjectStore, hash string) (*Blob, error) {
+	obj, err := store.Get(hash)
 	if err != nil {
 		return nil, err
 	}
-
-	actualContent := content[nullIndex+1:]
-	if len(actualContent) != length {
-		return nil, fmt.Errorf("Content length mismatch: expected %d, got %d", length, len(actualContent))
+	if obj.Type != object.TypeBlob {
+		return nil, fmt.Errorf("Invalid blob header: object %s is a %s", hash, obj.Type)
 	}
 
-	header = fmt.Sprintf("blob %d%c", len(actualContent), 0)
-	combined := append([]byte(header), actualContent...)
-	sumResult := sha1.Sum(combined)
-	hashResult := hex.EncodeToString(sumResult[:])
-
-	if hashResult != hash {
-		return nil, fmt.Errorf("Hash mismatch, expected %s, got %s", hash, hashResult)
+	format, err := objectformat.ForHash(hash)
+	if err != nil {
+		return nil, err
 	}
-
-	return &Blob{
-		hash:    hash,
-		content: actualContent,
-	}, nil
+	b, err := NewWithFormat(obj.Data, format)
+	if err != nil {
+		return nil, err
+	}
+	if b.hash != hash {
+		return nil, fmt.Errorf("Hash mismatch, expected %s, got %s", hash, b.hash)
+	}
+	return b, nil
 }
-
-
@@ -2,7 +2,10 @@ package blob
 
 import (
 	"bytes"
+	"io"
 	"github.com/HalilFocic/gitgo/internal/config"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
 	"os"
 	"path/filepath"
 	"testing"
@@ -10,6 +13,7 @@ import (
 
 func TestBlobOperations(t *testing.T) {
 	objectsPath := filepath.Join(config.GitDirName, "objects")
+	objects := object.NewFileStore(objectsPath, objectformat.SHA1)
 	t.Run("1.2: Create and store blob", func(t *testing.T) {
 
 		content := []byte("test content")
@@ -26,7 +30,7 @@ func TestBlobOperations(t *testing.T) {
 		}
 
 		// Store the blob
-		err = b.Store(objectsPath)
+		err = b.Store(objects)
 		if err != nil {
 			t.Fatalf("Failed to store blob: %v", err)
 		}
@@ -43,10 +47,10 @@ func TestBlobOperations(t *testing.T) {
 	t.Run("1.3: Read blob content", func(t *testing.T) {
 		content := []byte("test content")
 		originalBlob, _ := New(content)
-		originalBlob.Store(objectsPath)
+		originalBlob.Store(objects)
 		defer os.RemoveAll(config.GitDirName)
 		// Read blob back
-		readBlob, err := Read(objectsPath, originalBlob.Hash())
+		readBlob, err := Read(objects, originalBlob.Hash())
 		if err != nil {
 			t.Fatalf("Failed to read blob: %v", err)
 		}
@@ -55,6 +59,50 @@ func TestBlobOperations(t *testing.T) {
 			t.Error("Retrieved content doesn't match original")
 		}
 	})
+
+	t.Run("1.4: Stream blob in and out", func(t *testing.T) {
+		defer os.RemoveAll(config.GitDirName)
+		content := bytes.Repeat([]byte("streamed content\n"), 4096)
+
+		hash, err := StoreFrom(objects, bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+		b, _ := New(content)
+		if hash != b.Hash() {
+			t.Errorf("Streamed hash %s doesn't match %s", hash, b.Hash())
+		}
+
+		reader, size, err := Open(objects, hash)
+		if err != nil {
+			t.Fatalf("Failed to open blob: %v", err)
+		}
+		defer reader.Close()
+		if size != int64(len(content)) {
+			t.Errorf("Size mismatch: got %d, want %d", size, len(content))
+		}
+		got, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("Failed to read blob: %v", err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Error("Streamed content doesn't match original")
+		}
+
+		entries, _ := os.ReadDir(objectsPath)
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				t.Errorf("Temporary file %s left behind", entry.Name())
+			}
+		}
+	})
+
+	t.Run("1.5: Short reader", func(t *testing.T) {
+		defer os.RemoveAll(config.GitDirName)
+		if _, err := StoreFrom(objects, bytes.NewReader([]byte("short")), 100); err == nil {
+			t.Error("Expected error when reader ends before size bytes")
+		}
+	})
 }
 
 
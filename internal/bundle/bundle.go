@@ -0,0 +1,124 @@
+package bundle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+const (
+	signatureV2 = "# v2 git bundle"
+	signatureV3 = "# v3 git bundle"
+	formatCap   = "object-format="
+)
+
+// Prerequisite is a commit the receiving repository must already have
+// before the bundle's pack can be used. Comment is usually its subject.
+type Prerequisite struct {
+	Hash    string
+	Comment string
+}
+
+// Ref is a reference recorded in the bundle and the object it points at.
+type Ref struct {
+	Hash string
+	Name string
+}
+
+// Header is everything in a bundle file before the pack.
+type Header struct {
+	Format        objectformat.Format
+	Prerequisites []Prerequisite
+	Refs          []Ref
+}
+
+// Write writes h followed by the blank line that ends it. SHA-1 bundles
+// use version 2, which stock git reads everywhere; other object formats
+// need version 3 to name the format.
+func (h *Header) Write(w io.Writer) error {
+	var out strings.Builder
+	if h.Format.Name == objectformat.SHA1.Name {
+		out.WriteString(signatureV2 + "\n")
+	} else {
+		fmt.Fprintf(&out, "%s\n@%s%s\n", signatureV3, formatCap, h.Format.Name)
+	}
+	for _, p := range h.Prerequisites {
+		out.WriteString("-" + p.Hash)
+		if p.Comment != "" {
+			out.WriteString(" " + p.Comment)
+		}
+		out.WriteString("\n")
+	}
+	for _, ref := range h.Refs {
+		fmt.Fprintf(&out, "%s %s\n", ref.Hash, ref.Name)
+	}
+	out.WriteString("\n")
+	if _, err := io.WriteString(w, out.String()); err != nil {
+		return fmt.Errorf("failed to write bundle header: %v", err)
+	}
+	return nil
+}
+
+// ReadHeader reads a version 2 or 3 bundle header from r, leaving r at
+// the start of the pack.
+func ReadHeader(r *bufio.Reader) (*Header, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line != signatureV2 && line != signatureV3 {
+		return nil, fmt.Errorf("not a bundle: unrecognized header %q", line)
+	}
+
+	h := &Header{Format: objectformat.SHA1}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+
+		if capability, ok := strings.CutPrefix(line, "@"); ok {
+			name, isFormat := strings.CutPrefix(capability, formatCap)
+			if !isFormat {
+				return nil, fmt.Errorf("unsupported bundle capability %q", capability)
+			}
+			if h.Format, err = objectformat.Parse(name); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(line, "-"); ok {
+			hash, comment, _ := strings.Cut(rest, " ")
+			if !h.Format.IsValid(hash) {
+				return nil, fmt.Errorf("invalid bundle prerequisite %q", line)
+			}
+			h.Prerequisites = append(h.Prerequisites, Prerequisite{Hash: hash, Comment: comment})
+			continue
+		}
+
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok || name == "" || !h.Format.IsValid(hash) {
+			return nil, fmt.Errorf("invalid bundle reference %q", line)
+		}
+		h.Refs = append(h.Refs, Ref{Hash: hash, Name: name})
+	}
+	return h, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF {
+		return "", fmt.Errorf("truncated bundle header")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read bundle header: %v", err)
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
@@ -0,0 +1,72 @@
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+func TestHeader(t *testing.T) {
+	sha1Hash := strings.Repeat("a", 40)
+	sha256Hash := strings.Repeat("b", 64)
+
+	t.Run("1.1: Write and read a version 2 header", func(t *testing.T) {
+		h := &Header{
+			Format:        objectformat.SHA1,
+			Prerequisites: []Prerequisite{{Hash: sha1Hash, Comment: "base commit"}},
+			Refs:          []Ref{{Hash: sha1Hash, Name: "refs/heads/main"}},
+		}
+		var buf bytes.Buffer
+		if err := h.Write(&buf); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		want := "# v2 git bundle\n-" + sha1Hash + " base commit\n" + sha1Hash + " refs/heads/main\n\n"
+		if buf.String() != want {
+			t.Errorf("Header = %q; want %q", buf.String(), want)
+		}
+
+		buf.WriteString("PACK")
+		r := bufio.NewReader(&buf)
+		got, err := ReadHeader(r)
+		if err != nil {
+			t.Fatalf("Failed to read header: %v", err)
+		}
+		if got.Format.Name != "sha1" || len(got.Prerequisites) != 1 || got.Prerequisites[0] != h.Prerequisites[0] ||
+			len(got.Refs) != 1 || got.Refs[0] != h.Refs[0] {
+			t.Errorf("Read header = %+v", got)
+		}
+		if rest, _ := r.ReadString(0); rest != "PACK" {
+			t.Errorf("Reader left at %q; want the pack", rest)
+		}
+	})
+
+	t.Run("1.2: SHA-256 bundles use version 3", func(t *testing.T) {
+		h := &Header{Format: objectformat.SHA256, Refs: []Ref{{Hash: sha256Hash, Name: "HEAD"}}}
+		var buf bytes.Buffer
+		h.Write(&buf)
+		if !strings.HasPrefix(buf.String(), "# v3 git bundle\n@object-format=sha256\n") {
+			t.Errorf("Header = %q", buf.String())
+		}
+		got, err := ReadHeader(bufio.NewReader(&buf))
+		if err != nil || got.Format.Name != "sha256" || got.Refs[0].Hash != sha256Hash {
+			t.Errorf("Read header = %+v, %v", got, err)
+		}
+	})
+
+	t.Run("1.3: Invalid headers", func(t *testing.T) {
+		for _, text := range []string{
+			"not a bundle\n\n",
+			"# v2 git bundle\n" + sha1Hash + " refs/heads/main\n",
+			"# v2 git bundle\nxyz refs/heads/main\n\n",
+			"# v2 git bundle\n-" + sha256Hash + "\n\n",
+			"# v3 git bundle\n@filter=blob:none\n\n",
+		} {
+			if _, err := ReadHeader(bufio.NewReader(strings.NewReader(text))); err == nil {
+				t.Errorf("Expected error for %q", text)
+			}
+		}
+	})
+}
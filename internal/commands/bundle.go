@@ -0,0 +1,373 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/bundle"
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/pack"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+// BundleCommand creates, verifies or applies a bundle file, which carries
+// references and the objects they need in a single file for transfer
+// without a network connection.
+type BundleCommand struct {
+	rootPath string
+	action   string
+	file     string
+	revs     []string
+}
+
+// NewBundleCommand creates a bundle command. action is "create",
+// "verify" or "unbundle". For create, revs lists what to include: ref
+// names, ^<rev> or <rev>..<ref> to leave out history the receiver already
+// has, or --all for every reference.
+func NewBundleCommand(rootPath, action, file string, revs []string) *BundleCommand {
+	return &BundleCommand{
+		rootPath: rootPath,
+		action:   action,
+		file:     file,
+		revs:     revs,
+	}
+}
+
+func (c *BundleCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	switch c.action {
+	case "create":
+		return c.create(repo)
+	case "verify":
+		return c.verify(repo)
+	case "unbundle":
+		return c.unbundle(repo)
+	}
+	return fmt.Errorf("unknown bundle action: %s", c.action)
+}
+
+func (c *BundleCommand) create(repo *repository.Repository) error {
+	var included []bundle.Ref
+	var excluded []string
+	addRef := func(name string) error {
+		ref, hash, err := bundleRef(c.rootPath, name)
+		if err != nil {
+			return err
+		}
+		included = append(included, bundle.Ref{Hash: hash, Name: ref})
+		return nil
+	}
+	for _, rev := range c.revs {
+		if rev == "--all" {
+			names, err := refs.ListRefs(c.rootPath)
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				if err := addRef(name); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if from, to, ok := strings.Cut(rev, ".."); ok {
+			excluded = append(excluded, from)
+			rev = to
+		} else if name, ok := strings.CutPrefix(rev, "^"); ok {
+			excluded = append(excluded, name)
+			continue
+		}
+		if err := addRef(rev); err != nil {
+			return err
+		}
+	}
+	if len(included) == 0 {
+		return fmt.Errorf("refusing to create an empty bundle")
+	}
+
+	// Everything reachable from an excluded commit is assumed to be on
+	// the receiving side, so the walk over the included references stops
+	// wherever it reaches that history.
+	w := &reachableWalker{
+		store:   repo.Objects,
+		commits: newCommitSource(repo.GitgoDir, repo.Objects),
+		seen:    make(map[string]bool),
+	}
+	for _, name := range excluded {
+		hash, err := resolveCommit(c.rootPath, repo, name)
+		if err != nil {
+			return err
+		}
+		if err := w.walk(hash); err != nil {
+			return err
+		}
+	}
+	known := make(map[string]bool, len(w.seen))
+	for hash := range w.seen {
+		known[hash] = true
+	}
+	w.objects = nil
+	for _, ref := range included {
+		if err := w.walk(ref.Hash); err != nil {
+			return fmt.Errorf("failed to walk %s: %v", ref.Name, err)
+		}
+	}
+
+	header := &bundle.Header{Format: repo.ObjectFormat, Refs: included}
+	prerequisites := make(map[string]bool)
+	entries := make([]pack.Entry, 0, len(w.objects))
+	for _, r := range w.objects {
+		obj, err := repo.Objects.Get(r.hash)
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %v", r.hash, err)
+		}
+		entries = append(entries, pack.Entry{Hash: r.hash, Type: obj.Type, Data: obj.Data, Path: r.path})
+		if r.objectType != object.TypeCommit {
+			continue
+		}
+		com, err := w.commits.lookup(r.hash)
+		if err != nil {
+			return err
+		}
+		for _, parent := range com.Parents {
+			if !known[parent] || prerequisites[parent] {
+				continue
+			}
+			prerequisites[parent] = true
+			header.Prerequisites = append(header.Prerequisites, bundle.Prerequisite{
+				Hash:    parent,
+				Comment: commitSubject(repo.Objects, parent),
+			})
+		}
+	}
+
+	tmp := c.file + ".lock"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %v", err)
+	}
+	defer os.Remove(tmp)
+	out := bufio.NewWriter(file)
+	if err := header.Write(out); err != nil {
+		file.Close()
+		return err
+	}
+	if err := pack.WriteStream(out, repo.ObjectFormat, entries); err != nil {
+		file.Close()
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write bundle: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %v", err)
+	}
+	if err := os.Rename(tmp, c.file); err != nil {
+		return fmt.Errorf("failed to write bundle: %v", err)
+	}
+	fmt.Printf("Created %s with %d objects, %d refs and %d prerequisites\n",
+		c.file, len(entries), len(header.Refs), len(header.Prerequisites))
+	return nil
+}
+
+// bundleRef resolves name the way resolveRevision does but insists on a
+// reference, since a bundle records what it carries by ref name.
+func bundleRef(rootPath, name string) (string, string, error) {
+	candidates := []string{name}
+	if name != refs.HeadFile && !strings.HasPrefix(name, refs.RefsDir+"/") {
+		candidates = []string{refs.HeadsDir + "/" + name, refs.TagsDir + "/" + name}
+	}
+	for _, refName := range candidates {
+		if hash, err := readRefTarget(rootPath, refName); err == nil && hash != "" {
+			return refName, hash, nil
+		}
+	}
+	return "", "", fmt.Errorf("%s is not a reference", name)
+}
+
+// commitSubject returns the first line of a commit's message, or "" when
+// the commit cannot be read.
+func commitSubject(objects object.ObjectStore, hash string) string {
+	c, err := commit.Read(objects, hash)
+	if err != nil {
+		return ""
+	}
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return subject
+}
+
+// open reads the bundle header, checks that it matches the repository's
+// object format and that every prerequisite commit is present, and
+// unpacks the objects it carries. Bases of a thin pack are taken from
+// the repository.
+func (c *BundleCommand) open(repo *repository.Repository) (*bundle.Header, []pack.Entry, error) {
+	file, err := os.Open(c.file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open bundle: %v", err)
+	}
+	defer file.Close()
+	in := bufio.NewReader(file)
+	header, err := bundle.ReadHeader(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.Format.Name != repo.ObjectFormat.Name {
+		return nil, nil, fmt.Errorf("bundle uses %s object IDs, the repository uses %s",
+			header.Format.Name, repo.ObjectFormat.Name)
+	}
+
+	var missing []string
+	for _, p := range header.Prerequisites {
+		if objectType, _, err := object.ReadHeader(repo.Objects, p.Hash); err != nil || objectType != object.TypeCommit {
+			missing = append(missing, strings.TrimSpace(p.Hash+" "+p.Comment))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("repository lacks these prerequisite commits:\n  %s",
+			strings.Join(missing, "\n  "))
+	}
+
+	resolve := func(hash string) (string, []byte, error) {
+		obj, err := repo.Objects.Get(hash)
+		if err != nil {
+			return "", nil, err
+		}
+		return obj.Type, obj.Data, nil
+	}
+	entries, err := pack.Unpack(in, header.Format, resolve)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid bundle pack: %v", err)
+	}
+
+	carried := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		carried[e.Hash] = true
+	}
+	for _, ref := range header.Refs {
+		if !carried[ref.Hash] && !repo.Objects.Has(ref.Hash) {
+			return nil, nil, fmt.Errorf("bundle is missing %s for %s", ref.Hash, ref.Name)
+		}
+	}
+	return header, entries, nil
+}
+
+func (c *BundleCommand) verify(repo *repository.Repository) error {
+	header, _, err := c.open(repo)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("The bundle contains %d refs:\n", len(header.Refs))
+	for _, ref := range header.Refs {
+		fmt.Printf("%s %s\n", ref.Hash, ref.Name)
+	}
+	if len(header.Prerequisites) == 0 {
+		fmt.Println("The bundle records a complete history.")
+	} else {
+		fmt.Printf("The bundle requires these %d commits:\n", len(header.Prerequisites))
+		for _, p := range header.Prerequisites {
+			fmt.Println(strings.TrimSpace(p.Hash + " " + p.Comment))
+		}
+	}
+	fmt.Printf("%s is okay\n", c.file)
+	return nil
+}
+
+// unbundle stores the bundle's objects and then points its references
+// at them. An encrypted repository only holds encrypted loose objects,
+// so there they are written one by one instead of as a pack. Branches
+// and tags that already exist are only moved forward; a bundle that
+// would rewind or replace one is rejected before anything is updated.
+func (c *BundleCommand) unbundle(repo *repository.Repository) error {
+	header, entries, err := c.open(repo)
+	if err != nil {
+		return err
+	}
+
+	updates := make(map[string]string)
+	commits := newCommitSource(repo.GitgoDir, repo.Objects)
+	var fresh []pack.Entry
+	for _, e := range entries {
+		if !object.HasLocal(repo.Objects, e.Hash) {
+			fresh = append(fresh, e)
+		}
+	}
+	if err := storeEntries(repo, fresh); err != nil {
+		return err
+	}
+
+	for _, ref := range header.Refs {
+		if ref.Name == refs.HeadFile {
+			continue
+		}
+		if err := refs.CheckName(ref.Name); err != nil {
+			return fmt.Errorf("bundle: %v", err)
+		}
+		current, err := refs.ReadRef(c.rootPath, ref.Name)
+		switch {
+		case err != nil || current.Target == "":
+			updates[ref.Name] = ref.Hash
+			continue
+		case current.Target == ref.Hash:
+			continue
+		case strings.HasPrefix(ref.Name, refs.TagsDir+"/"):
+			return fmt.Errorf("refusing to replace existing tag %s", ref.Name)
+		}
+		ok, err := commits.isAncestor(current.Target, ref.Hash)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("refusing to update %s, %s is not a descendant of %s",
+				ref.Name, ref.Hash, current.Target)
+		}
+		updates[ref.Name] = ref.Hash
+	}
+
+	updated := 0
+	for _, ref := range header.Refs {
+		target := updates[ref.Name]
+		if target == "" {
+			continue
+		}
+		old := ""
+		if current, err := refs.ReadRef(c.rootPath, ref.Name); err == nil {
+			old = current.Target
+		}
+		if err := refs.CompareAndSwapRef(c.rootPath, ref.Name, old, target); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s\n", target, ref.Name)
+		updated++
+	}
+	fmt.Printf("Unbundled %d objects, updated %d refs\n", len(fresh), updated)
+	return nil
+}
+
+// storeEntries adds unpacked objects to the repository, as a new pack
+// unless the repository is encrypted.
+func storeEntries(repo *repository.Repository, entries []pack.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if !repo.Encrypted {
+		if _, err := pack.Write(filepath.Join(repo.ObjectPath(), "pack"), repo.ObjectFormat, entries); err != nil {
+			return fmt.Errorf("failed to write pack: %v", err)
+		}
+		return nil
+	}
+	for _, e := range entries {
+		if _, err := repo.Objects.Put(e.Type, e.Data); err != nil {
+			return fmt.Errorf("failed to store object %s: %v", e.Hash, err)
+		}
+	}
+	return nil
+}
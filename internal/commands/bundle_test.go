@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+func TestBundleCommand(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDir := filepath.Join(cwd, "testdata")
+	otherDir := filepath.Join(cwd, "testdata-unbundle")
+	fullBundle := filepath.Join(cwd, "testdata-full.bundle")
+	incBundle := filepath.Join(cwd, "testdata-inc.bundle")
+
+	setup := func(t *testing.T) []string {
+		for _, path := range []string{testDir, otherDir, fullBundle, incBundle} {
+			os.RemoveAll(path)
+		}
+		os.MkdirAll(testDir, 0755)
+		os.MkdirAll(otherDir, 0755)
+		repo, err := repository.Init(testDir)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		if _, err := repository.Init(otherDir); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		first := buildTree(t, repo.Objects, map[string]string{"a.txt": strings.Repeat("line\n", 200), "dir/b.txt": "bee\n"})
+		second := buildTree(t, repo.Objects, map[string]string{"a.txt": strings.Repeat("line\n", 201), "dir/b.txt": "bee\n"})
+		base, _ := NewCommitTreeCommand(testDir, first, nil, "first\n").Write()
+		next, _ := NewCommitTreeCommand(testDir, second, []string{base}, "second\n").Write()
+		refs.UpdateRef(testDir, "refs/heads/main", next, false)
+		refs.UpdateRef(testDir, "refs/heads/old", base, false)
+		return []string{base, next}
+	}
+	cleanup := func() {
+		for _, path := range []string{testDir, otherDir, fullBundle, incBundle} {
+			os.RemoveAll(path)
+		}
+	}
+
+	t.Run("1.1: Create and unbundle a complete bundle", func(t *testing.T) {
+		commits := setup(t)
+		defer cleanup()
+
+		if err := NewBundleCommand(testDir, "create", fullBundle, []string{"--all"}).Execute(); err != nil {
+			t.Fatalf("Failed to create bundle: %v", err)
+		}
+		if err := NewBundleCommand(otherDir, "verify", fullBundle, nil).Execute(); err != nil {
+			t.Fatalf("Failed to verify bundle: %v", err)
+		}
+		if err := NewBundleCommand(otherDir, "unbundle", fullBundle, nil).Execute(); err != nil {
+			t.Fatalf("Failed to unbundle: %v", err)
+		}
+		main, _ := refs.ReadRef(otherDir, "refs/heads/main")
+		old, _ := refs.ReadRef(otherDir, "refs/heads/old")
+		if main.Target != commits[1] || old.Target != commits[0] {
+			t.Errorf("Unbundled refs = %s, %s; want %v", main.Target, old.Target, commits)
+		}
+		if err := NewFsckCommand(otherDir, false).Execute(); err != nil {
+			t.Errorf("Unbundled repository is not consistent: %v", err)
+		}
+	})
+
+	t.Run("1.2: Incremental bundle needs its prerequisites", func(t *testing.T) {
+		commits := setup(t)
+		defer cleanup()
+
+		if err := NewBundleCommand(testDir, "create", incBundle, []string{"old..main"}).Execute(); err != nil {
+			t.Fatalf("Failed to create bundle: %v", err)
+		}
+		if err := NewBundleCommand(otherDir, "verify", incBundle, nil).Execute(); err == nil ||
+			!strings.Contains(err.Error(), commits[0]+" first") {
+			t.Errorf("Expected missing prerequisite error, got %v", err)
+		}
+		if err := NewBundleCommand(otherDir, "unbundle", incBundle, nil).Execute(); err == nil {
+			t.Error("Expected unbundle to fail without prerequisites")
+		}
+		if ref, _ := refs.ReadRef(otherDir, "refs/heads/main"); ref.Target != "" {
+			t.Errorf("main was updated to %s without prerequisites", ref.Target)
+		}
+
+		NewBundleCommand(testDir, "create", fullBundle, []string{"old"}).Execute()
+		if err := NewBundleCommand(otherDir, "unbundle", fullBundle, nil).Execute(); err != nil {
+			t.Fatalf("Failed to unbundle: %v", err)
+		}
+		if err := NewBundleCommand(otherDir, "unbundle", incBundle, nil).Execute(); err != nil {
+			t.Fatalf("Failed to unbundle: %v", err)
+		}
+		if ref, _ := refs.ReadRef(otherDir, "refs/heads/main"); ref.Target != commits[1] {
+			t.Errorf("main = %s; want %s", ref.Target, commits[1])
+		}
+	})
+
+	t.Run("1.3: Refuse to rewind a branch", func(t *testing.T) {
+		commits := setup(t)
+		defer cleanup()
+
+		NewBundleCommand(testDir, "create", fullBundle, []string{"main"}).Execute()
+		NewBundleCommand(otherDir, "unbundle", fullBundle, nil).Execute()
+
+		refs.UpdateRef(testDir, "refs/heads/main", commits[0], false)
+		os.Remove(fullBundle)
+		NewBundleCommand(testDir, "create", fullBundle, []string{"main"}).Execute()
+		if err := NewBundleCommand(otherDir, "unbundle", fullBundle, nil).Execute(); err == nil {
+			t.Error("Expected error when the bundle would rewind main")
+		}
+		if err := NewBundleCommand(testDir, "create", fullBundle+"2", []string{"^main"}).Execute(); err == nil {
+			t.Error("Expected error for a bundle without references")
+		}
+	})
+}
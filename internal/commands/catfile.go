@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/HalilFocic/gitgo/internal/blob"
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/tag"
+	"github.com/HalilFocic/gitgo/internal/tree"
+)
+
+type CatFileCommand struct {
+	rootPath string
+	hash     string
+	mode     string
+}
+
+// NewCatFileCommand creates a command that inspects a single object.
+// hash may also be abbreviated or name a branch or tag. mode is one of "type", "size" or "pretty".
+func NewCatFileCommand(rootPath, hash, mode string) *CatFileCommand {
+	return &CatFileCommand{
+		rootPath: rootPath,
+		hash:     hash,
+		mode:     mode,
+	}
+}
+
+func (c *CatFileCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	objects := repo.Objects
+	if c.hash, err = resolveRevision(c.rootPath, repo, c.hash); err != nil {
+		return err
+	}
+
+	switch c.mode {
+	case "type":
+		objectType, _, err := object.ReadHeader(objects, c.hash)
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %v", c.hash, err)
+		}
+		fmt.Println(objectType)
+
+	case "size":
+		_, size, err := object.ReadHeader(objects, c.hash)
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %v", c.hash, err)
+		}
+		fmt.Println(size)
+
+	case "pretty":
+		obj, err := objects.Get(c.hash)
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %v", c.hash, err)
+		}
+		format, err := objectformat.ForHash(c.hash)
+		if err != nil {
+			return err
+		}
+		return printObject(obj, format)
+
+	default:
+		return fmt.Errorf("unknown cat-file mode: %s", c.mode)
+	}
+	return nil
+}
+
+func printObject(obj *object.Object, format objectformat.Format) error {
+	switch obj.Type {
+	case object.TypeBlob:
+		b, err := blob.NewWithFormat(obj.Data, format)
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(b.Content())
+
+	case object.TypeTree:
+		t, err := tree.Parse(obj.Data, format)
+		if err != nil {
+			return fmt.Errorf("failed to parse tree: %v", err)
+		}
+		for _, entry := range t.Entries() {
+			entryType := object.TypeBlob
+			switch entry.Mode {
+			case tree.DirectoryMode:
+				entryType = object.TypeTree
+			case tree.GitlinkMode:
+				entryType = object.TypeCommit
+			}
+			fmt.Printf("%06o %s %s\t%s\n", entry.Mode, entryType, entry.Hash, entry.Name)
+		}
+
+	case object.TypeCommit, object.TypeTag:
+		if obj.Type == object.TypeCommit {
+			if _, err := commit.Parse(obj.Data); err != nil {
+				return fmt.Errorf("failed to parse commit: %v", err)
+			}
+		} else if _, err := tag.Parse(obj.Data); err != nil {
+			return fmt.Errorf("failed to parse tag: %v", err)
+		}
+		os.Stdout.Write(obj.Data)
+		if len(obj.Data) > 0 && obj.Data[len(obj.Data)-1] != '\n' {
+			fmt.Println()
+		}
+
+	default:
+		return fmt.Errorf("unknown object type %s", obj.Type)
+	}
+	return nil
+}
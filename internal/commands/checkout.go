@@ -2,19 +2,24 @@ package commands
 
 import (
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 
 	"github.com/HalilFocic/gitgo/internal/blob"
 	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/config"
+	"github.com/HalilFocic/gitgo/internal/object"
 	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
 	"github.com/HalilFocic/gitgo/internal/tree"
 )
 
 type CheckoutCommand struct {
 	rootPath string
 	target   string
+	objects  object.ObjectStore
 }
 
 func NewCheckoutCommand(rootPath, target string) *CheckoutCommand {
@@ -25,41 +30,55 @@ func NewCheckoutCommand(rootPath, target string) *CheckoutCommand {
 }
 
 func (c *CheckoutCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	c.objects = repo.Objects
+
 	branchRef := filepath.Join("refs", "heads", c.target)
 	ref, err := refs.ReadRef(c.rootPath, branchRef)
+	isBranch := err == nil
 
 	var commitHash string
-	if err == nil {
+	if isBranch {
 		commitHash = ref.Target
-		if err := refs.WriteHead(c.rootPath, branchRef, true); err != nil {
-			return fmt.Errorf("failed to update HEAD: %v", err)
-		}
 	} else {
-		if len(c.target) != 40 {
-			return fmt.Errorf("invalid reference: %s", c.target)
-		}
-		commitHash = c.target
-		if err := refs.WriteHead(c.rootPath, commitHash, false); err != nil {
-			return fmt.Errorf("failed to update HEAD: %v", err)
+		commitHash, err = resolveCommit(c.rootPath, repo, c.target)
+		if err != nil {
+			return fmt.Errorf("invalid reference: %v", err)
 		}
 	}
 
-	com, err := commit.Read(filepath.Join(c.rootPath, ".gitgo", "objects"), commitHash)
+	com, err := commit.Read(c.objects, commitHash)
 	if err != nil {
 		return fmt.Errorf("failed to read commit: %v", err)
 	}
 
-	rootTree, err := tree.Read(filepath.Join(c.rootPath, ".gitgo", "objects"), com.TreeHash)
+	rootTree, err := tree.Read(c.objects, com.TreeHash)
 	if err != nil {
 		return fmt.Errorf("failed to read tree: %v", err)
 	}
 
+	// HEAD of a stock .git repository is left alone, only the working
+	// tree is replaced.
+	if !repository.ReadOnly(c.rootPath) {
+		if isBranch {
+			err = refs.WriteHead(c.rootPath, branchRef, true)
+		} else {
+			err = refs.WriteHead(c.rootPath, commitHash, false)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update HEAD: %v", err)
+		}
+	}
+
 	files, err := filepath.Glob(filepath.Join(c.rootPath, "*"))
 	if err != nil {
 		return fmt.Errorf("failed to list files: %v", err)
 	}
 	for _, f := range files {
-		if filepath.Base(f) != ".gitgo" {
+		if !isGitDirName(filepath.Base(f)) {
 			os.RemoveAll(f)
 		}
 	}
@@ -71,28 +90,70 @@ func (c *CheckoutCommand) Execute() error {
 	return nil
 }
 
+func isGitDirName(name string) bool {
+	for _, gitDirName := range config.GitDirNames {
+		if name == gitDirName {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *CheckoutCommand) writeTree(t *tree.Tree, path string) error {
 	for _, entry := range t.Entries() {
 		fullPath := filepath.Join(path, entry.Name)
 
-		if entry.Mode == tree.DirectoryMode {
+		switch entry.Mode {
+		case tree.DirectoryMode:
 			os.MkdirAll(fullPath, 0755)
-			subTree, err := tree.Read(filepath.Join(c.rootPath, ".gitgo", "objects"), entry.Hash)
+			subTree, err := tree.Read(c.objects, entry.Hash)
 			if err != nil {
 				return fmt.Errorf("failed to read subtree: %v", err)
 			}
 			if err := c.writeTree(subTree, fullPath); err != nil {
 				return err
 			}
-		} else {
-			b, err := blob.Read(filepath.Join(c.rootPath, ".gitgo", "objects"), entry.Hash)
+		case tree.GitlinkMode:
+			// Submodule contents live in another repository, leave an
+			// empty directory in their place.
+			if err := os.MkdirAll(fullPath, 0755); err != nil {
+				return fmt.Errorf("failed to create submodule directory: %v", err)
+			}
+		case tree.SymlinkMode:
+			b, err := blob.Read(c.objects, entry.Hash)
 			if err != nil {
 				return fmt.Errorf("failed to read blob: %v", err)
 			}
-			if err := os.WriteFile(fullPath, b.Content(), fs.FileMode(entry.Mode)); err != nil {
-				return fmt.Errorf("failed to write file: %v", err)
+			if err := os.Symlink(string(b.Content()), fullPath); err != nil {
+				return fmt.Errorf("failed to create symlink: %v", err)
+			}
+		default:
+			if err := c.writeFile(entry.Hash, fullPath, fs.FileMode(entry.Mode)); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
+
+// writeFile copies blob hash to path without loading it into memory.
+func (c *CheckoutCommand) writeFile(hash, path string, mode fs.FileMode) error {
+	reader, _, err := blob.Open(c.objects, hash)
+	if err != nil {
+		return fmt.Errorf("failed to read blob: %v", err)
+	}
+	defer reader.Close()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	return nil
+}
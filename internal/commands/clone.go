@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+type CloneCommand struct {
+	source string
+	target string
+	shared bool
+}
+
+// NewCloneCommand creates a command that clones the repository at source
+// on the local filesystem into target. With shared set no objects are
+// copied; the clone lists the source's objects directory as an alternate
+// instead, so the source must not prune objects the clone still uses.
+func NewCloneCommand(source, target string, shared bool) *CloneCommand {
+	return &CloneCommand{
+		source: source,
+		target: target,
+		shared: shared,
+	}
+}
+
+func (c *CloneCommand) Execute() error {
+	src, err := repository.Open(c.source)
+	if err != nil {
+		return err
+	}
+	if src.Encrypted {
+		return fmt.Errorf("cannot clone an encrypted repository")
+	}
+	if entries, err := os.ReadDir(c.target); err == nil && len(entries) > 0 {
+		return fmt.Errorf("destination path %s already exists and is not an empty directory", c.target)
+	}
+	if err := os.MkdirAll(c.target, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", c.target, err)
+	}
+
+	dst, err := repository.InitWithObjectFormat(c.target, src.ObjectFormat)
+	if err != nil {
+		return err
+	}
+	if err := c.populate(src, dst); err != nil {
+		os.RemoveAll(dst.GitgoDir)
+		return err
+	}
+
+	head, err := readRefTarget(dst.Path, refs.HeadFile)
+	if err != nil || head == "" {
+		fmt.Printf("Cloned %s into %s, the repository is empty\n", src.Path, dst.Path)
+		return nil
+	}
+	target := head
+	if ref, _ := refs.ReadHead(dst.Path); ref.Type == refs.RefTypeSymbolic {
+		target = strings.TrimPrefix(ref.Target, refs.HeadsDir+"/")
+	}
+	if err := NewCheckoutCommand(dst.Path, target).Execute(); err != nil {
+		return fmt.Errorf("failed to check out %s: %v", target, err)
+	}
+	fmt.Printf("Cloned %s into %s\n", src.Path, dst.Path)
+	return nil
+}
+
+// populate fills the new repository dst with the objects and references
+// of src and points HEAD where src's HEAD points.
+func (c *CloneCommand) populate(src, dst *repository.Repository) error {
+	if c.shared {
+		if err := object.AddAlternate(dst.ObjectPath(), src.ObjectPath()); err != nil {
+			return err
+		}
+	} else if err := copyObjects(src.ObjectPath(), dst.ObjectPath()); err != nil {
+		return err
+	}
+
+	names, err := refs.ListRefs(src.Path)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		ref, err := refs.ReadRef(src.Path, name)
+		if err != nil {
+			return err
+		}
+		if ref.Target == "" {
+			continue
+		}
+		if err := refs.UpdateRef(dst.Path, name, ref.Target, ref.Type == refs.RefTypeSymbolic); err != nil {
+			return err
+		}
+	}
+
+	head, err := refs.ReadHead(src.Path)
+	if err != nil {
+		return err
+	}
+	if head.Type == refs.RefTypeSymbolic && head.Target != refs.HeadsDir+"/main" {
+		// Init created an unborn main branch the source may not have.
+		mainRef := filepath.Join(dst.GitgoDir, "refs", "heads", "main")
+		if info, err := os.Stat(mainRef); err == nil && info.Size() == 0 {
+			os.Remove(mainRef)
+		}
+	}
+	return refs.WriteHead(dst.Path, head.Target, head.Type == refs.RefTypeSymbolic)
+}
+
+// copyObjects copies the loose objects and packs of one objects directory
+// into another, together with the alternates the source borrows from.
+// Objects are copied as they are; both repositories use the same format.
+func copyObjects(srcPath, dstPath string) error {
+	err := filepath.WalkDir(srcPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcPath, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel == "info" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, "tmp_") || strings.HasSuffix(name, ".lock") {
+			return nil
+		}
+		return copyFile(path, filepath.Join(dstPath, rel))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy objects: %v", err)
+	}
+
+	alternates, err := object.ReadAlternates(srcPath)
+	if err != nil {
+		return err
+	}
+	for _, alt := range alternates {
+		if err := object.AddAlternate(dstPath, alt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies a read-only object or pack file, hard linking it when
+// both paths are on the same filesystem.
+func copyFile(srcPath, dstPath string) error {
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Link(srcPath, dstPath); err == nil {
+		return nil
+	}
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0444)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/staging"
+)
+
+func TestCloneCommand(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDir := filepath.Join(cwd, "testdata")
+	sourceDir := filepath.Join(testDir, "source")
+	cloneDir := filepath.Join(testDir, "clone")
+
+	setup := func(t *testing.T) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(sourceDir, 0755)
+		if _, err := repository.Init(sourceDir); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte("main content"), 0644)
+		idx, _ := staging.New(sourceDir)
+		idx.Add("main.go")
+		if err := NewCommitCommand(sourceDir, "first", "Test User <test@example.com>", false).Execute(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		if err := NewTagCommand(sourceDir, "v1", "", "", "", "create").Execute(); err != nil {
+			t.Fatalf("Failed to tag: %v", err)
+		}
+	}
+
+	t.Run("1.1: Full clone", func(t *testing.T) {
+		setup(t)
+		defer os.RemoveAll(testDir)
+
+		if err := NewCloneCommand(sourceDir, cloneDir, false).Execute(); err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(cloneDir, "main.go"))
+		if err != nil || string(content) != "main content" {
+			t.Errorf("Working tree not checked out: %q, %v", content, err)
+		}
+		source, _ := refs.ReadRef(sourceDir, "refs/heads/main")
+		for _, name := range []string{"refs/heads/main", "refs/tags/v1"} {
+			if ref, err := refs.ReadRef(cloneDir, name); err != nil || ref.Target != source.Target {
+				t.Errorf("%s = %q, %v; want %s", name, ref.Target, err, source.Target)
+			}
+		}
+		repo, _ := repository.Open(cloneDir)
+		if !object.HasLocal(repo.Objects, source.Target) {
+			t.Error("Full clone should copy the objects")
+		}
+		if err := NewCloneCommand(sourceDir, cloneDir, false).Execute(); err == nil {
+			t.Error("Expected error cloning into a non-empty directory")
+		}
+	})
+
+	t.Run("1.2: Shared clone and repack -a", func(t *testing.T) {
+		setup(t)
+		defer os.RemoveAll(testDir)
+
+		if err := NewCloneCommand(sourceDir, cloneDir, true).Execute(); err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+		source, _ := refs.ReadRef(sourceDir, "refs/heads/main")
+		repo, _ := repository.Open(cloneDir)
+		if object.HasLocal(repo.Objects, source.Target) || !repo.Objects.Has(source.Target) {
+			t.Fatal("Shared clone should borrow the source's objects")
+		}
+		if err := NewGCCommand(cloneDir, "now").Execute(); err != nil {
+			t.Fatalf("Failed to run gc: %v", err)
+		}
+		report, err := NewFsckCommand(cloneDir, false).Check()
+		if err != nil || len(report.Missing)+len(report.BadRefs) != 0 {
+			t.Fatalf("Borrowed objects reported missing: %+v, %v", report, err)
+		}
+
+		if err := NewRepackCommand(cloneDir, true).Execute(); err != nil {
+			t.Fatalf("Failed to repack: %v", err)
+		}
+		os.Remove(filepath.Join(repo.ObjectPath(), object.AlternatesFile))
+		os.RemoveAll(sourceDir)
+		report, err = NewFsckCommand(cloneDir, false).Check()
+		if err != nil || report.Checked != 3 || len(report.Missing)+len(report.Corrupt) != 0 {
+			t.Errorf("Clone is not self-contained after repack -a: %+v, %v", report, err)
+		}
+	})
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/repository"
 	"github.com/HalilFocic/gitgo/internal/staging"
 	"github.com/HalilFocic/gitgo/internal/tree"
 )
@@ -16,13 +18,19 @@ type CommitCommand struct {
 	rootPath string
 	message  string
 	author   string
+	sign     bool
 }
 
-func NewCommitCommand(rootPath, message, author string) *CommitCommand {
+// NewCommitCommand creates a commit command. An empty author is taken
+// from GITGO_AUTHOR_NAME and GITGO_AUTHOR_EMAIL, or user.name and
+// user.email. With sign set the commit carries an SSH signature made
+// with the key in user.signingKey.
+func NewCommitCommand(rootPath, message, author string, sign bool) *CommitCommand {
 	return &CommitCommand{
 		rootPath: rootPath,
 		message:  message,
 		author:   author,
+		sign:     sign,
 	}
 }
 
@@ -36,7 +44,13 @@ func (c *CommitCommand) Execute() error {
 	if len(entries) == 0 {
 		return fmt.Errorf("nothing to commit, staging area is empty")
 	}
-	headContent, err := os.ReadFile(filepath.Join(c.rootPath, ".gitgo", "HEAD"))
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	objects := repo.Objects
+
+	headContent, err := os.ReadFile(filepath.Join(repo.GitgoDir, "HEAD"))
 	if err != nil {
 		return err
 	}
@@ -47,7 +61,7 @@ func (c *CommitCommand) Execute() error {
 	}
 
 	branchName := strings.TrimPrefix(headRef, "ref: refs/heads/")
-	branchPath := filepath.Join(c.rootPath, ".gitgo", "refs", "heads", branchName)
+	branchPath := filepath.Join(repo.RefsPath(), "heads", branchName)
 
 	var previousTreeHash string
 	parentHash := ""
@@ -56,7 +70,7 @@ func (c *CommitCommand) Execute() error {
 		parentHash = strings.TrimSpace(string(previousCommitHash))
 
 		if parentHash != "" {
-			previousCommit, err := commit.Read(filepath.Join(c.rootPath, ".gitgo", "objects"), parentHash)
+			previousCommit, err := commit.Read(objects, parentHash)
 			if err != nil {
 				return fmt.Errorf("failed to read previous commit :%v", err)
 			}
@@ -64,9 +78,8 @@ func (c *CommitCommand) Execute() error {
 
 		}
 	}
-	objectsPath := filepath.Join(c.rootPath, ".gitgo", "objects")
-	combinedRoot := c.combineTreeWithStaged(previousTreeHash, entries, objectsPath)
-	treeHash, err := c.createTreeFromNode(combinedRoot, objectsPath)
+	combinedRoot := c.combineTreeWithStaged(previousTreeHash, entries, objects)
+	treeHash, err := createTreeFromNode(combinedRoot, objects)
 	if err != nil {
 		return fmt.Errorf("failed to create tree: %v", err)
 	}
@@ -75,12 +88,30 @@ func (c *CommitCommand) Execute() error {
 		parentHash = strings.TrimSpace(string(hash))
 	}
 
-	newCommit, err := commit.New(treeHash, parentHash, c.author, c.message)
+	var parents []string
+	if parentHash != "" {
+		parents = []string{parentHash}
+	}
+	author := c.author
+	if author == "" {
+		if author, err = authorIdent(repo.GitgoDir); err != nil {
+			return err
+		}
+	}
+	newCommit, err := commit.New(treeHash, parents, author, c.message)
 	if err != nil {
 		return fmt.Errorf("failed to create commit: %v", err)
 	}
+	if err := stampCommit(repo.GitgoDir, newCommit); err != nil {
+		return err
+	}
+	if c.sign {
+		if err := signCommit(repo.GitgoDir, newCommit); err != nil {
+			return fmt.Errorf("failed to sign commit: %v", err)
+		}
+	}
 
-	commitHash, err := newCommit.Write(objectsPath)
+	commitHash, err := newCommit.Write(objects)
 	if err != nil {
 		return fmt.Errorf("failed to write commit :%v", err)
 	}
@@ -89,6 +120,7 @@ func (c *CommitCommand) Execute() error {
 		return fmt.Errorf("failed to update branch reference: %v", err)
 	}
 	index.Clear()
+	traceObjectCache(repo)
 	return nil
 }
 
@@ -104,7 +136,7 @@ func NewPathNode() *pathNode {
 	}
 }
 
-func (c *CommitCommand) groupEntriesByDirectory(entries []*staging.Entry) *pathNode {
+func groupEntriesByDirectory(entries []*staging.Entry) *pathNode {
 	root := NewPathNode()
 
 	for _, entry := range entries {
@@ -135,11 +167,11 @@ func getFileMode(mode fs.FileMode) int {
 	return tree.RegularFileMode
 }
 
-func (c *CommitCommand) createTreeFromNode(node *pathNode, objectsPath string) (string, error) {
+func createTreeFromNode(node *pathNode, objects object.ObjectStore) (string, error) {
 	t := tree.New()
 
 	for dirName, childNode := range node.children {
-		childHash, err := c.createTreeFromNode(childNode, objectsPath)
+		childHash, err := createTreeFromNode(childNode, objects)
 		if err != nil {
 			return "", fmt.Errorf("failed to create tree for %s: %v", dirName, err)
 		}
@@ -153,24 +185,24 @@ func (c *CommitCommand) createTreeFromNode(node *pathNode, objectsPath string) (
 		}
 	}
 
-	hash, err := t.Write(objectsPath)
+	hash, err := t.Write(objects)
 	if err != nil {
 		return "", fmt.Errorf("failed to write tree: %v", err)
 	}
 	return hash, nil
 }
 
-func (c *CommitCommand) combineTreeWithStaged(previousTreeHash string, stagedEntries []*staging.Entry, objectsPath string) *pathNode {
+func (c *CommitCommand) combineTreeWithStaged(previousTreeHash string, stagedEntries []*staging.Entry, objects object.ObjectStore) *pathNode {
 	root := NewPathNode()
 
 	if previousTreeHash != "" {
-		previousTree, err := tree.Read(objectsPath, previousTreeHash)
+		previousTree, err := tree.Read(objects, previousTreeHash)
 		if err != nil {
 			fmt.Printf("Warning: could not read previous tree: %v\n", err)
 		} else {
 			for _, entry := range previousTree.Entries() {
 				if entry.Mode == tree.DirectoryMode {
-					c.addTreeEntriesToPathNode(root, entry.Name, entry.Hash, objectsPath)
+					c.addTreeEntriesToPathNode(root, entry.Name, entry.Hash, objects)
 				} else {
 					root.files[entry.Name] = staging.Entry{
 						Path: entry.Name,
@@ -201,8 +233,8 @@ func (c *CommitCommand) combineTreeWithStaged(previousTreeHash string, stagedEnt
 	return root
 }
 
-func (c *CommitCommand) addTreeEntriesToPathNode(root *pathNode, prefix string, treeHash string, objectsPath string) {
-	subtree, err := tree.Read(objectsPath, treeHash)
+func (c *CommitCommand) addTreeEntriesToPathNode(root *pathNode, prefix string, treeHash string, objects object.ObjectStore) {
+	subtree, err := tree.Read(objects, treeHash)
 	if err != nil {
 		fmt.Printf("Warning: could not read subtree %s: %v\n", treeHash, err)
 		return
@@ -212,7 +244,7 @@ func (c *CommitCommand) addTreeEntriesToPathNode(root *pathNode, prefix string,
 		fullPath := filepath.Join(prefix, entry.Name)
 
 		if entry.Mode == tree.DirectoryMode {
-			c.addTreeEntriesToPathNode(root, fullPath, entry.Hash, objectsPath)
+			c.addTreeEntriesToPathNode(root, fullPath, entry.Hash, objects)
 		} else {
 			root.files[fullPath] = staging.Entry{
 				Path: fullPath,
@@ -5,8 +5,13 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/config"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+	"github.com/HalilFocic/gitgo/internal/refs"
 	"github.com/HalilFocic/gitgo/internal/repository"
 	"github.com/HalilFocic/gitgo/internal/staging"
+	"github.com/HalilFocic/gitgo/internal/tag"
 )
 
 func TestCommitCommand(t *testing.T) {
@@ -65,6 +70,7 @@ func TestCommitCommand(t *testing.T) {
 			".",
 			"Initial commit",
 			"Test User <test@example.com>",
+			false,
 		)
 
 		err = cmd.Execute()
@@ -108,6 +114,7 @@ func TestCommitCommand(t *testing.T) {
 			testDir,
 			"Empty commit",
 			"Test User <test@example.com>",
+			false,
 		)
 
 		err = cmd.Execute()
@@ -165,6 +172,7 @@ func TestCommitCommand(t *testing.T) {
 			testDir,
 			"Nested directories",
 			"Test User <test@example.com>",
+			false,
 		)
 
 		err = cmd.Execute()
@@ -192,4 +200,103 @@ func TestCommitCommand(t *testing.T) {
 		}
 	})
 
+	t.Run("1.4: SHA-256 repository", func(t *testing.T) {
+		cwd, _ := os.Getwd()
+		testDir := filepath.Join(cwd, "testdata")
+		os.RemoveAll(testDir)
+		os.MkdirAll(filepath.Join(testDir, "lib"), 0755)
+		defer os.RemoveAll(testDir)
+
+		if _, err := repository.InitWithObjectFormat(testDir, objectformat.SHA256); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		os.WriteFile(filepath.Join(testDir, "main.go"), []byte("main content"), 0644)
+		os.WriteFile(filepath.Join(testDir, "lib", "util.go"), []byte("util content"), 0644)
+
+		idx, err := staging.New(testDir)
+		if err != nil {
+			t.Fatalf("Failed to create staging area: %v", err)
+		}
+		for _, path := range []string{"main.go", "lib/util.go"} {
+			if err := idx.Add(path); err != nil {
+				t.Fatalf("Failed to stage %s: %v", path, err)
+			}
+		}
+		reread, err := staging.New(testDir)
+		if err != nil {
+			t.Fatalf("Failed to reread index: %v", err)
+		}
+		for _, entry := range reread.Entries() {
+			if len(entry.Hash) != 64 {
+				t.Errorf("Index entry %s has hash %s; want a SHA-256 hash", entry.Path, entry.Hash)
+			}
+		}
+
+		if err := NewCommitCommand(testDir, "sha256 commit", "Test User <test@example.com>", false).Execute(); err != nil {
+			t.Fatalf("Failed to execute commit: %v", err)
+		}
+		ref, err := refs.ReadRef(testDir, "refs/heads/main")
+		if err != nil || len(ref.Target) != 64 {
+			t.Fatalf("Branch should point at a SHA-256 commit, got %q (%v)", ref.Target, err)
+		}
+
+		os.RemoveAll(filepath.Join(testDir, "lib"))
+		if err := NewCheckoutCommand(testDir, ref.Target).Execute(); err != nil {
+			t.Fatalf("Failed to checkout SHA-256 commit: %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(testDir, "lib", "util.go"))
+		if err != nil || string(content) != "util content" {
+			t.Errorf("Checked out content mismatch: %q (%v)", content, err)
+		}
+	})
+
+	t.Run("1.5: Identities from the environment and config", func(t *testing.T) {
+		cwd, _ := os.Getwd()
+		testDir := filepath.Join(cwd, "testdata")
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		defer os.RemoveAll(testDir)
+
+		repo, err := repository.Init(testDir)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		cfg, _ := config.Load(repo.GitgoDir)
+		cfg.Set("user.name", "Config User")
+		cfg.Set("user.email", "config@example.com")
+		if err := cfg.Save(repo.GitgoDir); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+		t.Setenv(AuthorNameEnv, "Env Author")
+		t.Setenv(AuthorEmailEnv, "")
+		t.Setenv(CommitterNameEnv, "")
+		t.Setenv(CommitterEmailEnv, "")
+
+		os.WriteFile(filepath.Join(testDir, "main.go"), []byte("main content"), 0644)
+		idx, _ := staging.New(testDir)
+		idx.Add("main.go")
+		if err := NewCommitCommand(testDir, "configured", "", false).Execute(); err != nil {
+			t.Fatalf("Failed to execute commit: %v", err)
+		}
+		ref, _ := refs.ReadRef(testDir, "refs/heads/main")
+		com, err := commit.Read(repo.Objects, ref.Target)
+		if err != nil {
+			t.Fatalf("Failed to read commit: %v", err)
+		}
+		if com.Author != "Env Author <config@example.com>" || com.Committer != "Config User <config@example.com>" {
+			t.Errorf("Author %q, committer %q; want them from the environment and config", com.Author, com.Committer)
+		}
+
+		if err := NewTagCommand(testDir, "v1", "", "release", "", "create").Execute(); err != nil {
+			t.Fatalf("Failed to create tag: %v", err)
+		}
+		tagRef, _ := refs.ReadRef(testDir, "refs/tags/v1")
+		tg, err := tag.Read(repo.Objects, tagRef.Target)
+		if err != nil {
+			t.Fatalf("Failed to read tag: %v", err)
+		}
+		if tg.Tagger != "Config User <config@example.com>" {
+			t.Errorf("Tagger = %q; want the committer identity", tg.Tagger)
+		}
+	})
 }
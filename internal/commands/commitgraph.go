@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/commitgraph"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+// CommitGraphCommand writes or verifies the commit-graph file.
+type CommitGraphCommand struct {
+	rootPath string
+	action   string
+}
+
+// NewCommitGraphCommand creates a commit-graph command. action is
+// "write", which records every commit reachable from a reference, or
+// "verify", which checks the file against the commit objects.
+func NewCommitGraphCommand(rootPath, action string) *CommitGraphCommand {
+	return &CommitGraphCommand{
+		rootPath: rootPath,
+		action:   action,
+	}
+}
+
+func (c *CommitGraphCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+
+	switch c.action {
+	case "write":
+		count, err := writeCommitGraph(c.rootPath, repo)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote commit-graph with %d commits\n", count)
+
+	case "verify":
+		g, err := commitgraph.Open(repo.ObjectPath(), repo.ObjectFormat)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no commit-graph to verify")
+		}
+		if err != nil {
+			return err
+		}
+		problems := g.Verify(repo.Objects)
+		for _, problem := range problems {
+			fmt.Printf("error: %v\n", problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("commit-graph has %d problems", len(problems))
+		}
+		fmt.Printf("Verified %d commits\n", g.Len())
+
+	default:
+		return fmt.Errorf("unknown commit-graph action: %s", c.action)
+	}
+	return nil
+}
+
+// writeCommitGraph replaces the commit-graph with one covering every
+// commit reachable from the references and HEAD, and returns how many
+// commits it holds.
+func writeCommitGraph(rootPath string, repo *repository.Repository) (int, error) {
+	tips, err := commitTips(rootPath, repo.Objects)
+	if err != nil {
+		return 0, err
+	}
+	commits, err := commitgraph.Build(repo.Objects, tips)
+	if err != nil {
+		return 0, err
+	}
+	if err := commitgraph.Write(repo.ObjectPath(), repo.ObjectFormat, commits); err != nil {
+		return 0, err
+	}
+	return len(commits), nil
+}
+
+// commitTips returns the commits the references and a detached HEAD
+// point at, with annotated tags peeled. Tags of trees and blobs are
+// skipped.
+func commitTips(rootPath string, objects object.ObjectStore) ([]string, error) {
+	names, err := refs.ListRefs(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	names = append(names, refs.HeadFile)
+	var tips []string
+	for _, name := range names {
+		ref, err := refs.ReadRef(rootPath, name)
+		if err != nil {
+			return nil, err
+		}
+		if ref.Type == refs.RefTypeSymbolic || ref.Target == "" {
+			continue
+		}
+		hash, err := peelToCommit(objects, ref.Target)
+		if err != nil {
+			continue
+		}
+		tips = append(tips, hash)
+	}
+	return tips, nil
+}
+
+// commitSource answers tree, parent and generation queries from the
+// commit-graph when the repository has one, and from the commit objects
+// for commits the graph does not cover.
+type commitSource struct {
+	objects object.ObjectStore
+	graph   *commitgraph.Graph
+}
+
+func newCommitSource(gitDir string, objects object.ObjectStore) *commitSource {
+	return &commitSource{
+		objects: objects,
+		graph:   loadCommitGraph(filepath.Join(gitDir, "objects"), objects.Format()),
+	}
+}
+
+// loadCommitGraph returns nil when there is no usable commit-graph. A
+// damaged one is reported and ignored, since every answer it gives can
+// also be read from the objects.
+func loadCommitGraph(objectsPath string, format objectformat.Format) *commitgraph.Graph {
+	g, err := commitgraph.Open(objectsPath, format)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "warning: ignoring commit-graph: %v\n", err)
+		}
+		return nil
+	}
+	return g
+}
+
+func (s *commitSource) lookup(hash string) (*commitgraph.Commit, error) {
+	if c, ok := s.graph.Lookup(hash); ok {
+		return c, nil
+	}
+	com, err := commit.Read(s.objects, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %v", hash, err)
+	}
+	c := &commitgraph.Commit{
+		Hash:       hash,
+		TreeHash:   com.TreeHash,
+		Generation: commitgraph.GenerationInfinity,
+		CommitTime: com.CommitterDate.Unix(),
+		Parents:    com.Parents,
+	}
+	return c, nil
+}
+
+func (s *commitSource) generation(hash string) uint32 {
+	return s.graph.Generation(hash)
+}
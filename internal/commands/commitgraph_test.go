@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/commitgraph"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/tree"
+)
+
+func TestCommitGraphCommand(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDir := filepath.Join(cwd, "testdata")
+
+	// setup builds main as base, m1, m2 and topic as base, t1, and returns
+	// the commits by name.
+	setup := func(t *testing.T) (*repository.Repository, map[string]string) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		repo, err := repository.Init(testDir)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		emptyTree, _ := tree.New().Write(repo.Objects)
+		commits := make(map[string]string)
+		chain := func(names []string, parent string) string {
+			for _, name := range names {
+				var parents []string
+				if parent != "" {
+					parents = []string{parent}
+				}
+				hash, err := NewCommitTreeCommand(testDir, emptyTree, parents, name).Write()
+				if err != nil {
+					t.Fatalf("Failed to create commit %s: %v", name, err)
+				}
+				commits[name] = hash
+				parent = hash
+			}
+			return parent
+		}
+		refs.UpdateRef(testDir, "refs/heads/main", chain([]string{"base", "m1", "m2"}, ""), false)
+		refs.UpdateRef(testDir, "refs/heads/topic", chain([]string{"t1"}, commits["base"]), false)
+		return repo, commits
+	}
+
+	checkQueries := func(t *testing.T, repo *repository.Repository, commits map[string]string) {
+		t.Helper()
+		source := newCommitSource(repo.GitgoDir, repo.Objects)
+		bases, err := source.mergeBases(commits["m2"], commits["t1"])
+		if err != nil || len(bases) != 1 || bases[0] != commits["base"] {
+			t.Errorf("mergeBases = %v, %v; want [%s]", bases, err, commits["base"])
+		}
+		if err := NewMergeBaseCommand(testDir, "main", "topic", false).Execute(); err != nil {
+			t.Errorf("Failed to run merge-base: %v", err)
+		}
+		if err := NewMergeBaseCommand(testDir, commits["m1"], "main", true).Execute(); err != nil {
+			t.Errorf("m1 should be an ancestor of main: %v", err)
+		}
+		if err := NewMergeBaseCommand(testDir, "topic", "main", true).Execute(); err != ErrNotAncestor {
+			t.Errorf("Expected ErrNotAncestor, got %v", err)
+		}
+		if err := NewLogCommand(testDir, -1, "topic", false, false).Execute(); err != nil {
+			t.Errorf("Failed to log: %v", err)
+		}
+		reachable, err := collectReachable(testDir, repo.Objects)
+		if err != nil || len(reachable) != 5 {
+			t.Errorf("Reachable = %d objects, %v; want 5", len(reachable), err)
+		}
+	}
+
+	t.Run("1.1: Queries without a commit-graph", func(t *testing.T) {
+		repo, commits := setup(t)
+		defer os.RemoveAll(testDir)
+		checkQueries(t, repo, commits)
+	})
+
+	t.Run("1.2: Write and verify", func(t *testing.T) {
+		repo, commits := setup(t)
+		defer os.RemoveAll(testDir)
+
+		if err := NewCommitGraphCommand(testDir, "verify").Execute(); err == nil {
+			t.Error("Expected error verifying a missing commit-graph")
+		}
+		if err := NewCommitGraphCommand(testDir, "write").Execute(); err != nil {
+			t.Fatalf("Failed to write commit-graph: %v", err)
+		}
+		if err := NewCommitGraphCommand(testDir, "verify").Execute(); err != nil {
+			t.Errorf("Failed to verify commit-graph: %v", err)
+		}
+		g, err := commitgraph.Open(repo.ObjectPath(), repo.ObjectFormat)
+		if err != nil || g.Len() != 4 {
+			t.Fatalf("Graph = %v, %v; want 4 commits", g, err)
+		}
+		if g.Generation(commits["m2"]) != 3 || g.Generation(commits["t1"]) != 2 {
+			t.Errorf("Unexpected generations for m2 and t1")
+		}
+		checkQueries(t, repo, commits)
+
+		// Commits made after the graph was written are read from objects.
+		emptyTree, _ := tree.New().Write(repo.Objects)
+		m3, _ := NewCommitTreeCommand(testDir, emptyTree, []string{commits["m2"]}, "m3").Write()
+		if err := NewMergeBaseCommand(testDir, commits["base"], m3, true).Execute(); err != nil {
+			t.Errorf("base should be an ancestor of a commit missing from the graph: %v", err)
+		}
+	})
+
+	t.Run("1.3: gc rewrites the commit-graph", func(t *testing.T) {
+		repo, commits := setup(t)
+		defer os.RemoveAll(testDir)
+
+		os.MkdirAll(filepath.Dir(commitgraph.Path(repo.ObjectPath())), 0755)
+		os.WriteFile(commitgraph.Path(repo.ObjectPath()), []byte("garbage"), 0644)
+		checkQueries(t, repo, commits)
+
+		if err := NewGCCommand(testDir, "").Execute(); err != nil {
+			t.Fatalf("Failed to run gc: %v", err)
+		}
+		g, err := commitgraph.Open(repo.ObjectPath(), repo.ObjectFormat)
+		if err != nil || g.Len() != 4 {
+			t.Errorf("Graph after gc = %v, %v; want 4 commits", g, err)
+		}
+	})
+
+	t.Run("1.4: Merge commits", func(t *testing.T) {
+		repo, commits := setup(t)
+		defer os.RemoveAll(testDir)
+
+		emptyTree, _ := tree.New().Write(repo.Objects)
+		merge, err := NewCommitTreeCommand(testDir, emptyTree, []string{commits["m2"], commits["t1"]}, "merge").Write()
+		if err != nil {
+			t.Fatalf("Failed to create merge commit: %v", err)
+		}
+		refs.UpdateRef(testDir, "refs/heads/main", merge, false)
+
+		for _, withGraph := range []bool{false, true} {
+			if withGraph {
+				if err := NewCommitGraphCommand(testDir, "write").Execute(); err != nil {
+					t.Fatalf("Failed to write commit-graph: %v", err)
+				}
+				if err := NewCommitGraphCommand(testDir, "verify").Execute(); err != nil {
+					t.Errorf("Failed to verify commit-graph: %v", err)
+				}
+			}
+			source := newCommitSource(repo.GitgoDir, repo.Objects)
+			node, err := source.lookup(merge)
+			if err != nil || len(node.Parents) != 2 || node.Parents[1] != commits["t1"] {
+				t.Errorf("Merge parents = %+v, %v", node, err)
+			}
+			if ok, _ := source.isAncestor(commits["t1"], merge); !ok {
+				t.Error("t1 should be an ancestor of the merge")
+			}
+			if err := NewLogCommand(testDir, -1, "main", false, false).Execute(); err != nil {
+				t.Errorf("Failed to log: %v", err)
+			}
+		}
+
+		reachable, err := collectReachable(testDir, repo.Objects)
+		if err != nil || len(reachable) != 6 {
+			t.Errorf("Reachable = %d objects, %v; want 6", len(reachable), err)
+		}
+		g, _ := commitgraph.Open(repo.ObjectPath(), repo.ObjectFormat)
+		if g == nil || g.Generation(merge) != 4 {
+			t.Errorf("Merge generation = %d; want 4", g.Generation(merge))
+		}
+	})
+}
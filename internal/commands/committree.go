@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+// CommitTreeCommand creates a commit object for an existing tree and
+// prints its ID without touching any reference.
+type CommitTreeCommand struct {
+	rootPath string
+	tree     string
+	parents  []string
+	message  string
+}
+
+// NewCommitTreeCommand creates a commit-tree command. tree may be a tree
+// or a commit, whose tree is used, and parents are revisions. The author
+// comes from GITGO_AUTHOR_NAME and GITGO_AUTHOR_EMAIL and the committer
+// from GITGO_COMMITTER_NAME and GITGO_COMMITTER_EMAIL, or both from
+// user.name and user.email in the config. GITGO_AUTHOR_DATE and
+// GITGO_COMMITTER_DATE fix the dates.
+func NewCommitTreeCommand(rootPath, tree string, parents []string, message string) *CommitTreeCommand {
+	return &CommitTreeCommand{
+		rootPath: rootPath,
+		tree:     tree,
+		parents:  parents,
+		message:  message,
+	}
+}
+
+func (c *CommitTreeCommand) Execute() error {
+	hash, err := c.Write()
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
+
+// Write stores the commit and returns its ID.
+func (c *CommitTreeCommand) Write() (string, error) {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return "", err
+	}
+	treeHash, err := resolveRevision(c.rootPath, repo, c.tree)
+	if err != nil {
+		return "", err
+	}
+	objectType, _, err := object.ReadHeader(repo.Objects, treeHash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read object %s: %v", treeHash, err)
+	}
+	if objectType != object.TypeTree {
+		commitHash, err := peelToCommit(repo.Objects, treeHash)
+		if err != nil {
+			return "", fmt.Errorf("%s is not a tree: %v", c.tree, err)
+		}
+		com, err := commit.Read(repo.Objects, commitHash)
+		if err != nil {
+			return "", fmt.Errorf("failed to read commit %s: %v", commitHash, err)
+		}
+		treeHash = com.TreeHash
+	}
+
+	var parents []string
+	for _, name := range c.parents {
+		parent, err := resolveCommit(c.rootPath, repo, name)
+		if err != nil {
+			return "", err
+		}
+		parents = append(parents, parent)
+	}
+
+	author, err := authorIdent(repo.GitgoDir)
+	if err != nil {
+		return "", err
+	}
+	com, err := commit.New(treeHash, parents, author, c.message)
+	if err != nil {
+		return "", fmt.Errorf("failed to create commit: %v", err)
+	}
+	if err := stampCommit(repo.GitgoDir, com); err != nil {
+		return "", err
+	}
+	hash, err := com.Write(repo.Objects)
+	if err != nil {
+		return "", fmt.Errorf("failed to write commit: %v", err)
+	}
+	return hash, nil
+}
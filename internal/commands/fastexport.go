@@ -0,0 +1,442 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/blob"
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/tag"
+	"github.com/HalilFocic/gitgo/internal/tree"
+)
+
+type FastExportCommand struct {
+	rootPath    string
+	refNames    []string
+	all         bool
+	importMarks string
+	exportMarks string
+	out         io.Writer
+}
+
+// NewFastExportCommand creates a command that writes the history of
+// refNames, or of every reference with all set, to out as a git
+// fast-import stream. Objects listed in the importMarks file are taken as
+// exported by an earlier run and referred to by their marks; every mark
+// is saved to the exportMarks file afterwards. Either file may be empty.
+func NewFastExportCommand(rootPath string, refNames []string, all bool, importMarks, exportMarks string, out io.Writer) *FastExportCommand {
+	return &FastExportCommand{
+		rootPath:    rootPath,
+		refNames:    refNames,
+		all:         all,
+		importMarks: importMarks,
+		exportMarks: exportMarks,
+		out:         out,
+	}
+}
+
+func (c *FastExportCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	names, err := c.exportedRefs()
+	if err != nil {
+		return err
+	}
+
+	e := &fastExporter{
+		rootPath: c.rootPath,
+		objects:  repo.Objects,
+		out:      bufio.NewWriter(c.out),
+		marks:    make(map[string]int),
+		nextMark: 1,
+	}
+	if c.importMarks != "" {
+		marks, err := readMarks(c.importMarks)
+		if err != nil {
+			return err
+		}
+		for number, hash := range marks {
+			e.marks[hash] = number
+			e.nextMark = max(e.nextMark, number+1)
+		}
+	}
+
+	for _, name := range names {
+		if err := e.exportRef(name); err != nil {
+			return err
+		}
+	}
+	if err := e.out.Flush(); err != nil {
+		return fmt.Errorf("failed to write stream: %v", err)
+	}
+
+	if c.exportMarks != "" {
+		marks := make(map[int]string, len(e.marks))
+		for hash, number := range e.marks {
+			marks[number] = hash
+		}
+		return writeMarks(c.exportMarks, marks)
+	}
+	return nil
+}
+
+// exportedRefs expands the requested names to full reference names. A
+// name is looked up as given, then as a branch and then as a tag; HEAD
+// stands for the branch it points at.
+func (c *FastExportCommand) exportedRefs() ([]string, error) {
+	if c.all {
+		names, err := refs.ListRefs(c.rootPath)
+		if err != nil {
+			return nil, err
+		}
+		var exported []string
+		for _, name := range names {
+			if ref, err := refs.ReadRef(c.rootPath, name); err == nil && ref.Type != refs.RefTypeSymbolic && ref.Target != "" {
+				exported = append(exported, name)
+			}
+		}
+		return exported, nil
+	}
+
+	var exported []string
+	seen := make(map[string]bool)
+	for _, name := range c.refNames {
+		candidates := []string{name, refs.HeadsDir + "/" + name, refs.TagsDir + "/" + name}
+		if name == refs.HeadFile {
+			head, err := refs.ReadHead(c.rootPath)
+			if err != nil {
+				return nil, err
+			}
+			if head.Type == refs.RefTypeSymbolic {
+				candidates = []string{head.Target}
+			}
+		}
+		found := ""
+		for _, candidate := range candidates {
+			if candidate != refs.HeadFile && !strings.HasPrefix(candidate, refs.RefsDir+"/") {
+				continue
+			}
+			if hash, err := readRefTarget(c.rootPath, candidate); err == nil && hash != "" {
+				found = candidate
+				break
+			}
+		}
+		if found == "" {
+			return nil, fmt.Errorf("unknown reference %s", name)
+		}
+		if !seen[found] {
+			seen[found] = true
+			exported = append(exported, found)
+		}
+	}
+	return exported, nil
+}
+
+// fastExporter writes the stream. marks maps every exported object ID to
+// its mark, including those imported from an earlier run.
+type fastExporter struct {
+	rootPath string
+	objects  object.ObjectStore
+	out      *bufio.Writer
+	marks    map[string]int
+	nextMark int
+}
+
+// fileChange is a file command of a commit, relative to its first parent.
+type fileChange struct {
+	path   string
+	mode   int
+	hash   string
+	delete bool
+}
+
+func (e *fastExporter) exportRef(name string) error {
+	hash, err := readRefTarget(e.rootPath, name)
+	if err != nil {
+		return err
+	}
+	objectType, _, err := object.ReadHeader(e.objects, hash)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", name, err)
+	}
+
+	switch objectType {
+	case object.TypeCommit:
+		return e.exportBranch(name, hash)
+	case object.TypeTag:
+		t, err := tag.Read(e.objects, hash)
+		if err != nil {
+			return fmt.Errorf("failed to read tag %s: %v", hash, err)
+		}
+		if t.ObjectType != object.TypeCommit {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s, it tags a %s\n", name, t.ObjectType)
+			return nil
+		}
+		if _, err := e.exportCommits(name, t.Object); err != nil {
+			return err
+		}
+		fmt.Fprintf(e.out, "tag %s\nfrom :%d\n", strings.TrimPrefix(name, refs.TagsDir+"/"), e.marks[t.Object])
+		if t.Tagger != "" {
+			fmt.Fprintf(e.out, "tagger %s\n", commit.FormatIdent(t.Tagger, t.TaggerDate))
+		}
+		e.writeData([]byte(t.Message))
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "warning: skipping %s, it points at a %s\n", name, objectType)
+		return nil
+	}
+}
+
+// exportBranch exports the commits of a branch or lightweight tag. When
+// every commit was exported before, the reference is moved with a reset.
+func (e *fastExporter) exportBranch(name, tip string) error {
+	exported, err := e.exportCommits(name, tip)
+	if err != nil {
+		return err
+	}
+	if !exported {
+		fmt.Fprintf(e.out, "reset %s\nfrom :%d\n\n", name, e.marks[tip])
+	}
+	return nil
+}
+
+// exportCommits writes every commit reachable from tip that has no mark
+// yet, parents before children, and reports whether it wrote any.
+func (e *fastExporter) exportCommits(name, tip string) (bool, error) {
+	commits := make(map[string]*commit.Commit)
+	stack := []string{tip}
+	exported := false
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		if _, ok := e.marks[hash]; ok {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		com, ok := commits[hash]
+		if !ok {
+			var err error
+			if com, err = commit.Read(e.objects, hash); err != nil {
+				return false, fmt.Errorf("failed to read commit %s: %v", hash, err)
+			}
+			commits[hash] = com
+		}
+
+		pending := false
+		for _, parent := range com.Parents {
+			if _, ok := e.marks[parent]; !ok {
+				stack = append(stack, parent)
+				pending = true
+			}
+		}
+		if pending {
+			continue
+		}
+		stack = stack[:len(stack)-1]
+		if err := e.writeCommit(name, hash, com); err != nil {
+			return false, err
+		}
+		delete(commits, hash)
+		exported = true
+	}
+	return exported, nil
+}
+
+func (e *fastExporter) writeCommit(name, hash string, com *commit.Commit) error {
+	parents := com.Parents
+	parentTree := ""
+	if len(parents) > 0 {
+		parent, err := commit.Read(e.objects, parents[0])
+		if err != nil {
+			return fmt.Errorf("failed to read commit %s: %v", parents[0], err)
+		}
+		parentTree = parent.TreeHash
+	}
+	var changes []fileChange
+	if err := e.diffTrees(parentTree, com.TreeHash, "", &changes); err != nil {
+		return err
+	}
+	// Deletions go first so that a file may replace a directory and the
+	// other way round.
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].delete && !changes[j].delete
+	})
+	for _, change := range changes {
+		if change.delete || change.mode == tree.GitlinkMode {
+			continue
+		}
+		if err := e.writeBlob(change.hash); err != nil {
+			return err
+		}
+	}
+
+	if len(parents) == 0 {
+		fmt.Fprintf(e.out, "reset %s\n", name)
+	}
+	mark := e.nextMark
+	e.nextMark++
+	// Commits from older gitgo versions have no committer, and a stream
+	// needs one; the author stands in.
+	committer := com.Committer
+	if committer == "" {
+		committer = com.Author
+	}
+	fmt.Fprintf(e.out, "commit %s\nmark :%d\nauthor %s\ncommitter %s\n", name, mark,
+		commit.FormatIdent(com.Author, com.AuthorDate), commit.FormatIdent(committer, com.CommitterDate))
+	if encoding, ok := com.Header("encoding"); ok {
+		fmt.Fprintf(e.out, "encoding %s\n", encoding)
+	}
+	e.writeData([]byte(com.Message))
+	for i, parent := range parents {
+		command := "from"
+		if i > 0 {
+			command = "merge"
+		}
+		fmt.Fprintf(e.out, "%s :%d\n", command, e.marks[parent])
+	}
+	for _, change := range changes {
+		switch {
+		case change.delete:
+			fmt.Fprintf(e.out, "D %s\n", quotePath(change.path))
+		case change.mode == tree.GitlinkMode:
+			fmt.Fprintf(e.out, "M %06o %s %s\n", change.mode, change.hash, quotePath(change.path))
+		default:
+			fmt.Fprintf(e.out, "M %06o :%d %s\n", change.mode, e.marks[change.hash], quotePath(change.path))
+		}
+	}
+	e.out.WriteString("\n")
+	e.marks[hash] = mark
+	return nil
+}
+
+// writeBlob exports a blob unless it already has a mark.
+func (e *fastExporter) writeBlob(hash string) error {
+	if _, ok := e.marks[hash]; ok {
+		return nil
+	}
+	reader, size, err := blob.Open(e.objects, hash)
+	if err != nil {
+		return fmt.Errorf("failed to read blob %s: %v", hash, err)
+	}
+	defer reader.Close()
+
+	mark := e.nextMark
+	e.nextMark++
+	fmt.Fprintf(e.out, "blob\nmark :%d\ndata %d\n", mark, size)
+	if _, err := io.Copy(e.out, reader); err != nil {
+		return fmt.Errorf("failed to read blob %s: %v", hash, err)
+	}
+	e.out.WriteString("\n")
+	e.marks[hash] = mark
+	return nil
+}
+
+func (e *fastExporter) writeData(data []byte) {
+	fmt.Fprintf(e.out, "data %d\n", len(data))
+	e.out.Write(data)
+	e.out.WriteString("\n")
+}
+
+// diffTrees appends the file changes that turn the tree oldHash into the
+// tree newHash. Either may be empty for a missing tree. Subtrees with the
+// same ID are skipped without being read.
+func (e *fastExporter) diffTrees(oldHash, newHash, prefix string, changes *[]fileChange) error {
+	if oldHash == newHash {
+		return nil
+	}
+	oldEntries, err := e.treeEntries(oldHash)
+	if err != nil {
+		return err
+	}
+	newEntries, err := e.treeEntries(newHash)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(oldEntries)+len(newEntries))
+	for name := range oldEntries {
+		names = append(names, name)
+	}
+	for name := range newEntries {
+		if _, ok := oldEntries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := prefix + name
+		oldEntry, inOld := oldEntries[name]
+		newEntry, inNew := newEntries[name]
+		oldIsDir := inOld && oldEntry.Mode == tree.DirectoryMode
+		newIsDir := inNew && newEntry.Mode == tree.DirectoryMode
+
+		switch {
+		case !inNew:
+			*changes = append(*changes, fileChange{path: path, delete: true})
+		case oldIsDir && newIsDir:
+			if err := e.diffTrees(oldEntry.Hash, newEntry.Hash, path+"/", changes); err != nil {
+				return err
+			}
+		case newIsDir:
+			if inOld {
+				*changes = append(*changes, fileChange{path: path, delete: true})
+			}
+			if err := e.diffTrees("", newEntry.Hash, path+"/", changes); err != nil {
+				return err
+			}
+		case !inOld || oldIsDir || oldEntry.Hash != newEntry.Hash || oldEntry.Mode != newEntry.Mode:
+			if oldIsDir {
+				*changes = append(*changes, fileChange{path: path, delete: true})
+			}
+			*changes = append(*changes, fileChange{path: path, mode: newEntry.Mode, hash: newEntry.Hash})
+		}
+	}
+	return nil
+}
+
+func (e *fastExporter) treeEntries(hash string) (map[string]tree.TreeEntry, error) {
+	entries := make(map[string]tree.TreeEntry)
+	if hash == "" {
+		return entries, nil
+	}
+	t, err := tree.Read(e.objects, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree %s: %v", hash, err)
+	}
+	for _, entry := range t.Entries() {
+		entries[entry.Name] = entry
+	}
+	return entries, nil
+}
+
+// quotePath quotes a path for a file command when it contains characters
+// the stream would otherwise misread, using C-style escapes.
+func quotePath(path string) string {
+	if !strings.ContainsAny(path, "\"\\\n") {
+		return path
+	}
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		switch ch := path[i]; ch {
+		case '"', '\\':
+			quoted.WriteByte('\\')
+			quoted.WriteByte(ch)
+		case '\n':
+			quoted.WriteString("\\n")
+		default:
+			quoted.WriteByte(ch)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/blob"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/tree"
+)
+
+// buildTree stores files, keyed by slash-separated path, as blobs and
+// trees and returns the root tree ID.
+func buildTree(t *testing.T, objects object.ObjectStore, files map[string]string) string {
+	t.Helper()
+	root := tree.New()
+	subdirs := make(map[string]map[string]string)
+	for path, content := range files {
+		if dir, rest, ok := strings.Cut(path, "/"); ok {
+			if subdirs[dir] == nil {
+				subdirs[dir] = make(map[string]string)
+			}
+			subdirs[dir][rest] = content
+			continue
+		}
+		b, _ := blob.New([]byte(content))
+		if err := b.Store(objects); err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+		root.AddEntry(path, b.Hash(), tree.RegularFileMode)
+	}
+	for dir, sub := range subdirs {
+		root.AddEntry(dir, buildTree(t, objects, sub), tree.DirectoryMode)
+	}
+	hash, err := root.Write(objects)
+	if err != nil {
+		t.Fatalf("Failed to write tree: %v", err)
+	}
+	return hash
+}
+
+func TestFastExportCommand(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDir := filepath.Join(cwd, "testdata")
+	marksFile := filepath.Join(testDir, "marks")
+
+	setup := func(t *testing.T) (*repository.Repository, []string) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		repo, err := repository.Init(testDir)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		first := buildTree(t, repo.Objects, map[string]string{"a.txt": "one\n", "dir/b.txt": "bee\n"})
+		second := buildTree(t, repo.Objects, map[string]string{"a.txt": "two\n", "dir": "now a file\n"})
+		base, err := NewCommitTreeCommand(testDir, first, nil, "first\n").Write()
+		if err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		next, err := NewCommitTreeCommand(testDir, second, []string{base}, "second\n").Write()
+		if err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		refs.UpdateRef(testDir, "refs/heads/main", next, false)
+		refs.UpdateRef(testDir, "refs/heads/old", base, false)
+		if err := NewTagCommand(testDir, "v1", base, "release\n", "Tagger <t@example.com>", "create").Execute(); err != nil {
+			t.Fatalf("Failed to tag: %v", err)
+		}
+		return repo, []string{base, next}
+	}
+
+	t.Run("1.1: Export all references", func(t *testing.T) {
+		_, commits := setup(t)
+		defer os.RemoveAll(testDir)
+
+		var out bytes.Buffer
+		if err := NewFastExportCommand(testDir, nil, true, "", marksFile, &out).Execute(); err != nil {
+			t.Fatalf("Failed to export: %v", err)
+		}
+		stream := out.String()
+		for _, want := range []string{
+			"blob\nmark :1\ndata 4\none\n\n",
+			"reset refs/heads/main\ncommit refs/heads/main\nmark :3\n",
+			"data 6\nfirst\n\nM 100644 :1 a.txt\nM 100644 :2 dir/b.txt\n\n",
+			"from :3\nD dir\nM 100644 :4 a.txt\nM 100644 :5 dir\n\n",
+			"reset refs/heads/old\nfrom :3\n\n",
+			"tag v1\nfrom :3\ntagger Tagger <t@example.com> ",
+		} {
+			if !strings.Contains(stream, want) {
+				t.Errorf("Stream is missing %q:\n%s", want, stream)
+			}
+		}
+		if strings.Count(stream, "\ncommit ") != 2 || !strings.HasPrefix(stream, "blob\n") {
+			t.Errorf("Commits should be exported once:\n%s", stream)
+		}
+
+		marks, err := readMarks(marksFile)
+		if err != nil || len(marks) != 6 || marks[3] != commits[0] || marks[6] != commits[1] {
+			t.Errorf("Marks = %v, %v", marks, err)
+		}
+	})
+
+	t.Run("1.2: Incremental export", func(t *testing.T) {
+		_, commits := setup(t)
+		defer os.RemoveAll(testDir)
+
+		if err := NewFastExportCommand(testDir, []string{"old"}, false, "", marksFile, &bytes.Buffer{}).Execute(); err != nil {
+			t.Fatalf("Failed to export: %v", err)
+		}
+		var out bytes.Buffer
+		err := NewFastExportCommand(testDir, []string{"main"}, false, marksFile, marksFile, &out).Execute()
+		if err != nil {
+			t.Fatalf("Failed to export: %v", err)
+		}
+		stream := out.String()
+		if strings.Contains(stream, "first") || strings.Contains(stream, "mark :1\n") || !strings.Contains(stream, "from :3\n") {
+			t.Errorf("Incremental export repeated or lost earlier objects:\n%s", stream)
+		}
+		marks, _ := readMarks(marksFile)
+		if len(marks) != 6 || marks[6] != commits[1] {
+			t.Errorf("Marks = %v; want the new commit as :6", marks)
+		}
+
+		out.Reset()
+		NewFastExportCommand(testDir, []string{"main"}, false, marksFile, "", &out).Execute()
+		if out.String() != "reset refs/heads/main\nfrom :6\n\n" {
+			t.Errorf("Export of an exported branch = %q", out.String())
+		}
+		if err := NewFastExportCommand(testDir, []string{"missing"}, false, "", "", &out).Execute(); err == nil {
+			t.Error("Expected error for an unknown reference")
+		}
+	})
+}
@@ -0,0 +1,982 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/tag"
+	"github.com/HalilFocic/gitgo/internal/tree"
+)
+
+type FastImportCommand struct {
+	rootPath    string
+	in          io.Reader
+	importMarks string
+	exportMarks string
+}
+
+// NewFastImportCommand creates a command that reads a git fast-import
+// stream from in and stores its blobs, trees, commits and tags. Marks
+// from the importMarks file can be referred to by the stream, and every
+// mark is saved to the exportMarks file at the end. Either file may be
+// empty, in which case the stream's own import-marks and export-marks
+// features are used.
+func NewFastImportCommand(rootPath string, in io.Reader, importMarks, exportMarks string) *FastImportCommand {
+	return &FastImportCommand{
+		rootPath:    rootPath,
+		in:          in,
+		importMarks: importMarks,
+		exportMarks: exportMarks,
+	}
+}
+
+func (c *FastImportCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	im := &fastImporter{
+		rootPath:    c.rootPath,
+		objects:     repo.Objects,
+		in:          bufio.NewReader(c.in),
+		marks:       make(map[int]string),
+		branches:    make(map[string]*importBranch),
+		updates:     make(map[string]string),
+		exportMarks: c.exportMarks,
+	}
+	if c.importMarks != "" {
+		if err := im.loadMarks(c.importMarks); err != nil {
+			return err
+		}
+		im.marksLoaded = true
+	}
+
+	if err := im.run(); err != nil {
+		return fmt.Errorf("fast-import: line %d: %v", im.line, err)
+	}
+	if err := im.updateRefs(); err != nil {
+		return err
+	}
+	if im.exportMarks != "" {
+		if err := writeMarks(im.exportMarks, im.marks); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Imported %d blobs, %d commits and %d tags, updated %d refs\n",
+		im.blobs, im.commits, im.tags, len(im.updated))
+	return nil
+}
+
+// fastImporter holds the state of one import. Branch tips are written to
+// the references at checkpoints and at the end of the stream.
+type fastImporter struct {
+	rootPath string
+	objects  object.ObjectStore
+	in       *bufio.Reader
+	line     int
+	unread   *string
+
+	marks       map[int]string
+	marksLoaded bool
+	exportMarks string
+	branches    map[string]*importBranch
+	updates     map[string]string
+	updated     map[string]bool
+	// expected holds what each updated reference pointed at before the
+	// import touched it, so that concurrent changes are not overwritten.
+	expected map[string]string
+
+	blobs, commits, tags int
+}
+
+// importBranch is a branch being written by the stream: its last commit
+// and the tree the next commit starts from.
+type importBranch struct {
+	tip  string
+	root *importTree
+}
+
+func (im *fastImporter) run() error {
+	for {
+		line, err := im.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		command, arg, _ := strings.Cut(line, " ")
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case line == "blob":
+			err = im.parseBlob()
+		case command == "commit":
+			err = im.parseCommit(arg)
+		case command == "tag":
+			err = im.parseTag(arg)
+		case command == "reset":
+			err = im.parseReset(arg)
+		case line == "checkpoint":
+			err = im.updateRefs()
+		case command == "progress":
+			fmt.Println(arg)
+		case line == "done":
+			return nil
+		case command == "feature":
+			err = im.parseFeature(arg)
+		case command == "option":
+			// Options only tune git's own importer.
+		default:
+			err = fmt.Errorf("unsupported command %q", line)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (im *fastImporter) readLine() (string, error) {
+	if im.unread != nil {
+		line := *im.unread
+		im.unread = nil
+		return line, nil
+	}
+	line, err := im.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	im.line++
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+// optional returns the argument of the next line when it starts with
+// prefix, and leaves the line to be read again otherwise.
+func (im *fastImporter) optional(prefix string) (string, bool, error) {
+	line, err := im.readLine()
+	if err == io.EOF {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if !strings.HasPrefix(line, prefix) {
+		im.unread = &line
+		return "", false, nil
+	}
+	return strings.TrimPrefix(line, prefix), true, nil
+}
+
+// optionalMark reads a "mark :<n>" line if there is one and returns n, or
+// 0 when the object has no mark.
+func (im *fastImporter) optionalMark() (int, error) {
+	value, ok, err := im.optional("mark ")
+	if err != nil || !ok {
+		return 0, err
+	}
+	number, err := strconv.Atoi(strings.TrimPrefix(value, ":"))
+	if err != nil || !strings.HasPrefix(value, ":") || number <= 0 {
+		return 0, fmt.Errorf("invalid mark %q", value)
+	}
+	return number, nil
+}
+
+// readData reads a data command: either "data <count>" followed by
+// exactly count bytes, or "data <<<delim>" followed by lines up to one
+// holding only delim. A newline after the data is optional.
+func (im *fastImporter) readData() ([]byte, error) {
+	value, ok, err := im.optional("data ")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("expected data")
+	}
+	if delim, ok := strings.CutPrefix(value, "<<"); ok {
+		var data bytes.Buffer
+		for {
+			line, err := im.readLine()
+			if err != nil {
+				return nil, fmt.Errorf("unterminated data, expected %s", delim)
+			}
+			if line == delim {
+				return data.Bytes(), nil
+			}
+			data.WriteString(line)
+			data.WriteByte('\n')
+		}
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid data length %q", value)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(im.in, data); err != nil {
+		return nil, fmt.Errorf("data is shorter than %d bytes", size)
+	}
+	im.line += bytes.Count(data, []byte{'\n'})
+	im.skipNewline()
+	return data, nil
+}
+
+func (im *fastImporter) skipNewline() {
+	if next, err := im.in.Peek(1); err == nil && next[0] == '\n' {
+		im.in.ReadByte()
+		im.line++
+	}
+}
+
+func (im *fastImporter) setMark(number int, hash string) {
+	if number > 0 {
+		im.marks[number] = hash
+	}
+}
+
+func (im *fastImporter) parseBlob() error {
+	mark, err := im.optionalMark()
+	if err != nil {
+		return err
+	}
+	if _, _, err := im.optional("original-oid "); err != nil {
+		return err
+	}
+
+	// Counted data is streamed into the store so large files are never
+	// held in memory.
+	value, ok, err := im.optional("data ")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("expected data")
+	}
+	var hash string
+	if size, err := strconv.ParseInt(value, 10, 64); err == nil && size >= 0 {
+		counter := &lineCounter{reader: io.LimitReader(im.in, size)}
+		hash, err = object.PutFrom(im.objects, object.TypeBlob, counter, size)
+		if err != nil {
+			return fmt.Errorf("failed to store blob: %v", err)
+		}
+		im.line += counter.lines
+		im.skipNewline()
+	} else {
+		line := "data " + value
+		im.unread = &line
+		data, err := im.readData()
+		if err != nil {
+			return err
+		}
+		if hash, err = im.objects.Put(object.TypeBlob, data); err != nil {
+			return fmt.Errorf("failed to store blob: %v", err)
+		}
+	}
+	im.setMark(mark, hash)
+	im.blobs++
+	return nil
+}
+
+// lineCounter counts the newlines read through it, to keep error line
+// numbers right across streamed blobs.
+type lineCounter struct {
+	reader io.Reader
+	lines  int
+}
+
+func (r *lineCounter) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.lines += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
+
+func (im *fastImporter) parseCommit(ref string) error {
+	if ref == "" {
+		return fmt.Errorf("commit needs a reference")
+	}
+	if err := refs.CheckName(ref); err != nil {
+		return err
+	}
+	mark, err := im.optionalMark()
+	if err != nil {
+		return err
+	}
+	if _, _, err := im.optional("original-oid "); err != nil {
+		return err
+	}
+	authorLine, hasAuthor, err := im.optional("author ")
+	if err != nil {
+		return err
+	}
+	committerLine, hasCommitter, err := im.optional("committer ")
+	if err != nil {
+		return err
+	}
+	if !hasCommitter {
+		return fmt.Errorf("commit %s has no committer", ref)
+	}
+	if !hasAuthor {
+		authorLine = committerLine
+	}
+	author, authorDate, err := commit.ParseIdent(authorLine)
+	if err != nil {
+		return err
+	}
+	committer, committerDate, err := commit.ParseIdent(committerLine)
+	if err != nil {
+		return err
+	}
+	encoding, hasEncoding, err := im.optional("encoding ")
+	if err != nil {
+		return err
+	}
+	message, err := im.readData()
+	if err != nil {
+		return err
+	}
+
+	branch := im.branches[ref]
+	from, hasFrom, err := im.optional("from ")
+	if err != nil {
+		return err
+	}
+	if hasFrom {
+		tip, err := im.resolveCommit(from)
+		if err != nil {
+			return err
+		}
+		branch = &importBranch{tip: tip, root: &importTree{}}
+		if tip != "" {
+			if branch.root, err = im.commitTree(tip); err != nil {
+				return err
+			}
+		}
+	} else if branch == nil {
+		branch = &importBranch{root: &importTree{}}
+		if tip, err := readRefTarget(im.rootPath, ref); err == nil && tip != "" {
+			branch.tip = tip
+			if branch.root, err = im.commitTree(tip); err != nil {
+				return err
+			}
+		}
+	}
+	var parents []string
+	if branch.tip != "" {
+		parents = append(parents, branch.tip)
+	}
+	for {
+		merge, ok, err := im.optional("merge ")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		parent, err := im.resolveCommit(merge)
+		if err != nil {
+			return err
+		}
+		if parent != "" && !slices.Contains(parents, parent) {
+			parents = append(parents, parent)
+		}
+	}
+
+	if err := im.parseFileCommands(branch); err != nil {
+		return err
+	}
+	treeHash, err := branch.root.write(im.objects)
+	if err != nil {
+		return err
+	}
+	com := &commit.Commit{
+		TreeHash:      treeHash,
+		Parents:       parents,
+		Author:        author,
+		AuthorDate:    authorDate,
+		Committer:     committer,
+		CommitterDate: committerDate,
+		Message:       string(message),
+	}
+	if hasEncoding {
+		com.ExtraHeaders = []commit.Header{{Name: "encoding", Value: encoding}}
+	}
+	hash, err := com.Write(im.objects)
+	if err != nil {
+		return fmt.Errorf("failed to write commit: %v", err)
+	}
+
+	branch.tip = hash
+	im.branches[ref] = branch
+	im.update(ref, hash)
+	im.setMark(mark, hash)
+	im.commits++
+	return nil
+}
+
+// parseFileCommands applies the M, D, R, C and deleteall commands that
+// follow a commit's header to the branch's tree.
+func (im *fastImporter) parseFileCommands(branch *importBranch) error {
+	for {
+		line, err := im.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		switch {
+		case line == "":
+			return nil
+		case line == "deleteall":
+			branch.root = &importTree{}
+		case command == "M":
+			err = im.parseModify(branch.root, arg)
+		case command == "D":
+			var path string
+			if path, _, err = parsePath(arg, false); err == nil {
+				err = branch.root.remove(im.objects, path)
+			}
+		case command == "R" || command == "C":
+			err = im.parseCopy(branch.root, arg, command == "R")
+		case command == "N":
+			err = fmt.Errorf("notes are not supported")
+		default:
+			im.unread = &line
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (im *fastImporter) parseModify(root *importTree, arg string) error {
+	fields := strings.SplitN(arg, " ", 3)
+	if len(fields) != 3 {
+		return fmt.Errorf("invalid file command M %s", arg)
+	}
+	mode, err := parseImportMode(fields[0])
+	if err != nil {
+		return err
+	}
+	path, _, err := parsePath(fields[2], false)
+	if err != nil {
+		return err
+	}
+
+	var hash string
+	switch dataref := fields[1]; {
+	case dataref == "inline":
+		data, err := im.readData()
+		if err != nil {
+			return err
+		}
+		if hash, err = im.objects.Put(object.TypeBlob, data); err != nil {
+			return fmt.Errorf("failed to store blob: %v", err)
+		}
+		im.blobs++
+	case strings.HasPrefix(dataref, ":"):
+		number, _ := strconv.Atoi(dataref[1:])
+		if hash = im.marks[number]; hash == "" {
+			return fmt.Errorf("unknown mark %s", dataref)
+		}
+	case im.objects.Format().IsValid(dataref):
+		hash = dataref
+	default:
+		return fmt.Errorf("invalid data reference %q", dataref)
+	}
+	if mode != tree.GitlinkMode && !im.objects.Has(hash) {
+		return fmt.Errorf("object %s for %s is missing", hash, path)
+	}
+	return root.set(im.objects, path, &importEntry{mode: mode, hash: hash})
+}
+
+// parseCopy handles "R <source> <dest>" and "C <source> <dest>". An
+// unquoted source path ends at the first space.
+func (im *fastImporter) parseCopy(root *importTree, arg string, rename bool) error {
+	source, rest, err := parsePath(arg, true)
+	if err != nil {
+		return err
+	}
+	dest, _, err := parsePath(rest, false)
+	if err != nil {
+		return err
+	}
+	entry, err := root.get(im.objects, source)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("path %s not in branch", source)
+	}
+	if entry.tree != nil {
+		// Write the subtree so the copy shares it by ID.
+		if _, err := entry.tree.write(im.objects); err != nil {
+			return err
+		}
+	}
+	copied := &importEntry{mode: entry.mode, hash: entry.treeHash()}
+	if rename {
+		if err := root.remove(im.objects, source); err != nil {
+			return err
+		}
+	}
+	return root.set(im.objects, dest, copied)
+}
+
+func (im *fastImporter) parseTag(name string) error {
+	if name == "" {
+		return fmt.Errorf("tag needs a name")
+	}
+	if err := refs.CheckName(refs.TagsDir + "/" + name); err != nil {
+		return err
+	}
+	// Tags may be marked; the mark names the tag object.
+	mark, err := im.optionalMark()
+	if err != nil {
+		return err
+	}
+	from, ok, err := im.optional("from ")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("tag %s has no from", name)
+	}
+	target, err := im.resolveObject(from)
+	if err != nil {
+		return err
+	}
+	objectType, _, err := object.ReadHeader(im.objects, target)
+	if err != nil {
+		return err
+	}
+	if _, _, err := im.optional("original-oid "); err != nil {
+		return err
+	}
+	t := &tag.Tag{Object: target, ObjectType: objectType, Name: name}
+	if tagger, ok, err := im.optional("tagger "); err != nil {
+		return err
+	} else if ok {
+		if t.Tagger, t.TaggerDate, err = commit.ParseIdent(tagger); err != nil {
+			return err
+		}
+	}
+	message, err := im.readData()
+	if err != nil {
+		return err
+	}
+	t.Message = string(message)
+	hash, err := t.Write(im.objects)
+	if err != nil {
+		return fmt.Errorf("failed to write tag: %v", err)
+	}
+	im.update(refs.TagsDir+"/"+name, hash)
+	im.setMark(mark, hash)
+	im.tags++
+	return nil
+}
+
+// parseReset moves a reference to the object in its optional from line,
+// or forgets the branch so the next commit on it starts a new history.
+func (im *fastImporter) parseReset(ref string) error {
+	if ref == "" {
+		return fmt.Errorf("reset needs a reference")
+	}
+	if err := refs.CheckName(ref); err != nil {
+		return err
+	}
+	delete(im.branches, ref)
+	from, ok, err := im.optional("from ")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		im.branches[ref] = &importBranch{root: &importTree{}}
+		return nil
+	}
+	target, err := im.resolveObject(from)
+	if err != nil {
+		return err
+	}
+	im.update(ref, target)
+	if objectType, _, err := object.ReadHeader(im.objects, target); err == nil && objectType == object.TypeCommit {
+		root, err := im.commitTree(target)
+		if err != nil {
+			return err
+		}
+		im.branches[ref] = &importBranch{tip: target, root: root}
+	}
+	return nil
+}
+
+func (im *fastImporter) parseFeature(feature string) error {
+	name, value, _ := strings.Cut(feature, "=")
+	switch name {
+	case "done", "date-format":
+		if name == "date-format" && value != "raw" {
+			return fmt.Errorf("unsupported date format %s", value)
+		}
+	case "import-marks", "import-marks-if-exists":
+		// Marks given on the command line take precedence.
+		if !im.marksLoaded {
+			im.marksLoaded = true
+			return im.loadMarks(value)
+		}
+	case "export-marks":
+		if im.exportMarks == "" {
+			im.exportMarks = value
+		}
+	default:
+		return fmt.Errorf("unsupported feature %s", name)
+	}
+	return nil
+}
+
+func (im *fastImporter) loadMarks(path string) error {
+	marks, err := readMarks(path)
+	if err != nil {
+		return err
+	}
+	for number, hash := range marks {
+		im.marks[number] = hash
+	}
+	return nil
+}
+
+// resolveObject turns a mark, an object ID or the name of a branch of
+// this import or of the repository into an object ID.
+func (im *fastImporter) resolveObject(name string) (string, error) {
+	if number, ok := strings.CutPrefix(name, ":"); ok {
+		n, _ := strconv.Atoi(number)
+		if hash := im.marks[n]; hash != "" {
+			return hash, nil
+		}
+		return "", fmt.Errorf("unknown mark %s", name)
+	}
+	if im.objects.Format().IsValid(name) {
+		return name, nil
+	}
+	if branch, ok := im.branches[name]; ok && branch.tip != "" {
+		return branch.tip, nil
+	}
+	for _, candidate := range []string{name, refs.HeadsDir + "/" + name} {
+		if hash, err := readRefTarget(im.rootPath, candidate); err == nil && hash != "" {
+			return hash, nil
+		}
+	}
+	return "", fmt.Errorf("unknown object %s", name)
+}
+
+// resolveCommit resolves a from line of a commit. The all-zero ID starts
+// a branch without a parent and resolves to "".
+func (im *fastImporter) resolveCommit(name string) (string, error) {
+	if name == strings.Repeat("0", im.objects.Format().HexSize()) {
+		return "", nil
+	}
+	hash, err := im.resolveObject(name)
+	if err != nil {
+		return "", err
+	}
+	return peelToCommit(im.objects, hash)
+}
+
+func (im *fastImporter) commitTree(hash string) (*importTree, error) {
+	com, err := commit.Read(im.objects, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %v", hash, err)
+	}
+	return &importTree{hash: com.TreeHash}, nil
+}
+
+// update queues name to point at target at the next checkpoint. The
+// first time a reference is touched its current target is remembered.
+func (im *fastImporter) update(name, target string) {
+	if im.expected == nil {
+		im.expected = make(map[string]string)
+	}
+	if _, ok := im.expected[name]; !ok {
+		current := ""
+		if ref, err := refs.ReadRef(im.rootPath, name); err == nil {
+			current = ref.Target
+		}
+		im.expected[name] = current
+	}
+	im.updates[name] = target
+}
+
+// updateRefs points every reference the stream changed at its new
+// target, failing for references someone else moved in the meantime.
+func (im *fastImporter) updateRefs() error {
+	if im.updated == nil {
+		im.updated = make(map[string]bool)
+	}
+	names := make([]string, 0, len(im.updates))
+	for name := range im.updates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target := im.updates[name]
+		if target != im.expected[name] {
+			if err := refs.CompareAndSwapRef(im.rootPath, name, im.expected[name], target); err != nil {
+				return err
+			}
+		}
+		im.expected[name] = target
+		im.updated[name] = true
+	}
+	im.updates = make(map[string]string)
+	return nil
+}
+
+// importTree is a directory of a branch being imported. Directories are
+// read from their tree object only when a file command reaches into them,
+// and hash is cleared whenever something below changes.
+type importTree struct {
+	hash    string
+	entries map[string]*importEntry
+}
+
+type importEntry struct {
+	mode int
+	hash string
+	tree *importTree
+}
+
+// treeHash returns the ID of the entry's blob or tree as last written.
+func (e *importEntry) treeHash() string {
+	if e.tree != nil {
+		return e.tree.hash
+	}
+	return e.hash
+}
+
+func (t *importTree) load(objects object.ObjectStore) error {
+	if t.entries != nil {
+		return nil
+	}
+	t.entries = make(map[string]*importEntry)
+	if t.hash == "" {
+		return nil
+	}
+	stored, err := tree.Read(objects, t.hash)
+	if err != nil {
+		return fmt.Errorf("failed to read tree %s: %v", t.hash, err)
+	}
+	for _, entry := range stored.Entries() {
+		e := &importEntry{mode: entry.Mode, hash: entry.Hash}
+		if entry.Mode == tree.DirectoryMode {
+			e.tree = &importTree{hash: entry.Hash}
+		}
+		t.entries[entry.Name] = e
+	}
+	return nil
+}
+
+// dir returns the directory holding path and the last path component.
+// Missing directories are created when create is set, replacing files in
+// the way, and every directory on the way is marked changed.
+func (t *importTree) dir(objects object.ObjectStore, path string, create bool) (*importTree, string, error) {
+	parts := strings.Split(path, "/")
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return nil, "", fmt.Errorf("invalid path %q", path)
+		}
+	}
+	current := t
+	for _, part := range parts[:len(parts)-1] {
+		if err := current.load(objects); err != nil {
+			return nil, "", err
+		}
+		if create {
+			current.hash = ""
+		}
+		entry := current.entries[part]
+		if entry == nil || entry.tree == nil {
+			if !create {
+				return nil, "", nil
+			}
+			entry = &importEntry{mode: tree.DirectoryMode, tree: &importTree{entries: make(map[string]*importEntry)}}
+			current.entries[part] = entry
+		}
+		current = entry.tree
+	}
+	if err := current.load(objects); err != nil {
+		return nil, "", err
+	}
+	if create {
+		current.hash = ""
+	}
+	return current, parts[len(parts)-1], nil
+}
+
+func (t *importTree) set(objects object.ObjectStore, path string, entry *importEntry) error {
+	dir, name, err := t.dir(objects, path, true)
+	if err != nil {
+		return err
+	}
+	if entry.mode == tree.DirectoryMode {
+		entry.tree = &importTree{hash: entry.hash}
+	}
+	dir.entries[name] = entry
+	return nil
+}
+
+func (t *importTree) get(objects object.ObjectStore, path string) (*importEntry, error) {
+	dir, name, err := t.dir(objects, path, false)
+	if err != nil || dir == nil {
+		return nil, err
+	}
+	return dir.entries[name], nil
+}
+
+// remove deletes path and then every directory it leaves empty. Removing
+// a path that does not exist is not an error.
+func (t *importTree) remove(objects object.ObjectStore, path string) error {
+	if entry, err := t.get(objects, path); err != nil || entry == nil {
+		return err
+	}
+	parts := strings.Split(path, "/")
+	for end := len(parts); end > 0; end-- {
+		dir, name, err := t.dir(objects, strings.Join(parts[:end], "/"), true)
+		if err != nil {
+			return err
+		}
+		entry := dir.entries[name]
+		if end < len(parts) && entry.tree != nil && len(entry.tree.entries) > 0 {
+			break
+		}
+		delete(dir.entries, name)
+	}
+	return nil
+}
+
+// write stores the tree and every changed directory below it and returns
+// its ID. Empty directories are left out, as git cannot record them.
+func (t *importTree) write(objects object.ObjectStore) (string, error) {
+	if t.hash != "" {
+		return t.hash, nil
+	}
+	if err := t.load(objects); err != nil {
+		return "", err
+	}
+	stored := tree.New()
+	for name, entry := range t.entries {
+		hash := entry.hash
+		if entry.tree != nil {
+			var err error
+			if hash, err = entry.tree.write(objects); err != nil {
+				return "", err
+			}
+			if hash == emptyTreeHash(objects) {
+				continue
+			}
+		}
+		if err := stored.AddEntry(name, hash, entry.mode); err != nil {
+			return "", fmt.Errorf("failed to add %s: %v", name, err)
+		}
+	}
+	hash, err := stored.Write(objects)
+	if err != nil {
+		return "", fmt.Errorf("failed to write tree: %v", err)
+	}
+	t.hash = hash
+	return hash, nil
+}
+
+func emptyTreeHash(objects object.ObjectStore) string {
+	return object.Hash(objects.Format(), object.TypeTree, nil)
+}
+
+func parseImportMode(value string) (int, error) {
+	switch value {
+	case "644", "100644":
+		return tree.RegularFileMode, nil
+	case "755", "100755":
+		return tree.ExecutableMode, nil
+	case "120000":
+		return tree.SymlinkMode, nil
+	case "160000":
+		return tree.GitlinkMode, nil
+	case "040000", "40000":
+		return tree.DirectoryMode, nil
+	}
+	return 0, fmt.Errorf("invalid mode %s", value)
+}
+
+// parsePath reads a path of a file command, either C-style quoted or
+// plain, and returns the rest of the line after it. With untilSpace set a
+// plain path ends at the first space instead of the end of the line.
+func parsePath(s string, untilSpace bool) (string, string, error) {
+	if !strings.HasPrefix(s, "\"") {
+		if untilSpace {
+			path, rest, ok := strings.Cut(s, " ")
+			if !ok {
+				return "", "", fmt.Errorf("missing destination path in %q", s)
+			}
+			return path, rest, nil
+		}
+		if s == "" {
+			return "", "", fmt.Errorf("missing path")
+		}
+		return s, "", nil
+	}
+
+	var path []byte
+	for i := 1; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case '"':
+			return string(path), strings.TrimPrefix(s[i+1:], " "), nil
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", fmt.Errorf("invalid quoted path %s", s)
+			}
+			switch esc := s[i]; esc {
+			case 'a':
+				path = append(path, '\a')
+			case 'b':
+				path = append(path, '\b')
+			case 'f':
+				path = append(path, '\f')
+			case 'n':
+				path = append(path, '\n')
+			case 'r':
+				path = append(path, '\r')
+			case 't':
+				path = append(path, '\t')
+			case 'v':
+				path = append(path, '\v')
+			case '0', '1', '2', '3':
+				if i+2 >= len(s) {
+					return "", "", fmt.Errorf("invalid quoted path %s", s)
+				}
+				value, err := strconv.ParseUint(s[i:i+3], 8, 8)
+				if err != nil {
+					return "", "", fmt.Errorf("invalid quoted path %s", s)
+				}
+				path = append(path, byte(value))
+				i += 2
+			default:
+				path = append(path, esc)
+			}
+		default:
+			path = append(path, ch)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted path %s", s)
+}
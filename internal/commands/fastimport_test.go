@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+func TestFastImportCommand(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDir := filepath.Join(cwd, "testdata")
+	otherDir := filepath.Join(cwd, "testdata-import")
+	marksFile := filepath.Join(cwd, "testdata-marks")
+
+	setup := func(t *testing.T, path string) *repository.Repository {
+		os.RemoveAll(path)
+		os.MkdirAll(path, 0755)
+		repo, err := repository.Init(path)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		return repo
+	}
+
+	t.Run("1.1: Import a stream", func(t *testing.T) {
+		repo := setup(t, testDir)
+		defer os.RemoveAll(testDir)
+		defer os.Remove(marksFile)
+
+		stream := "blob\nmark :1\ndata 4\none\n\n" +
+			"commit refs/heads/main\nmark :2\n" +
+			"committer C <c@example.com> 1700000000 +0100\n" +
+			"data 6\nfirst\n" +
+			"M 100644 :1 a.txt\nM 100644 :1 dir/b.txt\nM 100644 inline \"sp ace\\\"q\"\ndata <<EOF\ninline\nEOF\n\n" +
+			"commit refs/heads/main\nmark :3\n" +
+			"committer C <c@example.com> 1700000100 +0100\n" +
+			"data 7\nsecond\n" +
+			"R dir moved\nC a.txt copy.txt\nD a.txt\n\n" +
+			"reset refs/heads/empty\nfrom :2\n\n" +
+			"commit refs/heads/empty\n" +
+			"committer C <c@example.com> 1700000200 +0100\n" +
+			"data 6\nempty\ndeleteall\n\n" +
+			"tag v1\nfrom :2\ntagger T <t@example.com> 1700000000 +0000\ndata 8\nrelease\n"
+		err := NewFastImportCommand(testDir, strings.NewReader(stream), "", marksFile).Execute()
+		if err != nil {
+			t.Fatalf("Failed to import: %v", err)
+		}
+
+		marks, err := readMarks(marksFile)
+		if err != nil || len(marks) != 3 {
+			t.Fatalf("Marks = %v, %v", marks, err)
+		}
+		main, _ := refs.ReadRef(testDir, "refs/heads/main")
+		if main.Target != marks[3] {
+			t.Errorf("main = %s; want mark :3 %s", main.Target, marks[3])
+		}
+		want := buildTree(t, repo.Objects, map[string]string{"copy.txt": "one\n", "moved/b.txt": "one\n", "sp ace\"q": "inline\n"})
+		data, _ := repo.Objects.Get(marks[3])
+		second, err := commit.Parse(data.Data)
+		if err != nil || second.TreeHash != want || second.Message != "second\n" {
+			t.Errorf("Second commit = %+v, %v; want tree %s", second, err, want)
+		}
+
+		empty, _ := refs.ReadRef(testDir, "refs/heads/empty")
+		data, _ = repo.Objects.Get(empty.Target)
+		deleted, _ := commit.Parse(data.Data)
+		if deleted == nil || deleted.TreeHash != emptyTreeHash(repo.Objects) {
+			t.Errorf("deleteall commit = %+v; want the empty tree", deleted)
+		}
+		if tag, _ := refs.ReadRef(testDir, "refs/tags/v1"); tag.Target == "" {
+			t.Error("Tag v1 was not created")
+		}
+	})
+
+	t.Run("1.2: Round trip through fast-export", func(t *testing.T) {
+		repo := setup(t, testDir)
+		defer os.RemoveAll(testDir)
+		setup(t, otherDir)
+		defer os.RemoveAll(otherDir)
+
+		first := buildTree(t, repo.Objects, map[string]string{"a.txt": "one\n", "dir/b.txt": "bee\n"})
+		second := buildTree(t, repo.Objects, map[string]string{"a.txt": "two\n", "dir": "now a file\n"})
+		base, _ := NewCommitTreeCommand(testDir, first, nil, "first\n").Write()
+		next, _ := NewCommitTreeCommand(testDir, second, []string{base}, "second\n").Write()
+		refs.UpdateRef(testDir, "refs/heads/main", next, false)
+
+		var out bytes.Buffer
+		if err := NewFastExportCommand(testDir, nil, true, "", "", &out).Execute(); err != nil {
+			t.Fatalf("Failed to export: %v", err)
+		}
+		if err := NewFastImportCommand(otherDir, &out, "", "").Execute(); err != nil {
+			t.Fatalf("Failed to import: %v", err)
+		}
+		main, _ := refs.ReadRef(otherDir, "refs/heads/main")
+		if main.Target != next {
+			t.Errorf("Imported main = %s; want %s", main.Target, next)
+		}
+	})
+
+	t.Run("1.3: Reject invalid streams", func(t *testing.T) {
+		setup(t, testDir)
+		defer os.RemoveAll(testDir)
+
+		for _, stream := range []string{
+			"commit refs/heads/main\ncommitter C <c@example.com> 1 +0000\ndata 2\nx\nM 100644 :7 a\n\n",
+			"commit refs/heads/main\ndata 2\nx\n\n",
+			"blob\ndata 10\nshort",
+			"bogus\n",
+		} {
+			if err := NewFastImportCommand(testDir, strings.NewReader(stream), "", "").Execute(); err == nil {
+				t.Errorf("Expected error for stream %q", stream)
+			}
+		}
+		if ref, _ := refs.ReadRef(testDir, "refs/heads/main"); ref.Target != "" {
+			t.Error("A failed import should not update references")
+		}
+	})
+
+	t.Run("1.4: Merge commits round trip", func(t *testing.T) {
+		repo := setup(t, testDir)
+		defer os.RemoveAll(testDir)
+		setup(t, otherDir)
+		defer os.RemoveAll(otherDir)
+
+		base := buildTree(t, repo.Objects, map[string]string{"a.txt": "one\n"})
+		side := buildTree(t, repo.Objects, map[string]string{"a.txt": "one\n", "b.txt": "bee\n"})
+		root, _ := NewCommitTreeCommand(testDir, base, nil, "root\n").Write()
+		left, _ := NewCommitTreeCommand(testDir, base, []string{root}, "left\n").Write()
+		right, _ := NewCommitTreeCommand(testDir, side, []string{root}, "right\n").Write()
+		merge, err := NewCommitTreeCommand(testDir, side, []string{left, right}, "merge\n").Write()
+		if err != nil {
+			t.Fatalf("Failed to create merge commit: %v", err)
+		}
+		refs.UpdateRef(testDir, "refs/heads/main", merge, false)
+
+		var out bytes.Buffer
+		if err := NewFastExportCommand(testDir, []string{"main"}, false, "", "", &out).Execute(); err != nil {
+			t.Fatalf("Failed to export: %v", err)
+		}
+		if !strings.Contains(out.String(), "\nmerge :") {
+			t.Errorf("Export has no merge line:\n%s", out.String())
+		}
+		if err := NewFastImportCommand(otherDir, &out, "", "").Execute(); err != nil {
+			t.Fatalf("Failed to import: %v", err)
+		}
+		if main, _ := refs.ReadRef(otherDir, "refs/heads/main"); main.Target != merge {
+			t.Errorf("Imported main = %s; want %s", main.Target, merge)
+		}
+	})
+
+	t.Run("1.5: Reject unsafe reference names", func(t *testing.T) {
+		repo := setup(t, testDir)
+		defer os.RemoveAll(testDir)
+		configPath := filepath.Join(repo.GitgoDir, "config")
+		config, _ := os.ReadFile(configPath)
+
+		body := "committer C <c@example.com> 1 +0000\ndata 2\nx\n\n"
+		for _, stream := range []string{
+			"commit ../../hooks/post-checkout\n" + body,
+			"commit HEAD\n" + body,
+			"commit refs/heads/main\n" + body + "reset config\nfrom refs/heads/main\n",
+			"commit refs/heads/main\n" + body + "tag ../../../config\nfrom refs/heads/main\ndata 2\nx\n",
+		} {
+			if err := NewFastImportCommand(testDir, strings.NewReader(stream), "", "").Execute(); err == nil {
+				t.Errorf("Expected error for stream %q", stream)
+			}
+		}
+		// The first stream would land two levels above the git directory.
+		escaped := filepath.Join(filepath.Dir(testDir), "hooks")
+		defer os.RemoveAll(escaped)
+		if _, err := os.Stat(escaped); err == nil {
+			t.Errorf("Import wrote %s", escaped)
+		}
+		if after, _ := os.ReadFile(configPath); string(after) != string(config) {
+			t.Errorf("Import overwrote the config with %q", after)
+		}
+		if head, _ := refs.ReadHead(testDir); head.Type != refs.RefTypeSymbolic {
+			t.Errorf("HEAD = %+v; want it left symbolic", head)
+		}
+	})
+
+	t.Run("1.6: Identities and encoding are kept exactly", func(t *testing.T) {
+		repo := setup(t, testDir)
+		defer os.RemoveAll(testDir)
+		setup(t, otherDir)
+		defer os.RemoveAll(otherDir)
+
+		stream := "commit refs/heads/main\n" +
+			"author A  B <a@example.com> 1700000000 -0000\n" +
+			"committer C <c@example.com> 1700000000 +0530\n" +
+			"encoding ISO-8859-1\n" +
+			"data 2\nx\n\n"
+		if err := NewFastImportCommand(testDir, strings.NewReader(stream), "", "").Execute(); err != nil {
+			t.Fatalf("Failed to import: %v", err)
+		}
+		main, _ := refs.ReadRef(testDir, "refs/heads/main")
+		obj, err := repo.Objects.Get(main.Target)
+		if err != nil {
+			t.Fatalf("Failed to read commit: %v", err)
+		}
+		want := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+			"author A  B <a@example.com> 1700000000 -0000\n" +
+			"committer C <c@example.com> 1700000000 +0530\n" +
+			"encoding ISO-8859-1\n\nx\n"
+		if string(obj.Data) != want {
+			t.Errorf("Commit = %q; want %q", obj.Data, want)
+		}
+
+		var out bytes.Buffer
+		if err := NewFastExportCommand(testDir, nil, true, "", "", &out).Execute(); err != nil {
+			t.Fatalf("Failed to export: %v", err)
+		}
+		if err := NewFastImportCommand(otherDir, &out, "", "").Execute(); err != nil {
+			t.Fatalf("Failed to import: %v", err)
+		}
+		if other, _ := refs.ReadRef(otherDir, "refs/heads/main"); other.Target != main.Target {
+			t.Errorf("Round trip gave %s; want %s", other.Target, main.Target)
+		}
+	})
+}
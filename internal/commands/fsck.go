@@ -0,0 +1,297 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/staging"
+	"github.com/HalilFocic/gitgo/internal/tag"
+	"github.com/HalilFocic/gitgo/internal/tree"
+)
+
+// ErrFsckFailed is returned by FsckCommand when corrupt or missing
+// objects, or broken references, were found.
+var ErrFsckFailed = errors.New("fsck found problems")
+
+type FsckCommand struct {
+	rootPath string
+	json     bool
+}
+
+// FsckReport lists every problem fsck found. Dangling objects are
+// reported but do not make the check fail.
+type FsckReport struct {
+	Checked  int           `json:"checked"`
+	Corrupt  []FsckProblem `json:"corrupt"`
+	Missing  []FsckProblem `json:"missing"`
+	BadRefs  []FsckProblem `json:"badRefs"`
+	Dangling []FsckProblem `json:"dangling"`
+}
+
+type FsckProblem struct {
+	Hash  string `json:"hash"`
+	Type  string `json:"type,omitempty"`
+	Ref   string `json:"ref,omitempty"`
+	From  string `json:"from,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// fsckLink is a reference from one object to another.
+type fsckLink struct {
+	hash       string
+	objectType string
+}
+
+// NewFsckCommand creates a command that verifies every stored object and
+// the links between them. With asJSON set the report is printed as JSON.
+func NewFsckCommand(rootPath string, asJSON bool) *FsckCommand {
+	return &FsckCommand{
+		rootPath: rootPath,
+		json:     asJSON,
+	}
+}
+
+func (c *FsckCommand) Execute() error {
+	report, err := c.Check()
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range report.Corrupt {
+			fmt.Printf("corrupt %s: %s\n", p.Hash, p.Error)
+		}
+		for _, p := range report.Missing {
+			fmt.Printf("missing %s %s (referenced by %s)\n", p.Type, p.Hash, p.From)
+		}
+		for _, p := range report.BadRefs {
+			fmt.Printf("bad ref %s: %s\n", p.Ref, p.Error)
+		}
+		for _, p := range report.Dangling {
+			fmt.Printf("dangling %s %s\n", p.Type, p.Hash)
+		}
+		fmt.Printf("Checked %d objects: %d corrupt, %d missing, %d bad refs, %d dangling\n",
+			report.Checked, len(report.Corrupt), len(report.Missing), len(report.BadRefs), len(report.Dangling))
+	}
+
+	if len(report.Corrupt) > 0 || len(report.Missing) > 0 || len(report.BadRefs) > 0 {
+		return ErrFsckFailed
+	}
+	return nil
+}
+
+// Check runs every verification and returns the report without printing
+// it.
+func (c *FsckCommand) Check() (*FsckReport, error) {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return nil, err
+	}
+	store := repo.Objects
+
+	var hashes []string
+	stored := make(map[string]bool)
+	err = store.Iterate(func(hash string) error {
+		hashes = append(hashes, hash)
+		stored[hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %v", err)
+	}
+	sort.Strings(hashes)
+	// Objects borrowed from alternates are checked by the repository that
+	// stores them, but links to them are not missing.
+	present := func(hash string) bool {
+		return stored[hash] || store.Has(hash)
+	}
+
+	report := &FsckReport{
+		Checked:  len(hashes),
+		Corrupt:  []FsckProblem{},
+		Missing:  []FsckProblem{},
+		BadRefs:  []FsckProblem{},
+		Dangling: []FsckProblem{},
+	}
+	types := make(map[string]string)
+	referenced := make(map[string]bool)
+	linksFrom := make(map[string][]fsckLink)
+
+	for _, hash := range hashes {
+		objectType, links, err := verifyObject(store, hash)
+		if err != nil {
+			report.Corrupt = append(report.Corrupt, FsckProblem{Hash: hash, Error: err.Error()})
+			continue
+		}
+		types[hash] = objectType
+		linksFrom[hash] = links
+		for _, link := range links {
+			referenced[link.hash] = true
+			if !present(link.hash) {
+				report.Missing = append(report.Missing, FsckProblem{
+					Hash: link.hash,
+					Type: link.objectType,
+					From: hash,
+				})
+			}
+		}
+	}
+
+	// A link names the type it expects, which only the object at the
+	// other end can confirm once every object has been read.
+	for _, hash := range hashes {
+		for _, link := range linksFrom[hash] {
+			if linkedType, ok := types[link.hash]; ok && linkedType != link.objectType {
+				report.Corrupt = append(report.Corrupt, FsckProblem{
+					Hash:  hash,
+					Type:  types[hash],
+					Error: fmt.Sprintf("links to %s as a %s, but it is a %s", link.hash, link.objectType, linkedType),
+				})
+			}
+		}
+	}
+
+	names, err := refs.ListRefs(c.rootPath)
+	if err != nil {
+		return nil, err
+	}
+	names = append(names, refs.HeadFile)
+	for _, name := range names {
+		ref, err := refs.ReadRef(c.rootPath, name)
+		if err != nil {
+			report.BadRefs = append(report.BadRefs, FsckProblem{Ref: name, Error: err.Error()})
+			continue
+		}
+		if ref.Type == refs.RefTypeSymbolic || ref.Target == "" {
+			continue
+		}
+		referenced[ref.Target] = true
+		switch objectType, ok := types[ref.Target]; {
+		case !present(ref.Target):
+			report.BadRefs = append(report.BadRefs, FsckProblem{
+				Hash:  ref.Target,
+				Ref:   name,
+				Error: fmt.Sprintf("points at missing object %s", ref.Target),
+			})
+		case ok && objectType != object.TypeCommit && !strings.HasPrefix(name, refs.TagsDir+"/"):
+			report.BadRefs = append(report.BadRefs, FsckProblem{
+				Hash:  ref.Target,
+				Ref:   name,
+				Error: fmt.Sprintf("points at a %s, not a commit", objectType),
+			})
+		}
+	}
+
+	// Commits a reflog still remembers are not dangling, even when no
+	// reference reaches them any more.
+	logged, err := refs.ReflogHashes(c.rootPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range logged {
+		referenced[hash] = true
+	}
+
+	index, err := staging.New(c.rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staging area: %v", err)
+	}
+	for _, entry := range index.Entries() {
+		referenced[entry.Hash] = true
+		if !present(entry.Hash) {
+			report.Missing = append(report.Missing, FsckProblem{
+				Hash: entry.Hash,
+				Type: object.TypeBlob,
+				From: "index",
+			})
+		}
+	}
+
+	for _, hash := range hashes {
+		if objectType, ok := types[hash]; ok && !referenced[hash] {
+			report.Dangling = append(report.Dangling, FsckProblem{Hash: hash, Type: objectType})
+		}
+	}
+	return report, nil
+}
+
+// verifyObject checks the header, length and hash of a stored object and
+// that trees, commits and tags parse, and returns the objects it links to.
+// Blobs are hashed as they stream so large files are not loaded whole.
+func verifyObject(store object.ObjectStore, hash string) (string, []fsckLink, error) {
+	objectType, size, reader, err := object.Open(store, hash)
+	if err != nil {
+		return "", nil, err
+	}
+	defer reader.Close()
+
+	hasher := store.Format().New()
+	fmt.Fprintf(hasher, "%s %d\x00", objectType, size)
+	var content bytes.Buffer
+	var sink io.Writer = hasher
+	if objectType != object.TypeBlob {
+		sink = io.MultiWriter(hasher, &content)
+	}
+	n, err := io.Copy(sink, reader)
+	if err != nil {
+		return "", nil, err
+	}
+	if n != size {
+		return "", nil, fmt.Errorf("content length mismatch: expected %d, got %d", size, n)
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != hash {
+		return "", nil, fmt.Errorf("hash mismatch, content hashes to %s", got)
+	}
+
+	var links []fsckLink
+	switch objectType {
+	case object.TypeTree:
+		t, err := tree.Parse(content.Bytes(), store.Format())
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid tree: %v", err)
+		}
+		for _, entry := range t.Entries() {
+			switch entry.Mode {
+			case tree.DirectoryMode:
+				links = append(links, fsckLink{entry.Hash, object.TypeTree})
+			case tree.GitlinkMode:
+				// Submodule commits live in another repository.
+			default:
+				links = append(links, fsckLink{entry.Hash, object.TypeBlob})
+			}
+		}
+	case object.TypeCommit:
+		com, err := commit.Parse(content.Bytes())
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid commit: %v", err)
+		}
+		links = append(links, fsckLink{com.TreeHash, object.TypeTree})
+		for _, parent := range com.Parents {
+			links = append(links, fsckLink{parent, object.TypeCommit})
+		}
+	case object.TypeTag:
+		t, err := tag.Parse(content.Bytes())
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid tag: %v", err)
+		}
+		links = append(links, fsckLink{t.Object, t.ObjectType})
+	}
+	return objectType, links, nil
+}
@@ -0,0 +1,224 @@
+package commands
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/blob"
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/staging"
+	"github.com/HalilFocic/gitgo/internal/tree"
+)
+
+func TestFsckCommand(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDir := filepath.Join(cwd, "testdata")
+
+	setup := func(t *testing.T) *repository.Repository {
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		repo, err := repository.Init(testDir)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		os.WriteFile(filepath.Join(testDir, "main.go"), []byte("main content"), 0644)
+		idx, err := staging.New(testDir)
+		if err != nil {
+			t.Fatalf("Failed to create staging area: %v", err)
+		}
+		if err := idx.Add("main.go"); err != nil {
+			t.Fatalf("Failed to stage file: %v", err)
+		}
+		if err := NewCommitCommand(testDir, "first", "Test User <test@example.com>", false).Execute(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		return repo
+	}
+
+	t.Run("1.1: Healthy repository", func(t *testing.T) {
+		setup(t)
+		defer os.RemoveAll(testDir)
+
+		report, err := NewFsckCommand(testDir, false).Check()
+		if err != nil {
+			t.Fatalf("Failed to run fsck: %v", err)
+		}
+		if report.Checked != 3 {
+			t.Errorf("Checked %d objects; want 3", report.Checked)
+		}
+		if len(report.Corrupt)+len(report.Missing)+len(report.BadRefs)+len(report.Dangling) != 0 {
+			t.Errorf("Unexpected problems: %+v", report)
+		}
+		if err := NewFsckCommand(testDir, true).Execute(); err != nil {
+			t.Errorf("Expected fsck to pass: %v", err)
+		}
+	})
+
+	t.Run("1.2: Corrupt, missing, dangling and bad refs", func(t *testing.T) {
+		repo := setup(t)
+		defer os.RemoveAll(testDir)
+
+		head, _ := refs.ReadRef(testDir, "refs/heads/main")
+		com, err := commit.Read(repo.Objects, head.Target)
+		if err != nil {
+			t.Fatalf("Failed to read commit: %v", err)
+		}
+
+		// Swap the blob's content for something that hashes differently.
+		b, _ := blob.New([]byte("main content"))
+		blobPath, _ := object.Path(repo.ObjectPath(), b.Hash())
+		other, _ := blob.New([]byte("other content"))
+		other.Store(repo.Objects)
+		otherPath, _ := object.Path(repo.ObjectPath(), other.Hash())
+		data, _ := os.ReadFile(otherPath)
+		os.Chmod(blobPath, 0644)
+		os.WriteFile(blobPath, data, 0644)
+
+		treePath, _ := object.Path(repo.ObjectPath(), com.TreeHash)
+		os.Remove(treePath)
+
+		missing := object.Hash(repo.ObjectFormat, object.TypeCommit, []byte("nowhere"))
+		refs.UpdateRef(testDir, "refs/heads/broken", missing, false)
+		refs.UpdateRef(testDir, "refs/heads/blob", other.Hash(), false)
+
+		report, err := NewFsckCommand(testDir, false).Check()
+		if err != nil {
+			t.Fatalf("Failed to run fsck: %v", err)
+		}
+		if len(report.Corrupt) != 1 || report.Corrupt[0].Hash != b.Hash() {
+			t.Errorf("Corrupt = %+v; want %s", report.Corrupt, b.Hash())
+		}
+		if len(report.Missing) != 1 || report.Missing[0].Hash != com.TreeHash || report.Missing[0].From != head.Target {
+			t.Errorf("Missing = %+v; want tree %s from %s", report.Missing, com.TreeHash, head.Target)
+		}
+		if len(report.BadRefs) != 2 {
+			t.Errorf("BadRefs = %+v; want 2", report.BadRefs)
+		}
+		if len(report.Dangling) != 0 {
+			t.Errorf("Dangling = %+v; want none", report.Dangling)
+		}
+		if err := NewFsckCommand(testDir, true).Execute(); err != ErrFsckFailed {
+			t.Errorf("Expected ErrFsckFailed, got %v", err)
+		}
+
+		refs.DeleteBranch(testDir, "blob")
+		report, _ = NewFsckCommand(testDir, false).Check()
+		if len(report.Dangling) != 1 || report.Dangling[0].Hash != other.Hash() {
+			t.Errorf("Dangling = %+v; want blob %s", report.Dangling, other.Hash())
+		}
+	})
+	t.Run("1.3: Encrypted repository", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		defer os.RemoveAll(testDir)
+		key, _ := object.GenerateKey()
+		t.Setenv(repository.EncryptionKeyEnv, fmt.Sprintf("%x", key))
+		repo, err := repository.InitEncrypted(testDir, objectformat.SHA1, "")
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		os.WriteFile(filepath.Join(testDir, "main.go"), []byte("main content"), 0644)
+		idx, _ := staging.New(testDir)
+		idx.Add("main.go")
+		if err := NewCommitCommand(testDir, "first", "Test User <test@example.com>", false).Execute(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+
+		report, err := NewFsckCommand(testDir, false).Check()
+		if err != nil {
+			t.Fatalf("Failed to run fsck: %v", err)
+		}
+		if report.Checked != 3 || len(report.Corrupt)+len(report.Missing)+len(report.BadRefs) != 0 {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+
+		b, _ := blob.New([]byte("main content"))
+		blobPath, _ := object.Path(repo.ObjectPath(), b.Hash())
+		data, _ := os.ReadFile(blobPath)
+		data[len(data)-1] ^= 1
+		os.Chmod(blobPath, 0644)
+		os.WriteFile(blobPath, data, 0644)
+		report, err = NewFsckCommand(testDir, false).Check()
+		if err != nil {
+			t.Fatalf("Failed to run fsck: %v", err)
+		}
+		if len(report.Corrupt) != 1 || report.Corrupt[0].Hash != b.Hash() {
+			t.Errorf("Corrupt = %+v; want %s", report.Corrupt, b.Hash())
+		}
+
+		t.Setenv(repository.EncryptionKeyEnv, "")
+		if _, err := NewFsckCommand(testDir, false).Check(); err == nil {
+			t.Error("Expected fsck to fail without the key")
+		}
+	})
+
+	t.Run("1.4: Links of the wrong type and reflog entries", func(t *testing.T) {
+		repo := setup(t)
+		defer os.RemoveAll(testDir)
+
+		// A tree that claims the blob is a subdirectory.
+		b, _ := blob.New([]byte("main content"))
+		bad := tree.New()
+		bad.AddEntry("sub", b.Hash(), tree.DirectoryMode)
+		badTree, _ := bad.Write(repo.Objects)
+		head, _ := refs.ReadRef(testDir, "refs/heads/main")
+		com, _ := commit.New(badTree, []string{head.Target}, "Test User <test@example.com>", "typed")
+		typed, _ := com.Write(repo.Objects)
+		refs.UpdateRef(testDir, "refs/heads/typed", typed, false)
+
+		report, err := NewFsckCommand(testDir, false).Check()
+		if err != nil {
+			t.Fatalf("Failed to run fsck: %v", err)
+		}
+		if len(report.Corrupt) != 1 || report.Corrupt[0].Hash != badTree || !strings.Contains(report.Corrupt[0].Error, "blob") {
+			t.Errorf("Corrupt = %+v; want tree %s", report.Corrupt, badTree)
+		}
+
+		// Once the branch is gone only the reflog remembers the commit.
+		refs.DeleteBranch(testDir, "typed")
+		report, _ = NewFsckCommand(testDir, false).Check()
+		if len(report.Dangling) != 1 || report.Dangling[0].Hash != typed {
+			t.Errorf("Dangling = %+v; want commit %s", report.Dangling, typed)
+		}
+		logPath := filepath.Join(repo.GitgoDir, refs.LogsDir, refs.HeadFile)
+		os.MkdirAll(filepath.Dir(logPath), 0755)
+		entry := head.Target + " " + typed + " Test User <test@example.com> 1700000000 +0000\tcommit: typed\n"
+		os.WriteFile(logPath, []byte(entry), 0644)
+		report, _ = NewFsckCommand(testDir, false).Check()
+		if len(report.Dangling) != 0 {
+			t.Errorf("Dangling = %+v; want none with the reflog entry", report.Dangling)
+		}
+	})
+
+	t.Run("1.5: Loose object with trailing data", func(t *testing.T) {
+		repo := setup(t)
+		defer os.RemoveAll(testDir)
+
+		// The header declares the length of "main content", but the
+		// stream goes on past it.
+		b, _ := blob.New([]byte("main content"))
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write([]byte("blob 12\x00main content and more"))
+		zw.Close()
+		blobPath, _ := object.Path(repo.ObjectPath(), b.Hash())
+		os.Chmod(blobPath, 0644)
+		os.WriteFile(blobPath, compressed.Bytes(), 0644)
+
+		report, err := NewFsckCommand(testDir, false).Check()
+		if err != nil {
+			t.Fatalf("Failed to run fsck: %v", err)
+		}
+		if len(report.Corrupt) != 1 || report.Corrupt[0].Hash != b.Hash() {
+			t.Errorf("Corrupt = %+v; want %s", report.Corrupt, b.Hash())
+		}
+	})
+}
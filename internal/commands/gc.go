@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/HalilFocic/gitgo/internal/config"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/pack"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+// writeCommitGraphKey turns off rewriting the commit-graph during gc when
+// set to false, as in git.
+const writeCommitGraphKey = "gc.writeCommitGraph"
+
+type GCCommand struct {
+	rootPath    string
+	pruneExpire string
+}
+
+// NewGCCommand creates a gc command. pruneExpire is passed on to the
+// prune phase, see NewPruneCommand.
+func NewGCCommand(rootPath, pruneExpire string) *GCCommand {
+	return &GCCommand{
+		rootPath:    rootPath,
+		pruneExpire: pruneExpire,
+	}
+}
+
+// Execute packs every reachable object into a single new pack, deletes
+// the loose copies and any older pack the new one fully covers, prunes
+// expired unreachable loose objects and rewrites the commit-graph.
+// Encrypted repositories are pruned but not packed, and objects borrowed
+// from alternates are left where they are.
+func (c *GCCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	cutoff, err := pruneCutoff(repo.GitgoDir, c.pruneExpire, time.Now())
+	if err != nil {
+		return err
+	}
+
+	reachable, err := collectReachable(c.rootPath, repo.Objects)
+	if err != nil {
+		return err
+	}
+	// Objects borrowed from alternates stay there; repack -a copies them.
+	local := localObjects(repo, reachable)
+	if len(local) == 0 {
+		fmt.Println("Nothing to pack")
+	} else if repo.Encrypted {
+		// Packs are not encrypted, so sealed objects stay loose.
+		fmt.Println("Objects stay loose in an encrypted repository")
+	} else if err := packObjects(repo, local); err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool)
+	for _, r := range reachable {
+		keep[r.hash] = true
+	}
+	pruned, err := pruneLoose(repo.ObjectPath(), repo.ObjectFormat, keep, cutoff, false)
+	if err != nil {
+		return err
+	}
+	if len(pruned) > 0 {
+		fmt.Printf("Pruned %d unreachable objects\n", len(pruned))
+	}
+
+	cfg, err := config.Load(repo.GitgoDir)
+	if err != nil {
+		return err
+	}
+	if cfg.Get(writeCommitGraphKey) != "false" {
+		if _, err := writeCommitGraph(c.rootPath, repo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localObjects drops the objects repo only borrows from its alternates.
+func localObjects(repo *repository.Repository, reachable []reachableObject) []reachableObject {
+	var local []reachableObject
+	for _, r := range reachable {
+		if object.HasLocal(repo.Objects, r.hash) {
+			local = append(local, r)
+		}
+	}
+	return local
+}
+
+// packObjects writes reachable into a single new pack, then deletes their
+// loose copies and every older pack the new one fully covers.
+func packObjects(repo *repository.Repository, reachable []reachableObject) error {
+	objectsPath := repo.ObjectPath()
+	packDir := filepath.Join(objectsPath, "pack")
+
+	entries := make([]pack.Entry, 0, len(reachable))
+	packed := make(map[string]bool)
+	for _, r := range reachable {
+		obj, err := repo.Objects.Get(r.hash)
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %v", r.hash, err)
+		}
+		entries = append(entries, pack.Entry{
+			Hash: r.hash,
+			Type: obj.Type,
+			Data: obj.Data,
+			Path: r.path,
+		})
+		packed[r.hash] = true
+	}
+
+	oldPacks, err := pack.OpenDir(packDir, repo.ObjectFormat)
+	if err != nil {
+		return err
+	}
+	packPath, err := pack.Write(packDir, repo.ObjectFormat, entries)
+	if err != nil {
+		return fmt.Errorf("failed to write pack: %v", err)
+	}
+
+	removed := 0
+	for hash := range packed {
+		loosePath, err := object.Path(objectsPath, hash)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(loosePath); err == nil {
+			removed++
+			// Drop the fan-out directory once it is empty.
+			os.Remove(filepath.Dir(loosePath))
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove loose object %s: %v", hash, err)
+		}
+	}
+
+	for _, old := range oldPacks {
+		if old.Path() == packPath || !coversPack(packed, old) {
+			continue
+		}
+		idxPath := old.Path()[:len(old.Path())-len(".pack")] + ".idx"
+		if err := os.Remove(idxPath); err != nil {
+			return fmt.Errorf("failed to remove old pack index: %v", err)
+		}
+		if err := os.Remove(old.Path()); err != nil {
+			return fmt.Errorf("failed to remove old pack: %v", err)
+		}
+	}
+
+	fmt.Printf("Packed %d objects into %s, removed %d loose objects\n",
+		len(entries), filepath.Base(packPath), removed)
+	return nil
+}
+
+func coversPack(packed map[string]bool, p *pack.Pack) bool {
+	for _, hash := range p.Hashes() {
+		if !packed[hash] {
+			return false
+		}
+	}
+	return true
+}
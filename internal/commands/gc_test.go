@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/blob"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/staging"
+)
+
+func TestGCCommand(t *testing.T) {
+	t.Run("1.1: Pack reachable objects", func(t *testing.T) {
+		cwd, _ := os.Getwd()
+		testDir := filepath.Join(cwd, "testdata")
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		defer os.RemoveAll(testDir)
+
+		if _, err := repository.Init(testDir); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		for i, content := range []string{"first\n", "second\n"} {
+			os.WriteFile(filepath.Join(testDir, "util.go"), []byte(strings.Repeat(content, 100+i)), 0644)
+			idx, err := staging.New(testDir)
+			if err != nil {
+				t.Fatalf("Failed to create staging area: %v", err)
+			}
+			if err := idx.Add("util.go"); err != nil {
+				t.Fatalf("Failed to stage file: %v", err)
+			}
+			if err := NewCommitCommand(testDir, "commit", "Test User <test@example.com>", false).Execute(); err != nil {
+				t.Fatalf("Failed to commit: %v", err)
+			}
+		}
+
+		if err := NewGCCommand(testDir, "").Execute(); err != nil {
+			t.Fatalf("Failed to run gc: %v", err)
+		}
+
+		objectsPath := filepath.Join(testDir, ".gitgo", "objects")
+		entries, _ := os.ReadDir(objectsPath)
+		for _, entry := range entries {
+			if entry.Name() != "pack" && entry.Name() != "info" {
+				t.Errorf("Expected loose objects to be removed, found %s", entry.Name())
+			}
+		}
+		packs, _ := filepath.Glob(filepath.Join(objectsPath, "pack", "*.pack"))
+		if len(packs) != 1 {
+			t.Fatalf("Expected exactly one pack, got %d", len(packs))
+		}
+
+		os.Remove(filepath.Join(testDir, "util.go"))
+		if err := NewCheckoutCommand(testDir, "main").Execute(); err != nil {
+			t.Fatalf("Failed to checkout from pack: %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(testDir, "util.go"))
+		if err != nil || string(content) != strings.Repeat("second\n", 101) {
+			t.Errorf("Checked out content mismatch: %v", err)
+		}
+
+		b, _ := blob.New(content)
+		repo, _ := repository.Open(testDir)
+		if _, err := blob.Read(repo.Objects, b.Hash()); err != nil {
+			t.Errorf("Failed to read packed blob: %v", err)
+		}
+
+		if err := NewGCCommand(testDir, "").Execute(); err != nil {
+			t.Fatalf("Failed to run gc again: %v", err)
+		}
+		packs, _ = filepath.Glob(filepath.Join(objectsPath, "pack", "*.pack"))
+		if len(packs) != 1 {
+			t.Errorf("Expected repacking to replace the old pack, got %d packs", len(packs))
+		}
+	})
+}
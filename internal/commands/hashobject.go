@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/HalilFocic/gitgo/internal/blob"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+// HashObjectCommand prints the blob ID of files or of standard input and
+// with write set also stores them.
+type HashObjectCommand struct {
+	rootPath string
+	paths    []string
+	stdin    io.Reader
+	write    bool
+}
+
+// NewHashObjectCommand creates a hash-object command. When stdin is not
+// nil its content is hashed before the files in paths.
+func NewHashObjectCommand(rootPath string, paths []string, stdin io.Reader, write bool) *HashObjectCommand {
+	return &HashObjectCommand{
+		rootPath: rootPath,
+		paths:    paths,
+		stdin:    stdin,
+		write:    write,
+	}
+}
+
+func (c *HashObjectCommand) Execute() error {
+	hash := func(r io.Reader, size int64) (string, error) {
+		return blob.HashFrom(objectformat.SHA1, r, size)
+	}
+	// Hashing alone works outside a repository, as in git, and then
+	// falls back to SHA-1.
+	repo, err := repository.Open(c.rootPath)
+	switch {
+	case err == nil && c.write:
+		hash = func(r io.Reader, size int64) (string, error) {
+			return blob.StoreFrom(repo.Objects, r, size)
+		}
+	case err == nil:
+		hash = func(r io.Reader, size int64) (string, error) {
+			return blob.HashFrom(repo.ObjectFormat, r, size)
+		}
+	case c.write:
+		return err
+	}
+
+	if c.stdin != nil {
+		content, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read standard input: %v", err)
+		}
+		id, err := hash(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return err
+		}
+		fmt.Println(id)
+	}
+	for _, path := range c.paths {
+		id, err := hashFile(path, hash)
+		if err != nil {
+			return err
+		}
+		fmt.Println(id)
+	}
+	return nil
+}
+
+func hashFile(path string, hash func(io.Reader, int64) (string, error)) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	return hash(file, info.Size())
+}
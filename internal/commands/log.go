@@ -2,35 +2,55 @@ package commands
 
 import (
 	"fmt"
+	"strings"
+
 	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/commitgraph"
 	"github.com/HalilFocic/gitgo/internal/refs"
-	"path/filepath"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/sshsig"
 )
 
 type LogCommand struct {
-	rootPath string
-	maxCount int
+	rootPath      string
+	maxCount      int
+	revision      string
+	abbrev        bool
+	showSignature bool
 }
 
-func NewLogCommand(rootPath string, maxCount int) *LogCommand {
+// NewLogCommand creates a log command starting at revision, or at HEAD
+// when revision is empty. With abbrev set commit IDs are shortened like
+// the parents on Merge: lines, and with showSignature signed commits
+// are checked against the allowed signers file.
+func NewLogCommand(rootPath string, maxCount int, revision string, abbrev, showSignature bool) *LogCommand {
 	if maxCount <= 0 {
 		maxCount = -1
 	}
 	return &LogCommand{
-		rootPath: rootPath,
-		maxCount: maxCount,
+		rootPath:      rootPath,
+		maxCount:      maxCount,
+		revision:      revision,
+		abbrev:        abbrev,
+		showSignature: showSignature,
 	}
 }
 
 func (c *LogCommand) Execute() error {
-	objectsPath := filepath.Join(c.rootPath, ".gitgo", "objects")
-
-	headRef, err := refs.ReadHead(c.rootPath)
+	repo, err := repository.Open(c.rootPath)
 	if err != nil {
-		return fmt.Errorf("failed to read HEAD: %v", err)
+		return err
 	}
+	objects := repo.Objects
+
 	var currentCommitHash string
-	if headRef.Type == refs.RefTypeCommit {
+	if c.revision != "" {
+		if currentCommitHash, err = resolveCommit(c.rootPath, repo, c.revision); err != nil {
+			return err
+		}
+	} else if headRef, err := refs.ReadHead(c.rootPath); err != nil {
+		return fmt.Errorf("failed to read HEAD: %v", err)
+	} else if headRef.Type == refs.RefTypeCommit {
 		currentCommitHash = headRef.Target
 	} else {
 		newRef, err := refs.ReadRef(c.rootPath, headRef.Target)
@@ -39,30 +59,109 @@ func (c *LogCommand) Execute() error {
 		}
 		currentCommitHash = newRef.Target
 	}
-	commitCount := 0
+	// Parents and dates come from the commit-graph when there is one; the
+	// commits themselves are still read for their author and message.
+	source := newCommitSource(repo.GitgoDir, objects)
+	seen := make(map[string]bool)
+	var queue []*commitgraph.Commit
+	push := func(hash string) error {
+		if seen[hash] {
+			return nil
+		}
+		seen[hash] = true
+		node, err := source.lookup(hash)
+		if err != nil {
+			return err
+		}
+		queue = append(queue, node)
+		return nil
+	}
+	if currentCommitHash != "" {
+		if err := push(currentCommitHash); err != nil {
+			return err
+		}
+	}
 
-	for currentCommitHash != "" {
+	// A missing allowed signers file is reported for each signed commit
+	// rather than failing the whole log.
+	var signers sshsig.AllowedSigners
+	var signersErr error
+	if c.showSignature {
+		signers, signersErr = allowedSigners(repo.GitgoDir)
+	}
+
+	commitCount := 0
+	for len(queue) > 0 {
 		if c.maxCount != -1 && commitCount >= c.maxCount {
 			break
 		}
 
-		currentCommit, err := commit.Read(objectsPath, currentCommitHash)
+		// Like git log, show the newest pending commit next so that the
+		// sides of a merge are interleaved by date. The queue only holds
+		// one commit per line of history being followed.
+		next := 0
+		for i, node := range queue {
+			if node.CommitTime > queue[next].CommitTime {
+				next = i
+			}
+		}
+		node := queue[next]
+		queue = append(queue[:next], queue[next+1:]...)
+
+		currentCommit, err := commit.Read(objects, node.Hash)
 		if err != nil {
-			return fmt.Errorf("failed to read commit %s: %v", currentCommitHash, err)
+			return fmt.Errorf("failed to read commit %s: %v", node.Hash, err)
 		}
 
-		fmt.Printf("commit %s\n", currentCommitHash)
+		fmt.Printf("commit %s\n", abbreviate(repo, node.Hash, c.abbrev))
+		if c.showSignature && currentCommit.Signature() != "" {
+			good, err := verifySignature(signers, currentCommit)
+			if signersErr != nil {
+				good, err = "", signersErr
+			}
+			if err != nil {
+				fmt.Printf("Could not verify signature: %v\n", err)
+			} else {
+				fmt.Println(good)
+			}
+		}
+		if len(currentCommit.Parents) > 1 {
+			parents := make([]string, len(currentCommit.Parents))
+			for i, parent := range currentCommit.Parents {
+				parents[i] = abbreviate(repo, parent, true)
+			}
+			fmt.Printf("Merge: %s\n", strings.Join(parents, " "))
+		}
 		fmt.Printf("Author: %s\n", currentCommit.Author)
 		fmt.Printf("Date: %v\n", currentCommit.AuthorDate.Format("Mon Jan 2 15:04:05 2006 -0700"))
-		fmt.Printf("\n    %s\n\n", currentCommit.Message)
+		fmt.Printf("\n%s\n\n", indentMessage(currentCommit.Message))
 
-		currentCommitHash = currentCommit.ParentHash
+		for _, parent := range node.Parents {
+			if err := push(parent); err != nil {
+				return err
+			}
+		}
 		commitCount++
 	}
 
 	if commitCount == 0 {
 		fmt.Println("No commits found")
 	}
+	traceObjectCache(repo)
 
 	return nil
 }
+
+// indentMessage indents every line of a commit message by four spaces
+// and drops the trailing newlines, the way git log shows messages.
+func indentMessage(message string) string {
+	message = strings.TrimRight(message, "\n")
+	if message == "" {
+		return ""
+	}
+	lines := strings.Split(message, "\n")
+	for i, line := range lines {
+		lines[i] = "    " + line
+	}
+	return strings.Join(lines, "\n")
+}
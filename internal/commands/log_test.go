@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+func TestIndentMessage(t *testing.T) {
+	t.Run("1.1: Every line is indented", func(t *testing.T) {
+		cases := []struct {
+			message, want string
+		}{
+			{"subject", "    subject"},
+			{"subject\n", "    subject"},
+			{"subject\n\nbody line 1\nline 2\n\n\n", "    subject\n    \n    body line 1\n    line 2"},
+			{"\n", ""},
+		}
+		for _, tc := range cases {
+			if got := indentMessage(tc.message); got != tc.want {
+				t.Errorf("indentMessage(%q) = %q; want %q", tc.message, got, tc.want)
+			}
+		}
+	})
+}
+
+func TestAbbreviate(t *testing.T) {
+	t.Run("2.1: Default length", func(t *testing.T) {
+		repo := &repository.Repository{Objects: object.NewMemoryStore(objectformat.SHA1)}
+		hash, _ := repo.Objects.Put(object.TypeBlob, []byte("content"))
+		if got := abbreviate(repo, hash, true); got != hash[:object.DefaultAbbrev] {
+			t.Errorf("abbreviate(%s) = %s; want %s", hash, got, hash[:object.DefaultAbbrev])
+		}
+		if got := abbreviate(repo, hash, false); got != hash {
+			t.Errorf("abbreviate(%s) disabled = %s; want the full ID", hash, got)
+		}
+	})
+}
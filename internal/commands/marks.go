@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// readMarks parses a fast-import marks file, one ":<mark> <object ID>"
+// per line, as written by git fast-export and fast-import. A missing
+// file holds no marks.
+func readMarks(path string) (map[int]string, error) {
+	marks := make(map[int]string)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return marks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read marks: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		mark, hash, ok := strings.Cut(text, " ")
+		number, err := strconv.Atoi(strings.TrimPrefix(mark, ":"))
+		if !ok || !strings.HasPrefix(mark, ":") || err != nil || number <= 0 {
+			return nil, fmt.Errorf("invalid marks file %s at line %d", path, line)
+		}
+		marks[number] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read marks: %v", err)
+	}
+	return marks, nil
+}
+
+// writeMarks replaces the marks file at path, ordered by mark.
+func writeMarks(path string, marks map[int]string) error {
+	numbers := make([]int, 0, len(marks))
+	for number := range marks {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	var content strings.Builder
+	for _, number := range numbers {
+		fmt.Fprintf(&content, ":%d %s\n", number, marks[number])
+	}
+	tmp := path + ".lock"
+	if err := os.WriteFile(tmp, []byte(content.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write marks: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write marks: %v", err)
+	}
+	return nil
+}
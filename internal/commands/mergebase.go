@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/HalilFocic/gitgo/internal/commitgraph"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+// ErrNotAncestor is returned by MergeBaseCommand in is-ancestor mode when
+// the first commit is not an ancestor of the second.
+var ErrNotAncestor = errors.New("not an ancestor")
+
+type MergeBaseCommand struct {
+	rootPath   string
+	first      string
+	second     string
+	isAncestor bool
+}
+
+// NewMergeBaseCommand creates a merge-base command printing the best
+// common ancestors of first and second. With isAncestor set it prints
+// nothing and instead returns ErrNotAncestor unless first is an ancestor
+// of second.
+func NewMergeBaseCommand(rootPath, first, second string, isAncestor bool) *MergeBaseCommand {
+	return &MergeBaseCommand{
+		rootPath:   rootPath,
+		first:      first,
+		second:     second,
+		isAncestor: isAncestor,
+	}
+}
+
+func (c *MergeBaseCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	first, err := resolveCommit(c.rootPath, repo, c.first)
+	if err != nil {
+		return err
+	}
+	second, err := resolveCommit(c.rootPath, repo, c.second)
+	if err != nil {
+		return err
+	}
+	source := newCommitSource(repo.GitgoDir, repo.Objects)
+
+	if c.isAncestor {
+		ok, err := source.isAncestor(first, second)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotAncestor
+		}
+		return nil
+	}
+
+	bases, err := source.mergeBases(first, second)
+	if err != nil {
+		return err
+	}
+	if len(bases) == 0 {
+		return fmt.Errorf("no merge base between %s and %s", c.first, c.second)
+	}
+	for _, hash := range bases {
+		fmt.Println(hash)
+	}
+	return nil
+}
+
+// isAncestor reports whether ancestor is reachable from descendant. With
+// a commit-graph, commits of a lower generation than ancestor cannot
+// reach it and are not walked.
+func (s *commitSource) isAncestor(ancestor, descendant string) (bool, error) {
+	cutoff := s.generation(ancestor)
+	if cutoff == commitgraph.GenerationInfinity {
+		cutoff = 0
+	}
+	seen := map[string]bool{descendant: true}
+	stack := []string{descendant}
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if hash == ancestor {
+			return true, nil
+		}
+		c, err := s.lookup(hash)
+		if err != nil {
+			return false, err
+		}
+		for _, parent := range c.Parents {
+			if seen[parent] || s.generation(parent) < cutoff {
+				continue
+			}
+			seen[parent] = true
+			stack = append(stack, parent)
+		}
+	}
+	return false, nil
+}
+
+// mergeBases returns the common ancestors of first and second that are
+// not themselves ancestors of another common ancestor, sorted by ID.
+func (s *commitSource) mergeBases(first, second string) ([]string, error) {
+	ancestors, err := s.ancestors(first)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk back from second, stopping at the first common commits on
+	// each path.
+	var candidates []string
+	seen := map[string]bool{second: true}
+	stack := []string{second}
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if ancestors[hash] {
+			candidates = append(candidates, hash)
+			continue
+		}
+		c, err := s.lookup(hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range c.Parents {
+			if !seen[parent] {
+				seen[parent] = true
+				stack = append(stack, parent)
+			}
+		}
+	}
+
+	var bases []string
+	for _, candidate := range candidates {
+		redundant := false
+		for _, other := range candidates {
+			if other == candidate {
+				continue
+			}
+			ok, err := s.isAncestor(candidate, other)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			bases = append(bases, candidate)
+		}
+	}
+	sort.Strings(bases)
+	return bases, nil
+}
+
+func (s *commitSource) ancestors(hash string) (map[string]bool, error) {
+	seen := map[string]bool{hash: true}
+	stack := []string{hash}
+	for len(stack) > 0 {
+		c, err := s.lookup(stack[len(stack)-1])
+		stack = stack[:len(stack)-1]
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range c.Parents {
+			if !seen[parent] {
+				seen[parent] = true
+				stack = append(stack, parent)
+			}
+		}
+	}
+	return seen, nil
+}
@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/commitgraph"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/tag"
+	"github.com/HalilFocic/gitgo/internal/tree"
+)
+
+// MigrateObjectsCommand rewrites trees and commits that were stored under
+// IDs hashed over their compressed bytes, which older gitgo versions did,
+// and points every reference and reflog entry at the rewritten commits.
+type MigrateObjectsCommand struct {
+	rootPath string
+	trees    map[string]string
+	commits  map[string]string
+	tags     map[string]string
+}
+
+func NewMigrateObjectsCommand(rootPath string) *MigrateObjectsCommand {
+	return &MigrateObjectsCommand{
+		rootPath: rootPath,
+		trees:    make(map[string]string),
+		commits:  make(map[string]string),
+		tags:     make(map[string]string),
+	}
+}
+
+func (c *MigrateObjectsCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	objectsPath := repo.ObjectPath()
+
+	names, err := refs.ListRefs(c.rootPath)
+	if err != nil {
+		return err
+	}
+	names = append(names, refs.HeadFile)
+
+	updates := make(map[string]string)
+	for _, name := range names {
+		ref, err := refs.ReadRef(c.rootPath, name)
+		if err != nil {
+			return err
+		}
+		if ref.Type == refs.RefTypeSymbolic || ref.Target == "" {
+			continue
+		}
+		newHash, err := c.migrateRef(repo.Objects, ref.Target)
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s: %v", name, err)
+		}
+		if newHash != ref.Target {
+			updates[name] = newHash
+		}
+	}
+
+	// Reflogs may name commits no reference reaches any more. They are
+	// migrated too, so that every entry still names an object; entries
+	// whose objects are gone are left alone.
+	logged, err := refs.ReflogHashes(c.rootPath)
+	if err != nil {
+		return err
+	}
+	for _, hash := range logged {
+		objectType, _, err := object.ReadHeader(repo.Objects, hash)
+		if err != nil || (objectType != object.TypeCommit && objectType != object.TypeTag) {
+			continue
+		}
+		if _, err := c.migrateRef(repo.Objects, hash); err != nil {
+			return fmt.Errorf("failed to migrate reflog entry %s: %v", hash, err)
+		}
+	}
+
+	for name, newHash := range updates {
+		if err := refs.UpdateRef(c.rootPath, name, newHash, false); err != nil {
+			return err
+		}
+	}
+	ids := make(map[string]string)
+	for _, mapping := range []map[string]string{c.commits, c.tags} {
+		for oldHash, newHash := range mapping {
+			if oldHash != newHash {
+				ids[oldHash] = newHash
+			}
+		}
+	}
+	if err := refs.RewriteReflogs(c.rootPath, ids); err != nil {
+		return err
+	}
+
+	rewritten := 0
+	for _, mapping := range []map[string]string{c.trees, c.commits} {
+		for oldHash, newHash := range mapping {
+			if oldHash == newHash {
+				continue
+			}
+			rewritten++
+			oldPath, _ := object.Path(objectsPath, oldHash)
+			if err := os.Remove(oldPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove old object %s: %v", oldHash, err)
+			}
+		}
+	}
+
+	// The commit-graph names the old commit IDs.
+	if rewritten > 0 {
+		if err := os.Remove(commitgraph.Path(objectsPath)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove commit-graph: %v", err)
+		}
+	}
+
+	fmt.Printf("Rewrote %d objects and updated %d references\n", rewritten, len(updates))
+	return nil
+}
+
+// migrateRef migrates the commit a reference points at. Annotated tags
+// were never written by the old versions, but a tag created since may
+// point at an old commit, so it is rewritten to the commit's new ID.
+func (c *MigrateObjectsCommand) migrateRef(objects object.ObjectStore, hash string) (string, error) {
+	objectType, _, err := object.ReadHeader(objects, hash)
+	if err != nil {
+		return "", err
+	}
+	if objectType != object.TypeTag {
+		return c.migrateCommit(objects, hash)
+	}
+	if newHash, ok := c.tags[hash]; ok {
+		return newHash, nil
+	}
+	t, err := tag.Read(objects, hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read tag %s: %v", hash, err)
+	}
+	if t.ObjectType != object.TypeCommit {
+		return hash, nil
+	}
+	target, err := c.migrateCommit(objects, t.Object)
+	if err != nil || target == t.Object {
+		return hash, err
+	}
+	t.Object = target
+	newHash, err := t.Write(objects)
+	if err != nil {
+		return "", fmt.Errorf("failed to write tag: %v", err)
+	}
+	c.tags[hash] = newHash
+	return newHash, nil
+}
+
+func (c *MigrateObjectsCommand) migrateCommit(objects object.ObjectStore, hash string) (string, error) {
+	// Commits are rewritten parents first so every parent already has
+	// its new ID. An explicit stack keeps long histories from exhausting
+	// the goroutine stack.
+	read := make(map[string]*commit.Commit)
+	stack := []string{hash}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		if _, done := c.commits[current]; done {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		com, ok := read[current]
+		if !ok {
+			var err error
+			if com, err = commit.Read(objects, current); err != nil {
+				return "", fmt.Errorf("failed to read commit %s: %v", current, err)
+			}
+			read[current] = com
+		}
+
+		pending := false
+		for _, parent := range com.Parents {
+			if _, done := c.commits[parent]; !done {
+				stack = append(stack, parent)
+				pending = true
+			}
+		}
+		if pending {
+			continue
+		}
+		stack = stack[:len(stack)-1]
+
+		treeHash, err := c.migrateTree(objects, com.TreeHash)
+		if err != nil {
+			return "", err
+		}
+		com.TreeHash = treeHash
+		parents := make([]string, len(com.Parents))
+		for i, parent := range com.Parents {
+			parents[i] = c.commits[parent]
+		}
+		com.Parents = parents
+		newHash, err := com.Write(objects)
+		if err != nil {
+			return "", fmt.Errorf("failed to write commit: %v", err)
+		}
+		c.commits[current] = newHash
+		delete(read, current)
+	}
+	return c.commits[hash], nil
+}
+
+func (c *MigrateObjectsCommand) migrateTree(objects object.ObjectStore, hash string) (string, error) {
+	if newHash, ok := c.trees[hash]; ok {
+		return newHash, nil
+	}
+	oldTree, err := tree.Read(objects, hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read tree %s: %v", hash, err)
+	}
+
+	newTree := tree.New()
+	for _, entry := range oldTree.Entries() {
+		entryHash := entry.Hash
+		if entry.Mode == tree.DirectoryMode {
+			entryHash, err = c.migrateTree(objects, entry.Hash)
+			if err != nil {
+				return "", err
+			}
+		}
+		if err := newTree.AddEntry(entry.Name, entryHash, entry.Mode); err != nil {
+			return "", err
+		}
+	}
+
+	newHash, err := newTree.Write(objects)
+	if err != nil {
+		return "", fmt.Errorf("failed to write tree: %v", err)
+	}
+	c.trees[hash] = newHash
+	return newHash, nil
+}
@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/blob"
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+// writeLegacyObject stores an object the way older gitgo versions did,
+// with its ID hashed over the compressed bytes.
+func writeLegacyObject(t *testing.T, objectsPath, objectType string, data []byte) string {
+	t.Helper()
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	fmt.Fprintf(zw, "%s %d\x00", objectType, len(data))
+	zw.Write(data)
+	zw.Close()
+
+	sum := sha1.Sum(compressed.Bytes())
+	hash := hex.EncodeToString(sum[:])
+	objectPath := filepath.Join(objectsPath, hash[:2], hash[2:])
+	os.MkdirAll(filepath.Dir(objectPath), 0755)
+	if err := os.WriteFile(objectPath, compressed.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write legacy object: %v", err)
+	}
+	return hash
+}
+
+func legacyTree(t *testing.T, objectsPath, name, hash string, mode int) string {
+	t.Helper()
+	hashBytes, _ := hex.DecodeString(hash)
+	data := append([]byte(fmt.Sprintf("%o %s\x00", mode, name)), hashBytes...)
+	return writeLegacyObject(t, objectsPath, object.TypeTree, data)
+}
+
+func TestMigrateObjectsCommand(t *testing.T) {
+	t.Run("1.1: Rewrite legacy history", func(t *testing.T) {
+		cwd, _ := os.Getwd()
+		testDir := filepath.Join(cwd, "testdata")
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		defer os.RemoveAll(testDir)
+
+		if _, err := repository.Init(testDir); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		objectsPath := filepath.Join(testDir, ".gitgo", "objects")
+		objects := object.NewFileStore(objectsPath, objectformat.SHA1)
+
+		b, _ := blob.New([]byte("content"))
+		if err := b.Store(objects); err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+		subTree := legacyTree(t, objectsPath, "file.txt", b.Hash(), 0100644)
+		rootTree := legacyTree(t, objectsPath, "lib", subTree, 0040000)
+
+		first := writeLegacyObject(t, objectsPath, object.TypeCommit,
+			[]byte(fmt.Sprintf("tree %s\nauthor A <a@example.com> 1700000000 +0000\n\nfirst", rootTree)))
+		second := writeLegacyObject(t, objectsPath, object.TypeCommit,
+			[]byte(fmt.Sprintf("tree %s\nparent %s\nauthor A <a@example.com> 1700000100 +0000\n\nsecond", rootTree, first)))
+		if err := refs.UpdateRef(testDir, "refs/heads/main", second, false); err != nil {
+			t.Fatalf("Failed to update ref: %v", err)
+		}
+
+		if err := NewMigrateObjectsCommand(testDir).Execute(); err != nil {
+			t.Fatalf("Failed to migrate objects: %v", err)
+		}
+
+		ref, err := refs.ReadRef(testDir, "refs/heads/main")
+		if err != nil {
+			t.Fatalf("Failed to read ref: %v", err)
+		}
+		if ref.Target == second {
+			t.Fatal("Expected main to point at a rewritten commit")
+		}
+
+		head, err := objects.Get(ref.Target)
+		if err != nil {
+			t.Fatalf("Failed to read migrated commit: %v", err)
+		}
+		if got := object.Hash(objectformat.SHA1, head.Type, head.Data); got != ref.Target {
+			t.Errorf("Migrated commit hash = %s; want %s", ref.Target, got)
+		}
+
+		headCommit, _ := commit.Read(objects, ref.Target)
+		parent, err := commit.Read(objects, headCommit.Parents[0])
+		if err != nil {
+			t.Fatalf("Failed to read migrated parent: %v", err)
+		}
+		if parent.Message != "first" || parent.TreeHash != headCommit.TreeHash {
+			t.Errorf("Parent not rewritten correctly: %+v", parent)
+		}
+
+		for _, old := range []string{first, second, rootTree, subTree} {
+			oldPath, _ := object.Path(objectsPath, old)
+			if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+				t.Errorf("Expected legacy object %s to be removed", old)
+			}
+		}
+		if _, err := blob.Read(objects, b.Hash()); err != nil {
+			t.Errorf("Blob should survive migration: %v", err)
+		}
+	})
+
+	t.Run("1.2: Migration is idempotent", func(t *testing.T) {
+		cwd, _ := os.Getwd()
+		testDir := filepath.Join(cwd, "testdata")
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		defer os.RemoveAll(testDir)
+
+		if _, err := repository.Init(testDir); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		objectsPath := filepath.Join(testDir, ".gitgo", "objects")
+		objects := object.NewFileStore(objectsPath, objectformat.SHA1)
+		c, _ := commit.New("4b825dc642cb6eb9a060e54bf8d69288fbee4904", nil, "A <a@example.com>", "empty")
+		emptyTree, _ := objects.Put(object.TypeTree, nil)
+		c.TreeHash = emptyTree
+		hash, err := c.Write(objects)
+		if err != nil {
+			t.Fatalf("Failed to write commit: %v", err)
+		}
+		refs.UpdateRef(testDir, "refs/heads/main", hash, false)
+
+		if err := NewMigrateObjectsCommand(testDir).Execute(); err != nil {
+			t.Fatalf("Failed to migrate objects: %v", err)
+		}
+		ref, _ := refs.ReadRef(testDir, "refs/heads/main")
+		if ref.Target != hash {
+			t.Errorf("Canonical commit should keep its ID: got %s, want %s", ref.Target, hash)
+		}
+		if _, err := commit.Read(objects, hash); err != nil {
+			t.Errorf("Canonical commit should not be removed: %v", err)
+		}
+	})
+
+	t.Run("1.3: Reflogs follow the rewritten commits", func(t *testing.T) {
+		cwd, _ := os.Getwd()
+		testDir := filepath.Join(cwd, "testdata")
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		defer os.RemoveAll(testDir)
+
+		repo, err := repository.Init(testDir)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		objectsPath := filepath.Join(testDir, ".gitgo", "objects")
+		objects := object.NewFileStore(objectsPath, objectformat.SHA1)
+		emptyTree := writeLegacyObject(t, objectsPath, object.TypeTree, nil)
+		first := writeLegacyObject(t, objectsPath, object.TypeCommit,
+			[]byte(fmt.Sprintf("tree %s\nauthor A <a@example.com> 1700000000 +0000\n\nfirst", emptyTree)))
+		// Only the reflog remembers this commit, e.g. after a reset.
+		dropped := writeLegacyObject(t, objectsPath, object.TypeCommit,
+			[]byte(fmt.Sprintf("tree %s\nparent %s\nauthor A <a@example.com> 1700000100 +0000\n\ndropped", emptyTree, first)))
+		refs.UpdateRef(testDir, "refs/heads/main", first, false)
+
+		zero := strings.Repeat("0", 40)
+		pruned := strings.Repeat("1", 40)
+		entry := func(old, new, message string) string {
+			return old + " " + new + " A <a@example.com> 1700000200 +0000\t" + message + "\n"
+		}
+		reflog := entry(zero, first, "commit (initial): first") +
+			entry(first, dropped, "commit: dropped") +
+			entry(dropped, first, "reset: moving to HEAD~1") +
+			entry(first, pruned, "commit: long gone")
+		logPath := filepath.Join(repo.GitgoDir, refs.LogsDir, "refs", "heads", "main")
+		os.MkdirAll(filepath.Dir(logPath), 0755)
+		os.WriteFile(logPath, []byte(reflog), 0644)
+
+		if err := NewMigrateObjectsCommand(testDir).Execute(); err != nil {
+			t.Fatalf("Failed to migrate objects: %v", err)
+		}
+		main, _ := refs.ReadRef(testDir, "refs/heads/main")
+		newFirst := main.Target
+		newDropped := object.Hash(objectformat.SHA1, object.TypeCommit,
+			[]byte(fmt.Sprintf("tree %s\nparent %s\nauthor A <a@example.com> 1700000100 +0000\n\ndropped",
+				"4b825dc642cb6eb9a060e54bf8d69288fbee4904", newFirst)))
+		if !objects.Has(newDropped) {
+			t.Error("Commit only in the reflog was not migrated")
+		}
+
+		want := entry(zero, newFirst, "commit (initial): first") +
+			entry(newFirst, newDropped, "commit: dropped") +
+			entry(newDropped, newFirst, "reset: moving to HEAD~1") +
+			entry(newFirst, pruned, "commit: long gone")
+		if got, _ := os.ReadFile(logPath); string(got) != want {
+			t.Errorf("Reflog = %q; want %q", got, want)
+		}
+	})
+}
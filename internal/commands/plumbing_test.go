@@ -0,0 +1,221 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/blob"
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/staging"
+	"github.com/HalilFocic/gitgo/internal/tree"
+)
+
+func TestPlumbingCommands(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDir := filepath.Join(cwd, "testdata")
+
+	setup := func(t *testing.T) *repository.Repository {
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		repo, err := repository.Init(testDir)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		return repo
+	}
+
+	t.Run("1.1: hash-object", func(t *testing.T) {
+		repo := setup(t)
+		defer os.RemoveAll(testDir)
+
+		path := filepath.Join(testDir, "main.go")
+		os.WriteFile(path, []byte("main content"), 0644)
+		b, _ := blob.New([]byte("main content"))
+		stdin, _ := blob.New([]byte("from stdin"))
+
+		if err := NewHashObjectCommand(testDir, []string{path}, nil, false).Execute(); err != nil {
+			t.Fatalf("Failed to hash object: %v", err)
+		}
+		if repo.Objects.Has(b.Hash()) {
+			t.Error("hash-object without -w should not store the blob")
+		}
+
+		err := NewHashObjectCommand(testDir, []string{path}, strings.NewReader("from stdin"), true).Execute()
+		if err != nil {
+			t.Fatalf("Failed to write objects: %v", err)
+		}
+		for _, hash := range []string{b.Hash(), stdin.Hash()} {
+			if !repo.Objects.Has(hash) {
+				t.Errorf("Blob %s should be stored with -w", hash)
+			}
+		}
+		if err := NewHashObjectCommand(testDir, []string{testDir}, nil, false).Execute(); err == nil {
+			t.Error("Expected error hashing a directory")
+		}
+	})
+
+	t.Run("1.2: write-tree and commit-tree", func(t *testing.T) {
+		repo := setup(t)
+		defer os.RemoveAll(testDir)
+
+		os.MkdirAll(filepath.Join(testDir, "src"), 0755)
+		os.WriteFile(filepath.Join(testDir, "README"), []byte("readme"), 0644)
+		os.WriteFile(filepath.Join(testDir, "src", "main.go"), []byte("main"), 0644)
+		idx, _ := staging.New(testDir)
+		for _, path := range []string{"README", "src/main.go"} {
+			if err := idx.Add(path); err != nil {
+				t.Fatalf("Failed to stage %s: %v", path, err)
+			}
+		}
+
+		treeHash, err := NewWriteTreeCommand(testDir).Write()
+		if err != nil {
+			t.Fatalf("Failed to write tree: %v", err)
+		}
+		root, err := tree.Read(repo.Objects, treeHash)
+		if err != nil || len(root.Entries()) != 2 {
+			t.Fatalf("Root tree = %+v, %v; want README and src", root, err)
+		}
+		if idx, _ := staging.New(testDir); len(idx.Entries()) != 2 {
+			t.Error("write-tree should leave the index alone")
+		}
+
+		os.Setenv(AuthorNameEnv, "Script Bot")
+		os.Setenv(AuthorEmailEnv, "bot@example.com")
+		defer os.Unsetenv(AuthorNameEnv)
+		defer os.Unsetenv(AuthorEmailEnv)
+		first, err := NewCommitTreeCommand(testDir, treeHash, nil, "first").Write()
+		if err != nil {
+			t.Fatalf("Failed to commit tree: %v", err)
+		}
+		second, err := NewCommitTreeCommand(testDir, first[:7], []string{first}, "second").Write()
+		if err != nil {
+			t.Fatalf("Failed to commit tree of a commit: %v", err)
+		}
+		com, err := commit.Read(repo.Objects, second)
+		if err != nil {
+			t.Fatalf("Failed to read commit: %v", err)
+		}
+		if com.TreeHash != treeHash || !slices.Equal(com.Parents, []string{first}) || com.Author != "Script Bot <bot@example.com>" {
+			t.Errorf("Unexpected commit %+v", com)
+		}
+		merge, err := NewCommitTreeCommand(testDir, treeHash, []string{first, second}, "merge").Write()
+		if err != nil {
+			t.Fatalf("Failed to write merge commit: %v", err)
+		}
+		if com, _ := commit.Read(repo.Objects, merge); com == nil || !slices.Equal(com.Parents, []string{first, second}) {
+			t.Errorf("Merge commit = %+v; want parents %s and %s", com, first, second)
+		}
+		if head, _ := refs.ReadRef(testDir, "refs/heads/main"); head.Target != "" {
+			t.Errorf("commit-tree should not move main, got %s", head.Target)
+		}
+	})
+
+	t.Run("1.3: update-ref", func(t *testing.T) {
+		repo := setup(t)
+		defer os.RemoveAll(testDir)
+
+		emptyTree, _ := tree.New().Write(repo.Objects)
+		first, _ := NewCommitTreeCommand(testDir, emptyTree, nil, "first").Write()
+		second, _ := NewCommitTreeCommand(testDir, emptyTree, []string{first}, "second").Write()
+		zero := repo.ObjectFormat.Zero()
+
+		if err := NewUpdateRefCommand(testDir, "HEAD", first, "").Execute(); err != nil {
+			t.Fatalf("Failed to update HEAD: %v", err)
+		}
+		if ref, _ := refs.ReadRef(testDir, "refs/heads/main"); ref.Target != first {
+			t.Errorf("Updating HEAD should move main to %s, got %s", first, ref.Target)
+		}
+
+		if err := NewUpdateRefCommand(testDir, "refs/heads/main", second, second).Execute(); err == nil {
+			t.Error("Expected error for a stale old value")
+		}
+		if err := NewUpdateRefCommand(testDir, "refs/heads/main", second[:8], first).Execute(); err != nil {
+			t.Errorf("Failed to update with matching old value: %v", err)
+		}
+		if ref, _ := refs.ReadRef(testDir, "refs/heads/main"); ref.Target != second {
+			t.Errorf("main = %s; want %s", ref.Target, second)
+		}
+
+		if err := NewUpdateRefCommand(testDir, "refs/heads/topic", first, zero).Execute(); err != nil {
+			t.Errorf("Failed to create new ref: %v", err)
+		}
+		if err := NewUpdateRefCommand(testDir, "refs/heads/topic", second, zero).Execute(); err == nil {
+			t.Error("Expected error creating a ref that exists")
+		}
+		if _, err := os.Stat(filepath.Join(repo.GitgoDir, "refs", "heads", "topic.lock")); !os.IsNotExist(err) {
+			t.Error("Lock file should be removed")
+		}
+		if err := NewUpdateRefCommand(testDir, "main", first, "").Execute(); err == nil {
+			t.Error("Expected error for a name outside refs/")
+		}
+	})
+	t.Run("1.4: Reproducible commits", func(t *testing.T) {
+		repo := setup(t)
+		defer os.RemoveAll(testDir)
+
+		t.Setenv(AuthorNameEnv, "Release Bot")
+		t.Setenv(AuthorEmailEnv, "release@example.com")
+		t.Setenv(AuthorDateEnv, "1700000000 +0100")
+		t.Setenv(CommitterNameEnv, "Pipeline")
+		t.Setenv(CommitterEmailEnv, "ci@example.com")
+		t.Setenv(CommitterDateEnv, "2023-11-15T00:00:00Z")
+
+		emptyTree, _ := tree.New().Write(repo.Objects)
+		first, err := NewCommitTreeCommand(testDir, emptyTree, nil, "release").Write()
+		if err != nil {
+			t.Fatalf("Failed to commit tree: %v", err)
+		}
+		obj, _ := repo.Objects.Get(first)
+		want := "tree " + emptyTree + "\n" +
+			"author Release Bot <release@example.com> 1700000000 +0100\n" +
+			"committer Pipeline <ci@example.com> 1700006400 +0000\n\nrelease"
+		if string(obj.Data) != want {
+			t.Errorf("Commit = %q; want %q", obj.Data, want)
+		}
+		if again, _ := NewCommitTreeCommand(testDir, emptyTree, nil, "release").Write(); again != first {
+			t.Errorf("Same inputs gave %s and %s", first, again)
+		}
+
+		com, _ := commit.Read(repo.Objects, first)
+		node, _ := newCommitSource(repo.GitgoDir, repo.Objects).lookup(first)
+		if node == nil || node.CommitTime != com.CommitterDate.Unix() || node.CommitTime == com.AuthorDate.Unix() {
+			t.Errorf("Commit time = %+v; want the committer date", node)
+		}
+
+		t.Setenv(AuthorDateEnv, "yesterday")
+		if _, err := NewCommitTreeCommand(testDir, emptyTree, nil, "release").Write(); err == nil {
+			t.Error("Expected error for an unparsable date")
+		}
+	})
+
+	t.Run("1.5: Date formats", func(t *testing.T) {
+		for _, tc := range []struct {
+			value string
+			unix  int64
+			zone  string
+		}{
+			{"1700000000 +0100", 1700000000, "+0100"},
+			{"@1700000000", 1700000000, "+0000"},
+			{"@1700000000 -0530", 1700000000, "-0530"},
+			{"2023-11-14T22:13:20Z", 1700000000, "+0000"},
+			{"2023-11-14 23:13:20 +0100", 1700000000, "+0100"},
+			{"Tue, 14 Nov 2023 23:13:20 +0100", 1700000000, "+0100"},
+		} {
+			date, err := parseDate(tc.value)
+			if err != nil || date.Unix() != tc.unix || date.Format("-0700") != tc.zone {
+				t.Errorf("parseDate(%q) = %v, %v; want %d %s", tc.value, date, err, tc.unix, tc.zone)
+			}
+		}
+		for _, value := range []string{"", "now", "1700000000 0100"} {
+			if _, err := parseDate(value); err == nil {
+				t.Errorf("Expected error for %q", value)
+			}
+		}
+	})
+}
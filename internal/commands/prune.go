@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HalilFocic/gitgo/internal/config"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+const (
+	// pruneExpireKey is the config key stock git uses for the same setting.
+	pruneExpireKey     = "gc.pruneExpire"
+	defaultPruneExpire = "2.weeks.ago"
+)
+
+// PruneCommand deletes loose objects that nothing references any more,
+// keeping those written within the expiry window so that objects of an
+// operation still in progress are not lost.
+type PruneCommand struct {
+	rootPath string
+	expire   string
+	dryRun   bool
+}
+
+// NewPruneCommand creates a prune command. expire is either "now",
+// "never", a duration such as "72h" or git's "2.weeks.ago" form; when
+// empty gc.pruneExpire from the config is used, falling back to two
+// weeks. With dryRun set objects are only listed.
+func NewPruneCommand(rootPath, expire string, dryRun bool) *PruneCommand {
+	return &PruneCommand{
+		rootPath: rootPath,
+		expire:   expire,
+		dryRun:   dryRun,
+	}
+}
+
+func (c *PruneCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	cutoff, err := pruneCutoff(repo.GitgoDir, c.expire, time.Now())
+	if err != nil {
+		return err
+	}
+	reachable, err := collectReachable(c.rootPath, repo.Objects)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool)
+	for _, r := range reachable {
+		keep[r.hash] = true
+	}
+
+	pruned, err := pruneLoose(repo.ObjectPath(), repo.ObjectFormat, keep, cutoff, c.dryRun)
+	if err != nil {
+		return err
+	}
+	if c.dryRun {
+		for _, hash := range pruned {
+			fmt.Println(hash)
+		}
+		fmt.Printf("Would prune %d objects\n", len(pruned))
+		return nil
+	}
+	fmt.Printf("Pruned %d objects\n", len(pruned))
+	return nil
+}
+
+// pruneCutoff returns the time before which unreachable objects may be
+// deleted. The zero time means nothing expires.
+func pruneCutoff(gitDir, expire string, now time.Time) (time.Time, error) {
+	if expire == "" {
+		cfg, err := config.Load(gitDir)
+		if err != nil {
+			return time.Time{}, err
+		}
+		expire = cfg.Get(pruneExpireKey)
+	}
+	if expire == "" {
+		expire = defaultPruneExpire
+	}
+	return parseExpiry(expire, now)
+}
+
+func parseExpiry(expire string, now time.Time) (time.Time, error) {
+	switch expire {
+	case "now":
+		return now, nil
+	case "never":
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(expire); err == nil {
+		return now.Add(-d), nil
+	}
+
+	units := map[string]time.Duration{
+		"second": time.Second,
+		"minute": time.Minute,
+		"hour":   time.Hour,
+		"day":    24 * time.Hour,
+		"week":   7 * 24 * time.Hour,
+		"month":  30 * 24 * time.Hour,
+		"year":   365 * 24 * time.Hour,
+	}
+	parts := strings.Split(expire, ".")
+	if len(parts) == 3 && parts[2] == "ago" {
+		n, err := strconv.Atoi(parts[0])
+		unit, ok := units[strings.TrimSuffix(parts[1], "s")]
+		if err == nil && n >= 0 && ok {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry %q", expire)
+}
+
+// pruneLoose removes loose objects not in keep whose modification time
+// is not after cutoff, along with stale temporary files from interrupted
+// writes. It returns the IDs of the pruned objects in sorted order; with
+// dryRun set nothing is removed.
+func pruneLoose(objectsPath string, format objectformat.Format, keep map[string]bool, cutoff time.Time, dryRun bool) ([]string, error) {
+	if cutoff.IsZero() {
+		return nil, nil
+	}
+	expired := func(path string) bool {
+		info, err := os.Stat(path)
+		return err == nil && !info.ModTime().After(cutoff)
+	}
+
+	dirs, err := os.ReadDir(objectsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read objects directory: %v", err)
+	}
+	var pruned []string
+	for _, dir := range dirs {
+		dirPath := filepath.Join(objectsPath, dir.Name())
+		if !dir.IsDir() {
+			if strings.HasPrefix(dir.Name(), "tmp_") && expired(dirPath) && !dryRun {
+				os.Remove(dirPath)
+			}
+			continue
+		}
+		if len(dir.Name()) != 2 {
+			continue
+		}
+		files, err := os.ReadDir(dirPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read objects directory: %v", err)
+		}
+		for _, file := range files {
+			hash := dir.Name() + file.Name()
+			filePath := filepath.Join(dirPath, file.Name())
+			if !format.IsValid(hash) || keep[hash] || !expired(filePath) {
+				continue
+			}
+			pruned = append(pruned, hash)
+			if dryRun {
+				continue
+			}
+			if err := os.Remove(filePath); err != nil {
+				return nil, fmt.Errorf("failed to remove object %s: %v", hash, err)
+			}
+		}
+		if !dryRun {
+			// Only succeeds once the fan-out directory is empty.
+			os.Remove(dirPath)
+		}
+	}
+	sort.Strings(pruned)
+	return pruned, nil
+}
@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/HalilFocic/gitgo/internal/blob"
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/staging"
+)
+
+func TestPruneCommand(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDir := filepath.Join(cwd, "testdata")
+
+	// setup commits one file and stores an unreachable blob and commit,
+	// both backdated a month.
+	setup := func(t *testing.T) (*repository.Repository, string, string) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		repo, err := repository.Init(testDir)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		os.WriteFile(filepath.Join(testDir, "main.go"), []byte("main content"), 0644)
+		idx, _ := staging.New(testDir)
+		if err := idx.Add("main.go"); err != nil {
+			t.Fatalf("Failed to stage file: %v", err)
+		}
+		if err := NewCommitCommand(testDir, "first", "Test User <test@example.com>", false).Execute(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+
+		head, _ := refs.ReadRef(testDir, "refs/heads/main")
+		com, _ := commit.Read(repo.Objects, head.Target)
+		orphanCommit, _ := commit.New(com.TreeHash, nil, "Test User <test@example.com>", "orphan")
+		commitHash, err := orphanCommit.Write(repo.Objects)
+		if err != nil {
+			t.Fatalf("Failed to write commit: %v", err)
+		}
+		orphanBlob, _ := blob.New([]byte("orphan content"))
+		if err := orphanBlob.Store(repo.Objects); err != nil {
+			t.Fatalf("Failed to store blob: %v", err)
+		}
+
+		old := time.Now().Add(-30 * 24 * time.Hour)
+		for _, hash := range []string{commitHash, orphanBlob.Hash()} {
+			path, _ := object.Path(repo.ObjectPath(), hash)
+			os.Chtimes(path, old, old)
+		}
+		return repo, commitHash, orphanBlob.Hash()
+	}
+
+	exists := func(repo *repository.Repository, hash string) bool {
+		path, _ := object.Path(repo.ObjectPath(), hash)
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	t.Run("1.1: Prune expired unreachable objects", func(t *testing.T) {
+		repo, commitHash, blobHash := setup(t)
+		defer os.RemoveAll(testDir)
+
+		if err := NewPruneCommand(testDir, "", true).Execute(); err != nil {
+			t.Fatalf("Failed to run dry run: %v", err)
+		}
+		if !exists(repo, commitHash) || !exists(repo, blobHash) {
+			t.Fatal("Dry run should not delete anything")
+		}
+
+		if err := NewPruneCommand(testDir, "", false).Execute(); err != nil {
+			t.Fatalf("Failed to prune: %v", err)
+		}
+		if exists(repo, commitHash) || exists(repo, blobHash) {
+			t.Error("Expired unreachable objects should be pruned")
+		}
+		report, err := NewFsckCommand(testDir, false).Check()
+		if err != nil {
+			t.Fatalf("Failed to run fsck: %v", err)
+		}
+		if report.Checked != 3 || len(report.Missing) != 0 {
+			t.Errorf("Reachable objects damaged by prune: %+v", report)
+		}
+	})
+
+	t.Run("1.2: Grace period", func(t *testing.T) {
+		repo, commitHash, blobHash := setup(t)
+		defer os.RemoveAll(testDir)
+
+		recent, _ := blob.New([]byte("recent content"))
+		recent.Store(repo.Objects)
+
+		if err := NewPruneCommand(testDir, "2.weeks.ago", false).Execute(); err != nil {
+			t.Fatalf("Failed to prune: %v", err)
+		}
+		if !exists(repo, recent.Hash()) {
+			t.Error("Objects newer than the expiry should be kept")
+		}
+		if exists(repo, commitHash) || exists(repo, blobHash) {
+			t.Error("Objects older than the expiry should be pruned")
+		}
+
+		if err := NewPruneCommand(testDir, "never", false).Execute(); err != nil {
+			t.Fatalf("Failed to prune: %v", err)
+		}
+		if !exists(repo, recent.Hash()) {
+			t.Error("Nothing should be pruned with never")
+		}
+	})
+
+	t.Run("1.3: Reflogs and the index keep objects", func(t *testing.T) {
+		repo, commitHash, blobHash := setup(t)
+		defer os.RemoveAll(testDir)
+
+		logPath := filepath.Join(repo.GitgoDir, "logs", "refs", "heads", "main")
+		os.MkdirAll(filepath.Dir(logPath), 0755)
+		zero := repo.ObjectFormat.Zero()
+		line := zero + " " + commitHash + " Test User <test@example.com> 1700000000 +0000\tcommit: orphan\n"
+		os.WriteFile(logPath, []byte(line), 0644)
+
+		os.WriteFile(filepath.Join(testDir, "orphan.txt"), []byte("orphan content"), 0644)
+		idx, _ := staging.New(testDir)
+		if err := idx.Add("orphan.txt"); err != nil {
+			t.Fatalf("Failed to stage file: %v", err)
+		}
+		path, _ := object.Path(repo.ObjectPath(), blobHash)
+		old := time.Now().Add(-30 * 24 * time.Hour)
+		os.Chtimes(path, old, old)
+
+		if err := NewGCCommand(testDir, "now").Execute(); err != nil {
+			t.Fatalf("Failed to run gc: %v", err)
+		}
+		for _, hash := range []string{commitHash, blobHash} {
+			if !repo.Objects.Has(hash) {
+				t.Errorf("Object %s should survive gc", hash)
+			}
+		}
+	})
+
+	t.Run("1.4: Expiry formats", func(t *testing.T) {
+		now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+		cases := map[string]time.Time{
+			"now":         now,
+			"never":       {},
+			"72h":         now.Add(-72 * time.Hour),
+			"2.weeks.ago": now.Add(-14 * 24 * time.Hour),
+			"1.day.ago":   now.Add(-24 * time.Hour),
+		}
+		for expire, want := range cases {
+			got, err := parseExpiry(expire, now)
+			if err != nil || !got.Equal(want) {
+				t.Errorf("parseExpiry(%q) = %v, %v; want %v", expire, got, err, want)
+			}
+		}
+		for _, expire := range []string{"soon", "2.fortnights.ago", "x.days.ago"} {
+			if _, err := parseExpiry(expire, now); err == nil {
+				t.Errorf("Expected error for %q", expire)
+			}
+		}
+	})
+}
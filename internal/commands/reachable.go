@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/staging"
+	"github.com/HalilFocic/gitgo/internal/tag"
+	"github.com/HalilFocic/gitgo/internal/tree"
+)
+
+type reachableObject struct {
+	hash       string
+	objectType string
+	path       string
+}
+
+type reachableWalker struct {
+	store   object.ObjectStore
+	commits *commitSource
+	seen    map[string]bool
+	objects []reachableObject
+}
+
+// collectReachable walks every commit reachable from the references, a
+// detached HEAD and the reflogs together with their trees and blobs,
+// following annotated tags, and adds the blobs staged in the index. Objects are returned in the order first visited.
+func collectReachable(rootPath string, objects object.ObjectStore) ([]reachableObject, error) {
+	w := &reachableWalker{
+		store:   objects,
+		commits: newCommitSource(repository.GitDir(rootPath), objects),
+		seen:    make(map[string]bool),
+	}
+
+	names, err := refs.ListRefs(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	names = append(names, refs.HeadFile)
+	for _, name := range names {
+		ref, err := refs.ReadRef(rootPath, name)
+		if err != nil {
+			return nil, err
+		}
+		if ref.Type == refs.RefTypeSymbolic || ref.Target == "" {
+			continue
+		}
+		if err := w.walk(ref.Target); err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %v", name, err)
+		}
+	}
+
+	// Reflogs keep recently replaced commits alive. Entries whose objects
+	// are already gone are skipped rather than treated as corruption.
+	reflog, err := refs.ReflogHashes(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range reflog {
+		if !w.store.Has(hash) {
+			continue
+		}
+		if err := w.walk(hash); err != nil {
+			return nil, fmt.Errorf("failed to walk reflog entry %s: %v", hash, err)
+		}
+	}
+
+	index, err := staging.New(rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staging area: %v", err)
+	}
+	for _, entry := range index.Entries() {
+		w.add(entry.Hash, object.TypeBlob, entry.Path)
+	}
+	return w.objects, nil
+}
+
+func (w *reachableWalker) add(hash, objectType, path string) bool {
+	if w.seen[hash] {
+		return false
+	}
+	w.seen[hash] = true
+	w.objects = append(w.objects, reachableObject{
+		hash:       hash,
+		objectType: objectType,
+		path:       path,
+	})
+	return true
+}
+
+// walk visits the object a reference points at, which is usually a
+// commit but may be an annotated tag or, for tags, any other object.
+func (w *reachableWalker) walk(hash string) error {
+	for {
+		objectType, _, err := object.ReadHeader(w.store, hash)
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %v", hash, err)
+		}
+		switch objectType {
+		case object.TypeCommit:
+			return w.walkCommits(hash)
+		case object.TypeTree:
+			return w.walkTree(hash, "")
+		case object.TypeBlob:
+			w.add(hash, object.TypeBlob, "")
+			return nil
+		}
+		if !w.add(hash, object.TypeTag, "") {
+			return nil
+		}
+		t, err := tag.Read(w.store, hash)
+		if err != nil {
+			return fmt.Errorf("failed to read tag %s: %v", hash, err)
+		}
+		hash = t.Object
+	}
+}
+
+// walkCommits visits hash and its ancestors, taking trees and parents
+// from the commit-graph where it covers them.
+func (w *reachableWalker) walkCommits(hash string) error {
+	stack := []string{hash}
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !w.add(hash, object.TypeCommit, "") {
+			continue
+		}
+		c, err := w.commits.lookup(hash)
+		if err != nil {
+			return err
+		}
+		if err := w.walkTree(c.TreeHash, ""); err != nil {
+			return err
+		}
+		for i := len(c.Parents) - 1; i >= 0; i-- {
+			stack = append(stack, c.Parents[i])
+		}
+	}
+	return nil
+}
+
+func (w *reachableWalker) walkTree(hash, prefix string) error {
+	if !w.add(hash, object.TypeTree, prefix) {
+		return nil
+	}
+	t, err := tree.Read(w.store, hash)
+	if err != nil {
+		return fmt.Errorf("failed to read tree %s: %v", hash, err)
+	}
+	for _, entry := range t.Entries() {
+		entryPath := path.Join(prefix, entry.Name)
+		switch entry.Mode {
+		case tree.DirectoryMode:
+			if err := w.walkTree(entry.Hash, entryPath); err != nil {
+				return err
+			}
+		case tree.GitlinkMode:
+			// Submodule commits live in another repository.
+		default:
+			w.add(entry.Hash, object.TypeBlob, entryPath)
+		}
+	}
+	return nil
+}
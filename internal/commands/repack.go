@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+type RepackCommand struct {
+	rootPath string
+	all      bool
+}
+
+// NewRepackCommand creates a repack command. Without all only objects
+// stored in the repository itself are packed; with all, objects borrowed
+// from alternates are copied into the new pack as well, after which the
+// alternates file can be removed.
+func NewRepackCommand(rootPath string, all bool) *RepackCommand {
+	return &RepackCommand{
+		rootPath: rootPath,
+		all:      all,
+	}
+}
+
+// Execute packs every reachable object into a single new pack and deletes
+// the loose copies and older packs it covers. Unreachable objects are
+// left for prune.
+func (c *RepackCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	if repo.Encrypted {
+		return fmt.Errorf("cannot repack an encrypted repository, packs are not encrypted")
+	}
+
+	reachable, err := collectReachable(c.rootPath, repo.Objects)
+	if err != nil {
+		return err
+	}
+	local := localObjects(repo, reachable)
+	borrowed := len(reachable) - len(local)
+	if !c.all {
+		reachable = local
+	}
+	if len(reachable) == 0 {
+		fmt.Println("Nothing to pack")
+		return nil
+	}
+	if err := packObjects(repo, reachable); err != nil {
+		return err
+	}
+
+	if c.all && borrowed > 0 {
+		fmt.Printf("Copied %d borrowed objects, objects/%s can now be removed\n",
+			borrowed, object.AlternatesFile)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/tag"
+)
+
+// resolveRevision turns name into an object ID. name may be HEAD, a full
+// reference such as refs/heads/main, a branch or tag name, or a full or
+// abbreviated object ID.
+func resolveRevision(rootPath string, repo *repository.Repository, name string) (string, error) {
+	candidates := []string{name}
+	if name != refs.HeadFile && !strings.HasPrefix(name, refs.RefsDir+"/") {
+		candidates = []string{refs.HeadsDir + "/" + name, refs.TagsDir + "/" + name}
+	}
+	for _, refName := range candidates {
+		if hash, err := readRefTarget(rootPath, refName); err == nil && hash != "" {
+			return hash, nil
+		}
+	}
+
+	hash, err := object.Resolve(repo.Objects, name)
+	if err != nil {
+		if _, ambiguous := err.(*object.AmbiguousError); ambiguous {
+			return "", err
+		}
+		return "", fmt.Errorf("unknown revision %s", name)
+	}
+	return hash, nil
+}
+
+// resolveCommit resolves name like resolveRevision and peels annotated
+// tags down to the commit they point at.
+func resolveCommit(rootPath string, repo *repository.Repository, name string) (string, error) {
+	hash, err := resolveRevision(rootPath, repo, name)
+	if err != nil {
+		return "", err
+	}
+	return peelToCommit(repo.Objects, hash)
+}
+
+// peelToCommit follows annotated tags from hash until it reaches an
+// object that is not a tag, which must be a commit.
+func peelToCommit(objects object.ObjectStore, hash string) (string, error) {
+	for depth := 0; depth < 10; depth++ {
+		objectType, _, err := object.ReadHeader(objects, hash)
+		if err != nil {
+			return "", err
+		}
+		switch objectType {
+		case object.TypeCommit:
+			return hash, nil
+		case object.TypeTag:
+			t, err := tag.Read(objects, hash)
+			if err != nil {
+				return "", fmt.Errorf("failed to read tag %s: %v", hash, err)
+			}
+			hash = t.Object
+		default:
+			return "", fmt.Errorf("%s is a %s, not a commit", hash, objectType)
+		}
+	}
+	return "", fmt.Errorf("too many levels of tags at %s", hash)
+}
+
+// readRefTarget reads name and follows symbolic references to the object
+// ID they end at. An unborn branch resolves to "".
+func readRefTarget(rootPath, name string) (string, error) {
+	for depth := 0; depth < 5; depth++ {
+		ref, err := refs.ReadRef(rootPath, name)
+		if err != nil {
+			return "", err
+		}
+		if ref.Type != refs.RefTypeSymbolic {
+			return ref.Target, nil
+		}
+		name = ref.Target
+	}
+	return "", fmt.Errorf("too many levels of symbolic references at %s", name)
+}
+
+// abbreviate shortens hash to its shortest unique prefix of at least
+// object.DefaultAbbrev characters when enabled, and returns it unchanged
+// otherwise.
+func abbreviate(repo *repository.Repository, hash string, enabled bool) string {
+	if !enabled {
+		return hash
+	}
+	short, err := object.Abbreviate(repo.Objects, hash, object.DefaultAbbrev)
+	if err != nil {
+		return hash
+	}
+	return short
+}
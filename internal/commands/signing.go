@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/config"
+	"github.com/HalilFocic/gitgo/internal/sshsig"
+)
+
+const (
+	SigningKeyEnv     = "GITGO_SIGNING_KEY"
+	AllowedSignersEnv = "GITGO_ALLOWED_SIGNERS"
+
+	// signatureNamespace is the SSHSIG namespace git signs commits in.
+	signatureNamespace = "git"
+)
+
+// signingKey loads the ed25519 private key named by GITGO_SIGNING_KEY or
+// user.signingKey. Like git, the key may be given by its .pub file as
+// long as the private key sits next to it.
+func signingKey(gitDir string) (ed25519.PrivateKey, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	path := firstNonEmpty(os.Getenv(SigningKeyEnv), cfg.Get("user.signingKey"))
+	if path == "" {
+		return nil, fmt.Errorf("no signing key configured, set user.signingKey or %s", SigningKeyEnv)
+	}
+	path = strings.TrimSuffix(expandHome(path), ".pub")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %v", err)
+	}
+	key, err := sshsig.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing key %s: %v", path, err)
+	}
+	return key, nil
+}
+
+// signCommit stores an SSH signature of com's payload in com. It must
+// run after every other field of com is final.
+func signCommit(gitDir string, com *commit.Commit) error {
+	key, err := signingKey(gitDir)
+	if err != nil {
+		return err
+	}
+	com.SetSignature(sshsig.Sign(key, signatureNamespace, com.Payload()))
+	return nil
+}
+
+// allowedSigners reads the file named by GITGO_ALLOWED_SIGNERS or
+// gpg.ssh.allowedSignersFile.
+func allowedSigners(gitDir string) (sshsig.AllowedSigners, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	path := firstNonEmpty(os.Getenv(AllowedSignersEnv), cfg.Get("gpg.ssh.allowedSignersFile"))
+	if path == "" {
+		return nil, fmt.Errorf("no allowed signers file configured, set gpg.ssh.allowedSignersFile or %s", AllowedSignersEnv)
+	}
+	signers, err := sshsig.ReadAllowedSigners(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowed signers: %v", err)
+	}
+	return signers, nil
+}
+
+// verifySignature checks the signature of com against signers and
+// returns the line git prints for a good signature. Like git, it checks
+// the validity of the signer at the commit's committer date.
+func verifySignature(signers sshsig.AllowedSigners, com *commit.Commit) (string, error) {
+	signature := com.Signature()
+	if signature == "" {
+		return "", fmt.Errorf("no signature found")
+	}
+	if !strings.HasPrefix(signature, sshsig.BeginArmor) {
+		return "", fmt.Errorf("only SSH signatures can be verified")
+	}
+	key, err := sshsig.Verify(signature, signatureNamespace, com.Payload())
+	if err != nil {
+		return "", fmt.Errorf("bad signature: %v", err)
+	}
+	fingerprint := sshsig.Fingerprint(key)
+	principal, ok := signers.Principal(key, signatureNamespace, com.CommitterDate)
+	if !ok {
+		return "", fmt.Errorf("signature made with ED25519 key %s, which is not an allowed signer", fingerprint)
+	}
+	return fmt.Sprintf("Good %q signature for %s with ED25519 key %s", signatureNamespace, principal, fingerprint), nil
+}
+
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
@@ -0,0 +1,194 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/refs"
+)
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test User", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test User", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+func TestStockGitRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	t.Run("1.1: Log and checkout a .git repository", func(t *testing.T) {
+		cwd, _ := os.Getwd()
+		testDir := filepath.Join(cwd, "testdata")
+		os.RemoveAll(testDir)
+		os.MkdirAll(filepath.Join(testDir, "lib"), 0755)
+		defer os.RemoveAll(testDir)
+
+		runGit(t, testDir, "init", "-q", "-b", "main")
+		os.WriteFile(filepath.Join(testDir, "main.go"), []byte("first"), 0644)
+		os.WriteFile(filepath.Join(testDir, "lib", "util.go"), []byte("util"), 0644)
+		runGit(t, testDir, "add", ".")
+		runGit(t, testDir, "commit", "-q", "-m", "first")
+		runGit(t, testDir, "branch", "old")
+		os.WriteFile(filepath.Join(testDir, "main.go"), []byte("second"), 0644)
+		runGit(t, testDir, "commit", "-q", "-a", "-m", "second")
+
+		if err := NewLogCommand(testDir, -1, "", false, false).Execute(); err != nil {
+			t.Fatalf("Failed to run log: %v", err)
+		}
+
+		if err := NewCheckoutCommand(testDir, "old").Execute(); err != nil {
+			t.Fatalf("Failed to checkout: %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(testDir, "main.go"))
+		if err != nil || string(content) != "first" {
+			t.Errorf("main.go = %q, %v; want %q", content, err, "first")
+		}
+		if _, err := os.ReadFile(filepath.Join(testDir, "lib", "util.go")); err != nil {
+			t.Errorf("Nested file not restored: %v", err)
+		}
+		head, err := refs.ReadHead(testDir)
+		if err != nil || head.Target != "refs/heads/main" {
+			t.Errorf("HEAD = %+v, %v; want it left at refs/heads/main", head, err)
+		}
+		if _, err := os.Stat(filepath.Join(testDir, ".git", "objects")); err != nil {
+			t.Errorf("Checkout must not touch the .git directory: %v", err)
+		}
+	})
+
+	t.Run("1.2: Read a garbage collected .git repository", func(t *testing.T) {
+		cwd, _ := os.Getwd()
+		testDir := filepath.Join(cwd, "testdata")
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		defer os.RemoveAll(testDir)
+
+		runGit(t, testDir, "init", "-q", "-b", "main")
+		os.WriteFile(filepath.Join(testDir, "main.go"), []byte("first"), 0644)
+		os.Symlink("main.go", filepath.Join(testDir, "link"))
+		runGit(t, testDir, "add", ".")
+		runGit(t, testDir, "commit", "-q", "-m", "first")
+		runGit(t, testDir, "branch", "old")
+		runGit(t, testDir, "tag", "v1")
+		os.WriteFile(filepath.Join(testDir, "main.go"), []byte("second"), 0644)
+		runGit(t, testDir, "commit", "-q", "-a", "-m", "second")
+		runGit(t, testDir, "gc", "-q")
+
+		gitDir := filepath.Join(testDir, ".git")
+		if _, err := os.Stat(filepath.Join(gitDir, "refs", "heads", "old")); !os.IsNotExist(err) {
+			t.Fatalf("Expected git gc to pack refs/heads/old: %v", err)
+		}
+		packedRefs, err := os.ReadFile(filepath.Join(gitDir, "packed-refs"))
+		if err != nil {
+			t.Fatalf("Failed to read packed-refs: %v", err)
+		}
+		index, err := os.ReadFile(filepath.Join(gitDir, "index"))
+		if err != nil {
+			t.Fatalf("Failed to read index: %v", err)
+		}
+
+		if err := NewLogCommand(testDir, -1, "", false, false).Execute(); err != nil {
+			t.Fatalf("Failed to run log: %v", err)
+		}
+		if err := NewCheckoutCommand(testDir, "old").Execute(); err != nil {
+			t.Fatalf("Failed to checkout a packed branch: %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(testDir, "main.go"))
+		if err != nil || string(content) != "first" {
+			t.Errorf("main.go = %q, %v; want %q", content, err, "first")
+		}
+		if target, err := os.Readlink(filepath.Join(testDir, "link")); err != nil || target != "main.go" {
+			t.Errorf("link = %q, %v; want a symlink to main.go", target, err)
+		}
+
+		if err := refs.UpdateRef(testDir, "refs/heads/new", "1234567890123456789012345678901234567890", false); err == nil {
+			t.Error("Expected error writing a reference into .git")
+		}
+		if err := refs.DeleteBranch(testDir, "old"); err == nil {
+			t.Error("Expected error deleting a branch from .git")
+		}
+		if err := refs.DeleteTag(testDir, "v1"); err == nil {
+			t.Error("Expected error deleting a tag from .git")
+		}
+		if err := NewCommitCommand(testDir, "third", "", false).Execute(); err == nil {
+			t.Error("Expected error committing into .git")
+		}
+
+		head, _ := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+		if string(head) != "ref: refs/heads/main\n" {
+			t.Errorf(".git/HEAD = %q; want it left at refs/heads/main", head)
+		}
+		if got, _ := os.ReadFile(filepath.Join(gitDir, "packed-refs")); string(got) != string(packedRefs) {
+			t.Errorf("packed-refs changed:\n%s\nwant:\n%s", got, packedRefs)
+		}
+		if got, _ := os.ReadFile(filepath.Join(gitDir, "index")); string(got) != string(index) {
+			t.Error("Index of the .git repository was rewritten")
+		}
+		if _, err := os.Stat(filepath.Join(gitDir, "refs", "heads", "new")); !os.IsNotExist(err) {
+			t.Errorf("Reference written into .git: %v", err)
+		}
+	})
+}
+
+func TestStockGitPacks(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	cases := []struct {
+		desc   string
+		repack []string
+	}{
+		{"OFS_DELTA", []string{"repack", "-a", "-d", "-f", "-q"}},
+		{"REF_DELTA", []string{"-c", "repack.useDeltaBaseOffset=false", "repack", "-a", "-d", "-f", "-q"}},
+	}
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("2.%d: Read a pack using %s", i+1, tc.desc), func(t *testing.T) {
+			cwd, _ := os.Getwd()
+			testDir := filepath.Join(cwd, "testdata")
+			os.RemoveAll(testDir)
+			os.MkdirAll(testDir, 0755)
+			defer os.RemoveAll(testDir)
+
+			runGit(t, testDir, "init", "-q", "-b", "main")
+			content := strings.Repeat("a line that stays the same\n", 200)
+			for version := 0; version < 5; version++ {
+				content += fmt.Sprintf("version %d\n", version)
+				os.WriteFile(filepath.Join(testDir, "file.txt"), []byte(content), 0644)
+				runGit(t, testDir, "add", ".")
+				runGit(t, testDir, "commit", "-q", "-m", fmt.Sprintf("version %d", version))
+			}
+			runGit(t, testDir, tc.repack...)
+
+			loose, _ := filepath.Glob(filepath.Join(testDir, ".git", "objects", "??"))
+			if len(loose) != 0 {
+				t.Fatalf("Expected git to pack every object, %d fan-out directories left", len(loose))
+			}
+
+			if err := NewLogCommand(testDir, -1, "", false, false).Execute(); err != nil {
+				t.Fatalf("Failed to run log on packed repository: %v", err)
+			}
+			os.Remove(filepath.Join(testDir, "file.txt"))
+			if err := NewCheckoutCommand(testDir, "main").Execute(); err != nil {
+				t.Fatalf("Failed to checkout packed repository: %v", err)
+			}
+			got, _ := os.ReadFile(filepath.Join(testDir, "file.txt"))
+			if string(got) != content {
+				t.Errorf("Checked out content mismatch")
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/tag"
+)
+
+type TagCommand struct {
+	rootPath string
+	name     string
+	target   string
+	message  string
+	tagger   string
+	action   string
+}
+
+// NewTagCommand creates a tag command. action is one of "create",
+// "delete", "list" or "show". When creating, target defaults to HEAD and
+// a non-empty message makes an annotated tag object signed by tagger;
+// otherwise the tag is a lightweight reference. Like git, an empty tagger
+// is the committer identity from the environment or the config.
+func NewTagCommand(rootPath, name, target, message, tagger, action string) *TagCommand {
+	return &TagCommand{
+		rootPath: rootPath,
+		name:     name,
+		target:   target,
+		message:  message,
+		tagger:   tagger,
+		action:   action,
+	}
+}
+
+func (c *TagCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+
+	switch c.action {
+	case "create":
+		target := c.target
+		if target == "" {
+			target = refs.HeadFile
+		}
+		hash, err := resolveRevision(c.rootPath, repo, target)
+		if err != nil {
+			return err
+		}
+		if c.message != "" {
+			objectType, _, err := object.ReadHeader(repo.Objects, hash)
+			if err != nil {
+				return fmt.Errorf("failed to read object %s: %v", hash, err)
+			}
+			tagger := c.tagger
+			if tagger == "" {
+				if tagger, err = committerIdent(repo.GitgoDir); err != nil {
+					return err
+				}
+			}
+			t, err := tag.New(hash, objectType, c.name, tagger, c.message)
+			if err != nil {
+				return fmt.Errorf("failed to create tag: %v", err)
+			}
+			// Like git, the tagger date follows the committer date.
+			if t.TaggerDate, err = envDate(CommitterDateEnv, t.TaggerDate); err != nil {
+				return err
+			}
+			if hash, err = t.Write(repo.Objects); err != nil {
+				return fmt.Errorf("failed to write tag: %v", err)
+			}
+		}
+		if err := refs.CreateTag(c.rootPath, c.name, hash); err != nil {
+			return fmt.Errorf("failed to create tag: %v", err)
+		}
+
+	case "delete":
+		if err := refs.DeleteTag(c.rootPath, c.name); err != nil {
+			return fmt.Errorf("failed to delete tag: %v", err)
+		}
+
+	case "list":
+		tags, err := refs.ListTags(c.rootPath)
+		if err != nil {
+			return fmt.Errorf("failed to list tags: %v", err)
+		}
+		for _, name := range tags {
+			fmt.Println(name)
+		}
+
+	case "show":
+		ref, err := refs.ReadRef(c.rootPath, refs.TagsDir+"/"+c.name)
+		if err != nil {
+			return fmt.Errorf("tag %s does not exist", c.name)
+		}
+		return c.show(repo, ref.Target)
+
+	default:
+		return fmt.Errorf("unknown tag action: %s", c.action)
+	}
+
+	return nil
+}
+
+// show prints the annotated tags along the chain starting at hash,
+// followed by the object they end at.
+func (c *TagCommand) show(repo *repository.Repository, hash string) error {
+	for {
+		obj, err := repo.Objects.Get(hash)
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %v", hash, err)
+		}
+		switch obj.Type {
+		case object.TypeTag:
+			t, err := tag.Parse(obj.Data)
+			if err != nil {
+				return fmt.Errorf("failed to parse tag: %v", err)
+			}
+			fmt.Printf("tag %s\n", t.Name)
+			fmt.Printf("Tagger: %s\n", t.Tagger)
+			fmt.Printf("Date: %v\n", t.TaggerDate.Format("Mon Jan 2 15:04:05 2006 -0700"))
+			fmt.Printf("\n%s\n\n", strings.TrimRight(t.Message, "\n"))
+			hash = t.Object
+		case object.TypeCommit:
+			com, err := commit.Parse(obj.Data)
+			if err != nil {
+				return fmt.Errorf("failed to parse commit: %v", err)
+			}
+			fmt.Printf("commit %s\n", hash)
+			fmt.Printf("Author: %s\n", com.Author)
+			fmt.Printf("Date: %v\n", com.AuthorDate.Format("Mon Jan 2 15:04:05 2006 -0700"))
+			fmt.Printf("\n%s\n", indentMessage(com.Message))
+			return nil
+		default:
+			return printObject(obj, repo.ObjectFormat)
+		}
+	}
+}
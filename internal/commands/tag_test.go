@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/staging"
+	"github.com/HalilFocic/gitgo/internal/tag"
+)
+
+func TestTagCommand(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDir := filepath.Join(cwd, "testdata")
+
+	// setup makes two commits of main.go and returns their IDs.
+	setup := func(t *testing.T) (*repository.Repository, []string) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		repo, err := repository.Init(testDir)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		var commits []string
+		for _, content := range []string{"first", "second"} {
+			os.WriteFile(filepath.Join(testDir, "main.go"), []byte(content), 0644)
+			idx, _ := staging.New(testDir)
+			if err := idx.Add("main.go"); err != nil {
+				t.Fatalf("Failed to stage file: %v", err)
+			}
+			if err := NewCommitCommand(testDir, content, "Test User <test@example.com>", false).Execute(); err != nil {
+				t.Fatalf("Failed to commit: %v", err)
+			}
+			head, _ := refs.ReadRef(testDir, "refs/heads/main")
+			commits = append(commits, head.Target)
+		}
+		return repo, commits
+	}
+
+	t.Run("1.1: Lightweight and annotated tags", func(t *testing.T) {
+		repo, commits := setup(t)
+		defer os.RemoveAll(testDir)
+
+		if err := NewTagCommand(testDir, "light", "", "", "", "create").Execute(); err != nil {
+			t.Fatalf("Failed to create lightweight tag: %v", err)
+		}
+		ref, _ := refs.ReadRef(testDir, "refs/tags/light")
+		if ref.Target != commits[1] {
+			t.Errorf("Lightweight tag points at %s; want HEAD %s", ref.Target, commits[1])
+		}
+
+		err := NewTagCommand(testDir, "v1", commits[0][:7], "First release", "Test User <test@example.com>", "create").Execute()
+		if err != nil {
+			t.Fatalf("Failed to create annotated tag: %v", err)
+		}
+		ref, _ = refs.ReadRef(testDir, "refs/tags/v1")
+		tg, err := tag.Read(repo.Objects, ref.Target)
+		if err != nil {
+			t.Fatalf("Annotated tag should point at a tag object: %v", err)
+		}
+		if tg.Object != commits[0] || tg.ObjectType != object.TypeCommit || tg.Name != "v1" || tg.Message != "First release" {
+			t.Errorf("Unexpected tag %+v", tg)
+		}
+
+		if err := NewTagCommand(testDir, "v1", "", "", "", "create").Execute(); err == nil {
+			t.Error("Expected error creating an existing tag")
+		}
+		if err := NewTagCommand(testDir, "v1", "", "", "", "show").Execute(); err != nil {
+			t.Errorf("Failed to show tag: %v", err)
+		}
+
+		report, err := NewFsckCommand(testDir, false).Check()
+		if err != nil {
+			t.Fatalf("Failed to run fsck: %v", err)
+		}
+		if len(report.BadRefs)+len(report.Missing)+len(report.Dangling) != 0 {
+			t.Errorf("Unexpected fsck problems: %+v", report)
+		}
+
+		if err := NewTagCommand(testDir, "v1", "", "", "", "delete").Execute(); err != nil {
+			t.Fatalf("Failed to delete tag: %v", err)
+		}
+		tags, _ := refs.ListTags(testDir)
+		if len(tags) != 1 || tags[0] != "light" {
+			t.Errorf("Tags = %v; want [light]", tags)
+		}
+	})
+
+	t.Run("1.2: Checkout and gc through tags", func(t *testing.T) {
+		repo, commits := setup(t)
+		defer os.RemoveAll(testDir)
+
+		err := NewTagCommand(testDir, "v1", commits[0], "First release", "Test User <test@example.com>", "create").Execute()
+		if err != nil {
+			t.Fatalf("Failed to create annotated tag: %v", err)
+		}
+		if err := NewCheckoutCommand(testDir, "v1").Execute(); err != nil {
+			t.Fatalf("Failed to checkout tag: %v", err)
+		}
+		head, _ := refs.ReadHead(testDir)
+		if head.Type != refs.RefTypeCommit || head.Target != commits[0] {
+			t.Errorf("HEAD = %+v; want detached at %s", head, commits[0])
+		}
+		content, _ := os.ReadFile(filepath.Join(testDir, "main.go"))
+		if string(content) != "first" {
+			t.Errorf("main.go = %q; want %q", content, "first")
+		}
+		if err := NewLogCommand(testDir, -1, "v1", false, false).Execute(); err != nil {
+			t.Errorf("Failed to log from tag: %v", err)
+		}
+
+		if err := NewCheckoutCommand(testDir, "main").Execute(); err != nil {
+			t.Fatalf("Failed to checkout main: %v", err)
+		}
+		ref, _ := refs.ReadRef(testDir, "refs/tags/v1")
+		if err := NewGCCommand(testDir, "now").Execute(); err != nil {
+			t.Fatalf("Failed to run gc: %v", err)
+		}
+		if !repo.Objects.Has(ref.Target) {
+			t.Error("Tag object should survive gc")
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+// TraceCacheEnv names the environment variable that, when set, makes
+// commands walking history report object cache statistics on stderr.
+const TraceCacheEnv = "GITGO_TRACE_CACHE"
+
+func traceObjectCache(repo *repository.Repository) {
+	if os.Getenv(TraceCacheEnv) == "" {
+		return
+	}
+	cache, ok := repo.Objects.(*object.CachedStore)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "object cache: disabled")
+		return
+	}
+	stats := cache.Stats()
+	fmt.Fprintf(os.Stderr, "object cache: %d hits, %d misses, %d objects, %d bytes\n",
+		stats.Hits, stats.Misses, stats.Objects, stats.Bytes)
+}
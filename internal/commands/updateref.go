@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/refs"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+// UpdateRefCommand points a reference at an object, optionally only when
+// it still points at an expected old value.
+type UpdateRefCommand struct {
+	rootPath string
+	name     string
+	newValue string
+	oldValue string
+}
+
+// NewUpdateRefCommand creates an update-ref command. name is HEAD or a
+// full reference such as refs/heads/main. newValue and oldValue are
+// revisions; an empty oldValue skips the check and an all-zero oldValue
+// requires that the reference does not exist yet.
+func NewUpdateRefCommand(rootPath, name, newValue, oldValue string) *UpdateRefCommand {
+	return &UpdateRefCommand{
+		rootPath: rootPath,
+		name:     name,
+		newValue: newValue,
+		oldValue: oldValue,
+	}
+}
+
+func (c *UpdateRefCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	name := c.name
+	if name != refs.HeadFile && (!strings.HasPrefix(name, refs.RefsDir+"/") || strings.Contains(name, "..")) {
+		return fmt.Errorf("invalid reference name %s", name)
+	}
+	// Updating HEAD moves the branch it points at, as in git.
+	if head, err := refs.ReadRef(c.rootPath, name); err == nil && head.Type == refs.RefTypeSymbolic {
+		name = head.Target
+	}
+
+	newHash, err := resolveRevision(c.rootPath, repo, c.newValue)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case c.oldValue == "":
+		return refs.UpdateRef(c.rootPath, name, newHash, false)
+	case strings.Trim(c.oldValue, "0") == "":
+		return refs.CompareAndSwapRef(c.rootPath, name, "", newHash)
+	}
+	oldHash, err := resolveRevision(c.rootPath, repo, c.oldValue)
+	if err != nil {
+		return err
+	}
+	return refs.CompareAndSwapRef(c.rootPath, name, oldHash, newHash)
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/repository"
+)
+
+type VerifyCommitCommand struct {
+	rootPath string
+	revision string
+}
+
+// NewVerifyCommitCommand creates a command that checks the SSH signature
+// of the commit revision resolves to against the allowed signers file.
+func NewVerifyCommitCommand(rootPath, revision string) *VerifyCommitCommand {
+	return &VerifyCommitCommand{
+		rootPath: rootPath,
+		revision: revision,
+	}
+}
+
+func (c *VerifyCommitCommand) Execute() error {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return err
+	}
+	hash, err := resolveCommit(c.rootPath, repo, c.revision)
+	if err != nil {
+		return err
+	}
+	com, err := commit.Read(repo.Objects, hash)
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %v", hash, err)
+	}
+	signers, err := allowedSigners(repo.GitgoDir)
+	if err != nil {
+		return err
+	}
+	good, err := verifySignature(signers, com)
+	if err != nil {
+		return fmt.Errorf("commit %s: %v", hash, err)
+	}
+	fmt.Println(good)
+	return nil
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/HalilFocic/gitgo/internal/repository"
+	"github.com/HalilFocic/gitgo/internal/staging"
+)
+
+// WriteTreeCommand writes the trees for the staged entries and prints the
+// ID of the root tree. Unlike commit it neither reads HEAD nor clears the
+// index.
+type WriteTreeCommand struct {
+	rootPath string
+}
+
+func NewWriteTreeCommand(rootPath string) *WriteTreeCommand {
+	return &WriteTreeCommand{
+		rootPath: rootPath,
+	}
+}
+
+func (c *WriteTreeCommand) Execute() error {
+	hash, err := c.Write()
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
+
+// Write stores the trees and returns the root tree ID.
+func (c *WriteTreeCommand) Write() (string, error) {
+	repo, err := repository.Open(c.rootPath)
+	if err != nil {
+		return "", err
+	}
+	index, err := staging.New(c.rootPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read staging area: %v", err)
+	}
+	root := groupEntriesByDirectory(index.Entries())
+	hash, err := createTreeFromNode(root, repo.Objects)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tree: %v", err)
+	}
+	return hash, nil
+}
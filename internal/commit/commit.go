@@ -2,38 +2,81 @@ package commit
 
 import (
 	"bytes"
-	"compress/zlib"
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
-	"io"
-	"os"
-	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
 )
 
 type Commit struct {
-	TreeHash   string
-	ParentHash string
+	TreeHash string
+	// Parents lists the parent commits in the order they are recorded.
+	// A root commit has none and a merge commit has more than one.
+	Parents    []string
 	Author     string
 	AuthorDate time.Time
-	Message    string
+	// Committer is written as the committer header when set. Commits
+	// from older gitgo versions have none; CommitterDate then repeats
+	// AuthorDate.
+	Committer     string
+	CommitterDate time.Time
+	// ExtraHeaders holds every header after the committer, such as
+	// encoding, mergetag and gpgsig, in the order they are written.
+	ExtraHeaders []Header
+	// Message is everything after the blank line ending the headers,
+	// byte for byte.
+	Message string
+	// noSeparator is set for commits read without the blank line after
+	// the headers, so that writing them back leaves it out too.
+	noSeparator bool
+	// authorRaw and committerRaw keep the dates as they were read.
+	authorRaw    RawDate
+	committerRaw RawDate
+}
+
+// Header is a commit header other than tree, parent, author and
+// committer. Value may span several lines; on disk each line after the
+// first is a continuation line starting with a space.
+type Header struct {
+	Name  string
+	Value string
 }
 
-func New(treeHash string, parentHash string, author string, message string) (*Commit, error) {
-	if len(treeHash) != 40 {
-		return nil, fmt.Errorf("expected treehash to have length 40, got %d", len(treeHash))
+// SignatureHeader is the header git stores commit signatures in, for
+// SSH signatures as much as for OpenPGP ones.
+const SignatureHeader = "gpgsig"
+
+// signatureHeaders are left out of the signed payload. Commits in
+// repositories being converted between hash functions may carry a
+// signature over each.
+var signatureHeaders = []string{SignatureHeader, "gpgsig-sha256"}
+
+func New(treeHash string, parents []string, author string, message string) (*Commit, error) {
+	format, err := objectformat.ForHash(treeHash)
+	if err != nil {
+		return nil, fmt.Errorf("expected treehash to have length %d or %d, got %d",
+			objectformat.SHA1.HexSize(), objectformat.SHA256.HexSize(), len(treeHash))
 	}
-	if !regexp.MustCompile(`^[0-9a-f]{40}$`).MatchString(treeHash) {
+	if !format.IsValid(treeHash) {
 		return nil, fmt.Errorf("tree hash must contain only hex characters")
 	}
-	if parentHash != "" && len(parentHash) != 40 {
-		return nil, fmt.Errorf("if present, parent hash must be 40 characters, got %d", len(parentHash))
-	}
-	if parentHash != "" && !regexp.MustCompile(`^[0-9a-f]{40}$`).MatchString(parentHash) {
-		return nil, fmt.Errorf("parent hash must contain only hex characters")
+	for i, parent := range parents {
+		if len(parent) != format.HexSize() {
+			return nil, fmt.Errorf("parent hash must be %d characters, got %d", format.HexSize(), len(parent))
+		}
+		if !format.IsValid(parent) {
+			return nil, fmt.Errorf("parent hash must contain only hex characters")
+		}
+		for _, earlier := range parents[:i] {
+			if earlier == parent {
+				return nil, fmt.Errorf("duplicate parent %s", parent)
+			}
+		}
 	}
 	if len(message) == 0 {
 		return nil, fmt.Errorf("commit message cannot be empty")
@@ -42,144 +85,285 @@ func New(treeHash string, parentHash string, author string, message string) (*Co
 	if !authorRegex.MatchString(author) {
 		return nil, fmt.Errorf("invalid author format, must be 'Name <email>'")
 	}
+	now := time.Now()
 	commit := Commit{
-		TreeHash:   treeHash,
-		ParentHash: parentHash,
-		Author:     author,
-		AuthorDate: time.Now(),
-		Message:    message,
+		TreeHash:      treeHash,
+		Parents:       parents,
+		Author:        author,
+		AuthorDate:    now,
+		Committer:     author,
+		CommitterDate: now,
+		Message:       message,
 	}
 	return &commit, nil
 
 }
 
-func (c *Commit) Write(objectsPath string) (string, error) {
-	timestamp := c.AuthorDate.Unix()
-	timezone := c.AuthorDate.Format("-0700")
-
-	content := fmt.Sprintf("tree %s\n", c.TreeHash)
-	if c.ParentHash != "" {
-		content += fmt.Sprintf("parent %s\n", c.ParentHash)
+func (c *Commit) Write(store object.ObjectStore) (string, error) {
+	format := store.Format()
+	if !format.IsValid(c.TreeHash) {
+		return "", fmt.Errorf("tree hash %s is not a %s hash", c.TreeHash, format.Name)
 	}
-	content += fmt.Sprintf("author %s %d %s\n\n%s",
-		c.Author,
-		timestamp,
-		timezone,
-		c.Message)
-	data := fmt.Sprintf("commit %d\x00%s", len(content), content)
-	var compressed bytes.Buffer
-	zw := zlib.NewWriter(&compressed)
-	if _, err := zw.Write([]byte(data)); err != nil {
-		return "", fmt.Errorf("failed to compress data: %v", err)
+	hash, err := store.Put(object.TypeCommit, c.encode(true))
+	if err != nil {
+		return "", err
 	}
-	zw.Close()
+	return hash, nil
+}
 
-	hash := sha1.Sum(compressed.Bytes())
-	hashStr := hex.EncodeToString(hash[:])
-	hashPath := filepath.Join(objectsPath, hashStr[:2], hashStr[2:])
-	if err := os.MkdirAll(filepath.Dir(hashPath), 0755); err != nil {
-		return "", fmt.Errorf("failed to create object directory: %v", err)
+// Header returns the value of the first extra header called name.
+func (c *Commit) Header(name string) (string, bool) {
+	for _, h := range c.ExtraHeaders {
+		if h.Name == name {
+			return h.Value, true
+		}
 	}
+	return "", false
+}
 
-	// Write file
-	if err := os.WriteFile(hashPath, compressed.Bytes(), 0644); err != nil {
-		return "", fmt.Errorf("failed to write object file: %v", err)
+// Signature returns the armored signature in the gpgsig header, or ""
+// for an unsigned commit.
+func (c *Commit) Signature() string {
+	value, ok := c.Header(SignatureHeader)
+	if !ok {
+		return ""
 	}
-
-	return hashStr, nil
+	return value + "\n"
 }
 
-func Read(objectsPath, hash string) (*Commit, error) {
-	hashPath := filepath.Join(objectsPath, hash[:2], hash[2:])
-	compressed, err := os.ReadFile(hashPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read object file: %v", err)
+// SetSignature stores an armored signature made over Payload, replacing
+// any earlier one. An empty signature removes it.
+func (c *Commit) SetSignature(signature string) {
+	value := strings.TrimSuffix(signature, "\n")
+	for i, h := range c.ExtraHeaders {
+		if h.Name == SignatureHeader {
+			if value == "" {
+				c.ExtraHeaders = slices.Delete(c.ExtraHeaders, i, i+1)
+			} else {
+				c.ExtraHeaders[i].Value = value
+			}
+			return
+		}
 	}
-
-	zr, err := zlib.NewReader(bytes.NewReader(compressed))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create zlib reader: %v", err)
+	if value != "" {
+		c.ExtraHeaders = append(c.ExtraHeaders, Header{Name: SignatureHeader, Value: value})
 	}
-	defer zr.Close()
+}
 
-	var buffer bytes.Buffer
-	if _, err := io.Copy(&buffer, zr); err != nil {
-		return nil, fmt.Errorf("failed to decompress data: %v", err)
-	}
+// Payload returns the commit as it is signed: every header except the
+// signatures themselves, and the message.
+func (c *Commit) Payload() []byte {
+	return c.encode(false)
+}
 
-	data := buffer.Bytes()
-	parts := bytes.SplitN(data, []byte{0}, 2)
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid format")
+func (c *Commit) encode(withSignature bool) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "tree %s\n", c.TreeHash)
+	for _, parent := range c.Parents {
+		fmt.Fprintf(&b, "parent %s\n", parent)
+	}
+	fmt.Fprintf(&b, "author %s\n", c.authorRaw.FormatIdent(c.Author, c.AuthorDate))
+	if c.Committer != "" {
+		fmt.Fprintf(&b, "committer %s\n", c.committerRaw.FormatIdent(c.Committer, c.CommitterDate))
+	}
+	for _, h := range c.ExtraHeaders {
+		if !withSignature && slices.Contains(signatureHeaders, h.Name) {
+			continue
+		}
+		b.WriteString(h.Name + " " + strings.ReplaceAll(h.Value, "\n", "\n ") + "\n")
 	}
+	if !c.noSeparator || c.Message != "" {
+		b.WriteString("\n" + c.Message)
+	}
+	return []byte(b.String())
+}
 
-	header := bytes.Fields(parts[0])
-	if len(header) != 2 || string(header[0]) != "commit" {
+func Read(store object.ObjectStore, hash string) (*Commit, error) {
+	obj, err := store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != object.TypeCommit {
 		return nil, fmt.Errorf("not a commit object")
 	}
+	return Parse(obj.Data)
+}
 
-	content := parts[1]
-	lines := bytes.Split(content, []byte{'\n'})
-
-	var treeHash, parentHash, author string
-	var authorTime time.Time
-	var message string
+// Parse decodes the body of a commit object, without its header. Headers
+// it does not interpret are kept in ExtraHeaders and the message is kept
+// as is, so that writing the commit back gives the same object ID.
+func Parse(content []byte) (*Commit, error) {
+	commit := &Commit{}
+	headers, message, found := bytes.Cut(content, []byte("\n\n"))
+	if !found {
+		// A commit with neither a message nor the blank line before it.
+		if headers, found = bytes.CutSuffix(content, []byte{'\n'}); !found {
+			return nil, fmt.Errorf("commit headers must end with a newline")
+		}
+		commit.noSeparator = true
+	}
+	commit.Message = string(message)
 
-	messageStart := 0
-	for i, line := range lines {
-		if len(line) == 0 {
-			messageStart = i + 1
-			break
+	// Headers are only interpreted in the order git writes them. Anything
+	// else, including a known header out of place, is kept in
+	// ExtraHeaders so that it is written back where it was.
+	const (
+		start = iota
+		afterTree
+		afterParents
+		afterAuthor
+		afterCommitter
+		inExtra
+	)
+	position := start
+	var extra *Header
+	for _, line := range strings.Split(string(headers), "\n") {
+		// Continuation lines of multi-line headers such as gpgsig and
+		// mergetag start with a space.
+		if rest, ok := strings.CutPrefix(line, " "); ok {
+			if extra == nil {
+				return nil, fmt.Errorf("continuation line outside of a header")
+			}
+			extra.Value += "\n" + rest
+			continue
 		}
 
-		fields := bytes.Fields(line)
-		if len(fields) < 2 {
+		name, value, found := strings.Cut(line, " ")
+		if !found {
 			return nil, fmt.Errorf("invalid line format")
 		}
-
-		switch string(fields[0]) {
-		case "tree":
-			treeHash = string(fields[1])
-		case "parent":
-			parentHash = string(fields[1])
-		case "author":
-			if len(fields) < 4 {
-				return nil, fmt.Errorf("invalid author line")
-			}
-			authorEnd := len(fields) - 2
-			author = string(bytes.Join(fields[1:authorEnd], []byte(" ")))
-
-			timestamp, err := strconv.ParseInt(string(fields[authorEnd]), 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("invalid timestamp: %v", err)
-			}
-
-			timezone := string(fields[authorEnd+1])
-			tzHours, err := strconv.Atoi(timezone[1:3])
-			if err != nil {
-				return nil, fmt.Errorf("invalid timezone hours: %v", err)
+		switch {
+		case name == "tree" && position == start:
+			commit.TreeHash = value
+			position = afterTree
+		case name == "parent" && (position == afterTree || position == afterParents):
+			commit.Parents = append(commit.Parents, value)
+			position = afterParents
+		case name == "author" && (position == afterTree || position == afterParents):
+			var err error
+			if commit.Author, commit.authorRaw, err = ParseRawIdent(value); err != nil {
+				return nil, fmt.Errorf("invalid author line: %v", err)
 			}
-			tzMinutes, err := strconv.Atoi(timezone[3:])
-			if err != nil {
-				return nil, fmt.Errorf("invalid timezone minutes: %v", err)
+			commit.AuthorDate = commit.authorRaw.Date()
+			position = afterAuthor
+		case name == "committer" && position == afterAuthor:
+			var err error
+			if commit.Committer, commit.committerRaw, err = ParseRawIdent(value); err != nil {
+				return nil, fmt.Errorf("invalid committer line: %v", err)
 			}
-			tzOffset := (tzHours*60 + tzMinutes) * 60
-			if timezone[0] == '-' {
-				tzOffset = -tzOffset
+			commit.CommitterDate = commit.committerRaw.Date()
+			position = afterCommitter
+		default:
+			if name == "author" || name == "committer" {
+				if _, _, err := ParseIdent(value); err != nil {
+					return nil, fmt.Errorf("invalid %s line: %v", name, err)
+				}
 			}
-			authorTime = time.Unix(timestamp, 0).In(time.FixedZone("", tzOffset))
+			commit.ExtraHeaders = append(commit.ExtraHeaders, Header{Name: name, Value: value})
+			extra = &commit.ExtraHeaders[len(commit.ExtraHeaders)-1]
+			position = inExtra
 		}
 	}
 
-	message = string(bytes.Join(lines[messageStart:], []byte{'\n'}))
+	// Write always starts with the tree and puts the author after the
+	// parents, so a commit without them there could not be written back
+	// unchanged.
+	if commit.TreeHash == "" || commit.Author == "" {
+		return nil, fmt.Errorf("commit must start with its tree and author")
+	}
+	if commit.Committer == "" {
+		commit.CommitterDate = commit.AuthorDate
+	}
+	return commit, nil
+}
 
-	commit := &Commit{
-		TreeHash:   treeHash,
-		ParentHash: parentHash,
-		Author:     author,
-		AuthorDate: authorTime,
-		Message:    message,
+// FormatIdent renders the value of an author, committer or tagger line.
+// It is the inverse of ParseIdent.
+func FormatIdent(ident string, date time.Time) string {
+	timezone := date.Format("-0700")
+	// Some imported histories record -0000, which Format cannot tell
+	// apart from +0000; ParseIdent keeps it as the zone's name.
+	if name, _ := date.Zone(); name == "-0000" {
+		timezone = name
 	}
+	return fmt.Sprintf("%s %d %s", ident, date.Unix(), timezone)
+}
 
-	return commit, nil
+// ParseIdent decodes the value of an author, committer or tagger line:
+// "Name <email>", a Unix timestamp and a +hhmm offset. The identity is
+// kept exactly as written, spaces included.
+func ParseIdent(value string) (string, time.Time, error) {
+	rest, timezone, found := cutLast(value)
+	if !found {
+		return "", time.Time{}, fmt.Errorf("missing fields")
+	}
+	ident, seconds, found := cutLast(rest)
+	if !found || ident == "" {
+		return "", time.Time{}, fmt.Errorf("missing fields")
+	}
+
+	timestamp, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid timestamp: %v", err)
+	}
+
+	if len(timezone) != 5 || (timezone[0] != '+' && timezone[0] != '-') {
+		return "", time.Time{}, fmt.Errorf("invalid timezone %q", timezone)
+	}
+	tzHours, err := strconv.Atoi(timezone[1:3])
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid timezone hours: %v", err)
+	}
+	tzMinutes, err := strconv.Atoi(timezone[3:])
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid timezone minutes: %v", err)
+	}
+	tzOffset := (tzHours*60 + tzMinutes) * 60
+	if timezone[0] == '-' {
+		tzOffset = -tzOffset
+	}
+	return ident, time.Unix(timestamp, 0).In(time.FixedZone(timezone, tzOffset)), nil
+}
+
+// RawDate is the "<timestamp> <tz>" text of an author, committer or
+// tagger line as it was read. Git accepts spellings that FormatIdent
+// would not produce, such as 0123, +123 or a +0099 offset, so objects
+// keep the text to be written back with the same ID.
+type RawDate struct {
+	text string
+	date time.Time
+}
+
+// ParseRawIdent is ParseIdent, but returns the date with the text it was
+// read from.
+func ParseRawIdent(value string) (string, RawDate, error) {
+	ident, date, err := ParseIdent(value)
+	if err != nil {
+		return "", RawDate{}, err
+	}
+	return ident, RawDate{text: value[len(ident)+1:], date: date}, nil
+}
+
+// Date returns the date the text decodes to.
+func (r RawDate) Date() time.Time {
+	return r.date
+}
+
+// FormatIdent renders ident and date like the FormatIdent function, but
+// writes the date as it was read as long as date is the one it was read
+// as.
+func (r RawDate) FormatIdent(ident string, date time.Time) string {
+	if r.text == "" || !date.Equal(r.date) || date.Location() != r.date.Location() {
+		return FormatIdent(ident, date)
+	}
+	return ident + " " + r.text
+}
+
+// cutLast splits s around its last space.
+func cutLast(s string) (string, string, bool) {
+	i := strings.LastIndexByte(s, ' ')
+	if i == -1 {
+		return "", "", false
+	}
+	return s[:i], s[i+1:], true
 }
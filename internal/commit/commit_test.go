@@ -3,7 +3,13 @@ package commit
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
 )
 
 func TestCommitCreation(t *testing.T) {
@@ -13,7 +19,7 @@ func TestCommitCreation(t *testing.T) {
 		author := "John Doe <john@example.com>"
 		message := "Initial commit"
 
-		commit, err := New(treeHash, parentHash, author, message)
+		commit, err := New(treeHash, []string{parentHash}, author, message)
 		if err != nil {
 			t.Fatalf("Failed to create valid commit: %v", err)
 		}
@@ -21,8 +27,8 @@ func TestCommitCreation(t *testing.T) {
 		if commit.TreeHash != treeHash {
 			t.Errorf("TreeHash = %s; want %s", commit.TreeHash, treeHash)
 		}
-		if commit.ParentHash != parentHash {
-			t.Errorf("ParentHash = %s; want %s", commit.ParentHash, parentHash)
+		if len(commit.Parents) != 1 || commit.Parents[0] != parentHash {
+			t.Errorf("Parents = %v; want [%s]", commit.Parents, parentHash)
 		}
 		if commit.Author != author {
 			t.Errorf("Author = %s; want %s", commit.Author, author)
@@ -39,13 +45,13 @@ func TestCommitCreation(t *testing.T) {
 		author := "John Doe <john@example.com>"
 		message := "First commit"
 
-		commit, err := New(treeHash, "", author, message)
+		commit, err := New(treeHash, nil, author, message)
 		if err != nil {
 			t.Fatalf("Failed to create first commit: %v", err)
 		}
 
-		if commit.ParentHash != "" {
-			t.Errorf("First commit should have empty ParentHash, got %s", commit.ParentHash)
+		if len(commit.Parents) != 0 {
+			t.Errorf("First commit should have no parents, got %v", commit.Parents)
 		}
 	})
 	t.Run("1.3: Invalid tree hash", func(t *testing.T) {
@@ -60,7 +66,7 @@ func TestCommitCreation(t *testing.T) {
 		}
 
 		for _, tc := range cases {
-			_, err := New(tc.hash, "", "John Doe <john@example.com>", "test")
+			_, err := New(tc.hash, nil, "John Doe <john@example.com>", "test")
 			if err == nil {
 				t.Errorf("Expected error for invalid tree hash (%s)", tc.desc)
 			}
@@ -78,7 +84,7 @@ func TestCommitCreation(t *testing.T) {
 		}
 
 		for _, tc := range cases {
-			_, err := New(validTree, tc.hash, "John Doe <john@example.com>", "test")
+			_, err := New(validTree, []string{tc.hash}, "John Doe <john@example.com>", "test")
 			if err == nil {
 				t.Errorf("Expected error for invalid parent hash (%s)", tc.desc)
 			}
@@ -97,7 +103,7 @@ func TestCommitCreation(t *testing.T) {
 		}
 
 		for _, tc := range cases {
-			_, err := New(validTree, "", tc.author, "test")
+			_, err := New(validTree, nil, tc.author, "test")
 			if err == nil {
 				t.Errorf("Expected error for invalid author format (%s)", tc.desc)
 			}
@@ -108,7 +114,7 @@ func TestCommitCreation(t *testing.T) {
 		validTree := "1234567890123456789012345678901234567890"
 		validAuthor := "John Doe <john@example.com>"
 
-		_, err := New(validTree, "", validAuthor, "")
+		_, err := New(validTree, nil, validAuthor, "")
 		if err == nil {
 			t.Error("Expected error for empty message")
 		}
@@ -119,7 +125,7 @@ func TestCommitCreation(t *testing.T) {
 		validAuthor := "John Doe <john@example.com>"
 		message := "First line\nSecond line\nThird line"
 
-		commit, err := New(validTree, "", validAuthor, message)
+		commit, err := New(validTree, nil, validAuthor, message)
 		if err != nil {
 			t.Fatalf("Failed to create commit with multi-line message: %v", err)
 		}
@@ -141,6 +147,7 @@ func TestCommitStorage(t *testing.T) {
 		testDir := filepath.Join(cwd, "testdata")
 		os.RemoveAll(testDir)
 		os.MkdirAll(filepath.Join(testDir, ".gitgo", "objects"), 0755)
+		objects := object.NewFileStore(filepath.Join(testDir, ".gitgo", "objects"), objectformat.SHA1)
 		defer os.RemoveAll(testDir)
 
 		treeHash := "1234567890123456789012345678901234567890"
@@ -148,17 +155,17 @@ func TestCommitStorage(t *testing.T) {
 		author := "John Doe <john@example.com>"
 		message := "Test commit message"
 
-		commit, err := New(treeHash, parentHash, author, message)
+		commit, err := New(treeHash, []string{parentHash}, author, message)
 		if err != nil {
 			t.Fatalf("Failed to create commit: %v", err)
 		}
 
-		hash, err := commit.Write(filepath.Join(testDir, ".gitgo", "objects"))
+		hash, err := commit.Write(objects)
 		if err != nil {
 			t.Fatalf("Failed to write commit: %v", err)
 		}
 
-		readCommit, err := Read(filepath.Join(testDir, ".gitgo", "objects"), hash)
+		readCommit, err := Read(objects, hash)
 		if err != nil {
 			t.Fatalf("Failed to read commit: %v", err)
 		}
@@ -166,8 +173,8 @@ func TestCommitStorage(t *testing.T) {
 		if readCommit.TreeHash != commit.TreeHash {
 			t.Errorf("Tree hash mismatch: got %s, want %s", readCommit.TreeHash, commit.TreeHash)
 		}
-		if readCommit.ParentHash != commit.ParentHash {
-			t.Errorf("Parent hash mismatch: got %s, want %s", readCommit.ParentHash, commit.ParentHash)
+		if len(readCommit.Parents) != 1 || readCommit.Parents[0] != parentHash {
+			t.Errorf("Parents mismatch: got %v, want [%s]", readCommit.Parents, parentHash)
 		}
 		if readCommit.Author != commit.Author {
 			t.Errorf("Author mismatch: got %s, want %s", readCommit.Author, commit.Author)
@@ -193,22 +200,23 @@ func TestCommitStorage(t *testing.T) {
 		testDir := filepath.Join(cwd, "testdata")
 		os.RemoveAll(testDir)
 		os.MkdirAll(filepath.Join(testDir, ".gitgo", "objects"), 0755)
+		objects := object.NewFileStore(filepath.Join(testDir, ".gitgo", "objects"), objectformat.SHA1)
 		defer os.RemoveAll(testDir)
 
 		message := "First line\nSecond line\nThird line"
 		commit, _ := New(
 			"1234567890123456789012345678901234567890",
-			"",
+			nil,
 			"John Doe <john@example.com>",
 			message,
 		)
 
-		hash, err := commit.Write(filepath.Join(testDir, ".gitgo", "objects"))
+		hash, err := commit.Write(objects)
 		if err != nil {
 			t.Fatalf("Failed to write commit: %v", err)
 		}
 
-		readCommit, err := Read(filepath.Join(testDir, ".gitgo", "objects"), hash)
+		readCommit, err := Read(objects, hash)
 		if err != nil {
 			t.Fatalf("Failed to read commit: %v", err)
 		}
@@ -224,27 +232,280 @@ func TestCommitStorage(t *testing.T) {
 		testDir := filepath.Join(cwd, "testdata")
 		os.RemoveAll(testDir)
 		os.MkdirAll(filepath.Join(testDir, ".gitgo", "objects"), 0755)
+		objects := object.NewFileStore(filepath.Join(testDir, ".gitgo", "objects"), objectformat.SHA1)
 		defer os.RemoveAll(testDir)
 
 		commit, _ := New(
 			"1234567890123456789012345678901234567890",
-			"",
+			nil,
 			"John Doe <john@example.com>",
 			"First commit",
 		)
 
-		hash, err := commit.Write(filepath.Join(testDir, ".gitgo", "objects"))
+		hash, err := commit.Write(objects)
 		if err != nil {
 			t.Fatalf("Failed to write commit: %v", err)
 		}
 
-		readCommit, err := Read(filepath.Join(testDir, ".gitgo", "objects"), hash)
+		readCommit, err := Read(objects, hash)
+		if err != nil {
+			t.Fatalf("Failed to read commit: %v", err)
+		}
+
+		if len(readCommit.Parents) != 0 {
+			t.Errorf("Expected no parents, got %v", readCommit.Parents)
+		}
+	})
+
+	t.Run("2.4: History in memory", func(t *testing.T) {
+		objects := object.NewMemoryStore(objectformat.SHA1)
+		treeHash, err := objects.Put(object.TypeTree, nil)
+		if err != nil {
+			t.Fatalf("Failed to write tree: %v", err)
+		}
+
+		var parents []string
+		var hashes []string
+		for _, message := range []string{"first", "second", "third"} {
+			c, err := New(treeHash, parents, "John Doe <john@example.com>", message)
+			if err != nil {
+				t.Fatalf("Failed to create commit: %v", err)
+			}
+			hash, err := c.Write(objects)
+			if err != nil {
+				t.Fatalf("Failed to write commit: %v", err)
+			}
+			parents = []string{hash}
+			hashes = append(hashes, hash)
+		}
+
+		for i := len(hashes) - 1; i >= 0; i-- {
+			c, err := Read(objects, hashes[i])
+			if err != nil {
+				t.Fatalf("Failed to read commit: %v", err)
+			}
+			var want []string
+			if i > 0 {
+				want = hashes[i-1 : i]
+			}
+			if !slices.Equal(c.Parents, want) || c.TreeHash != treeHash {
+				t.Errorf("Commit %d = %+v; want parent %q", i, c, want)
+			}
+		}
+	})
+	t.Run("2.5: Merge commit round trip", func(t *testing.T) {
+		objects := object.NewMemoryStore(objectformat.SHA1)
+		treeHash := "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+		parents := []string{
+			"abcdef1234567890abcdef1234567890abcdef12",
+			"1234567890abcdef1234567890abcdef12345678",
+			"0000000000111111111122222222223333333333",
+		}
+		c, err := New(treeHash, parents, "John Doe <john@example.com>", "Merge branches\n")
+		if err != nil {
+			t.Fatalf("Failed to create merge commit: %v", err)
+		}
+		hash, err := c.Write(objects)
+		if err != nil {
+			t.Fatalf("Failed to write commit: %v", err)
+		}
+		obj, _ := objects.Get(hash)
+		if !strings.HasPrefix(string(obj.Data), "tree "+treeHash+"\nparent "+parents[0]+"\nparent "+parents[1]+"\nparent "+parents[2]+"\nauthor ") {
+			t.Errorf("Merge commit written as:\n%s", obj.Data)
+		}
+
+		read, err := Parse(obj.Data)
+		if err != nil {
+			t.Fatalf("Failed to parse commit: %v", err)
+		}
+		if !slices.Equal(read.Parents, parents) {
+			t.Errorf("Parents = %v; want %v", read.Parents, parents)
+		}
+		if again, _ := read.Write(objects); again != hash {
+			t.Errorf("Rewritten merge commit = %s; want %s", again, hash)
+		}
+
+		if _, err := New(treeHash, []string{parents[0], parents[0]}, "John Doe <john@example.com>", "dup"); err == nil {
+			t.Error("Expected error for a duplicate parent")
+		}
+	})
+	t.Run("2.6: Committer round trip", func(t *testing.T) {
+		objects := object.NewMemoryStore(objectformat.SHA1)
+		treeHash := "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+		c, _ := New(treeHash, nil, "John Doe <john@example.com>", "message")
+		c.AuthorDate = time.Unix(1700000000, 0).In(time.FixedZone("", 3600))
+		c.Committer = "Jane Roe <jane@example.com>"
+		c.CommitterDate = time.Unix(1700000500, 0).UTC()
+		hash, _ := c.Write(objects)
+
+		obj, _ := objects.Get(hash)
+		want := "tree " + treeHash + "\nauthor John Doe <john@example.com> 1700000000 +0100\n" +
+			"committer Jane Roe <jane@example.com> 1700000500 +0000\n\nmessage"
+		if string(obj.Data) != want {
+			t.Errorf("Commit = %q; want %q", obj.Data, want)
+		}
+		read, err := Parse(obj.Data)
+		if err != nil || read.Committer != c.Committer || read.CommitterDate.Unix() != 1700000500 {
+			t.Errorf("Parsed commit = %+v, %v", read, err)
+		}
+	})
+
+	t.Run("2.7: Commit without a committer", func(t *testing.T) {
+		objects := object.NewMemoryStore(objectformat.SHA1)
+		data := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\nauthor A <a@example.com> 1700000000 +0000\n\nold"
+		hash, _ := objects.Put(object.TypeCommit, []byte(data))
+		c, err := Read(objects, hash)
 		if err != nil {
 			t.Fatalf("Failed to read commit: %v", err)
 		}
+		if c.Committer != "" || !c.CommitterDate.Equal(c.AuthorDate) {
+			t.Errorf("Committer = %q at %v; want none at the author date", c.Committer, c.CommitterDate)
+		}
+		if again, _ := c.Write(objects); again != hash {
+			t.Errorf("Rewritten commit = %s; want %s", again, hash)
+		}
+		if _, err := Parse([]byte("tree x\ncommitter A <a@example.com> 1700000000 0100\n\nbad")); err == nil {
+			t.Error("Expected error for a malformed committer line")
+		}
+	})
+
+	t.Run("2.8: Signature round trip", func(t *testing.T) {
+		objects := object.NewMemoryStore(objectformat.SHA1)
+		treeHash := "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+		c, _ := New(treeHash, nil, "John Doe <john@example.com>", "signed\n")
+		c.AuthorDate = time.Unix(1700000000, 0).UTC()
+		c.CommitterDate = c.AuthorDate
+		payload := c.Payload()
+		signature := "-----BEGIN SSH SIGNATURE-----\nU1NIU0lH\n-----END SSH SIGNATURE-----\n"
+		c.SetSignature(signature)
+		hash, _ := c.Write(objects)
+
+		obj, _ := objects.Get(hash)
+		want := "tree " + treeHash + "\nauthor John Doe <john@example.com> 1700000000 +0000\n" +
+			"committer John Doe <john@example.com> 1700000000 +0000\n" +
+			"gpgsig -----BEGIN SSH SIGNATURE-----\n U1NIU0lH\n -----END SSH SIGNATURE-----\n\nsigned\n"
+		if string(obj.Data) != want {
+			t.Errorf("Commit = %q; want %q", obj.Data, want)
+		}
+		read, err := Parse(obj.Data)
+		if err != nil || read.Signature() != signature || read.Message != c.Message {
+			t.Errorf("Parsed commit = %+v, %v", read, err)
+		}
+		if string(read.Payload()) != string(payload) {
+			t.Errorf("Payload = %q; want %q", read.Payload(), payload)
+		}
+		if again, _ := read.Write(objects); again != hash {
+			t.Errorf("Rewritten signed commit = %s; want %s", again, hash)
+		}
+		read.SetSignature("")
+		if read.Signature() != "" || string(read.Payload()) != string(payload) {
+			t.Errorf("Commit still signed after removing the signature: %+v", read)
+		}
+	})
+
+	t.Run("2.9: Unknown headers and exact messages round trip", func(t *testing.T) {
+		objects := object.NewMemoryStore(objectformat.SHA1)
+		tree := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"
+		parent := "parent abcdef1234567890abcdef1234567890abcdef12\n"
+		for _, data := range []string{
+			// What stock git writes for a signed merge of a signed tag.
+			tree + parent + "parent 1234567890123456789012345678901234567890\n" +
+				"author John  Doe <john@example.com> 1700000000 -0000\n" +
+				"committer Jane Roe <jane@example.com> 1700000500 +0530\n" +
+				"encoding ISO-8859-1\n" +
+				"mergetag object 1234567890123456789012345678901234567890\n type commit\n tag v1\n" +
+				" tagger Jane Roe <jane@example.com> 1700000000 +0000\n \n release\n" +
+				"gpgsig -----BEGIN PGP SIGNATURE-----\n \n iQEzBAABCAAdFiEE\n -----END PGP SIGNATURE-----\n" +
+				"x-custom value with  spaces \n" +
+				"\n  subject\n\n\nbody without a final newline",
+			// Known headers out of their usual place stay where they are.
+			tree + "author A <a@example.com> 1 +0000\nextra 1\ncommitter C <c@example.com> 2 +0000\n" + parent + "\n",
+			tree + "author A <a@example.com> 1 +0000\n\n",
+		} {
+			hash, _ := objects.Put(object.TypeCommit, []byte(data))
+			c, err := Read(objects, hash)
+			if err != nil {
+				t.Fatalf("Failed to read commit %q: %v", data, err)
+			}
+			again, err := c.Write(objects)
+			if err != nil || again != hash {
+				rewritten, _ := objects.Get(again)
+				t.Errorf("Rewritten commit = %q, %v; want %q", rewritten.Data, err, data)
+			}
+		}
+
+		c, _ := Parse([]byte("tree x\nauthor A <a@example.com> 1 +0000\nencoding UTF-8\nmergetag a\n b\n \n\nmessage"))
+		want := []Header{{Name: "encoding", Value: "UTF-8"}, {Name: "mergetag", Value: "a\nb\n"}}
+		if !slices.Equal(c.ExtraHeaders, want) || c.Message != "message" {
+			t.Errorf("ExtraHeaders = %q, message %q; want %q", c.ExtraHeaders, c.Message, want)
+		}
+		if value, ok := c.Header("encoding"); !ok || value != "UTF-8" {
+			t.Errorf("Header(encoding) = %q, %v", value, ok)
+		}
+		if _, err := Parse([]byte(" orphan\ntree x\n\nmessage")); err == nil {
+			t.Error("Expected error for a continuation line without a header")
+		}
+	})
+
+	t.Run("2.10: Commits without a message or in the wrong order", func(t *testing.T) {
+		objects := object.NewMemoryStore(objectformat.SHA1)
+		tree := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"
+		author := "author A <a@example.com> 1 +0000\n"
+		for _, data := range []string{
+			tree + author,
+			tree + author + "committer C <c@example.com> 2 +0000\nx-custom a\n b\n",
+			tree + author + "\n",
+		} {
+			hash, _ := objects.Put(object.TypeCommit, []byte(data))
+			c, err := Read(objects, hash)
+			if err != nil {
+				t.Fatalf("Failed to read commit %q: %v", data, err)
+			}
+			if c.Message != "" {
+				t.Errorf("Message = %q; want none", c.Message)
+			}
+			again, err := c.Write(objects)
+			if err != nil || again != hash {
+				rewritten, _ := objects.Get(again)
+				t.Errorf("Rewritten commit = %q, %v; want %q", rewritten.Data, err, data)
+			}
+		}
+
+		for _, data := range []string{
+			tree + "committer C <c@example.com> 2 +0000\n\nno author",
+			author + tree + "\ntree after the author",
+			"parent abcdef1234567890abcdef1234567890abcdef12\n" + tree + author + "\nparent first",
+			strings.TrimSuffix(tree+author, "\n"),
+		} {
+			if _, err := Parse([]byte(data)); err == nil {
+				t.Errorf("Expected error for commit %q", data)
+			}
+		}
+	})
+
+	t.Run("2.11: Dates round trip as written", func(t *testing.T) {
+		objects := object.NewMemoryStore(objectformat.SHA1)
+		tree := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"
+		for _, date := range []string{"0123 +0000", "+123 +0000", "1700000000 +0099", "1700000000 -0000"} {
+			data := tree + "author A <a@example.com> " + date + "\n" +
+				"committer C <c@example.com> " + date + "\n\nmessage\n"
+			hash, _ := objects.Put(object.TypeCommit, []byte(data))
+			c, err := Read(objects, hash)
+			if err != nil {
+				t.Fatalf("Failed to read commit %q: %v", data, err)
+			}
+			again, err := c.Write(objects)
+			if err != nil || again != hash {
+				rewritten, _ := objects.Get(again)
+				t.Errorf("Rewritten commit = %q, %v; want %q", rewritten.Data, err, data)
+			}
+		}
 
-		if readCommit.ParentHash != "" {
-			t.Errorf("Expected empty parent hash, got %s", readCommit.ParentHash)
+		c, _ := Parse([]byte(tree + "author A <a@example.com> 0123 +0099\n\nmessage\n"))
+		c.AuthorDate = c.AuthorDate.Add(time.Second)
+		want := "author A <a@example.com> 124 +0139\n"
+		if payload := string(c.Payload()); !strings.Contains(payload, want) {
+			t.Errorf("Changed date written as %q; want %q", payload, want)
 		}
 	})
 }
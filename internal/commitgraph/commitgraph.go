@@ -0,0 +1,481 @@
+package commitgraph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+// File is where the commit-graph lives inside the objects directory, the
+// same place stock git keeps it.
+const File = "info/commit-graph"
+
+// GenerationInfinity is the generation of a commit missing from the
+// graph. It sorts after every real generation, so such commits are
+// never skipped by a walk that prunes on generation numbers.
+const GenerationInfinity = 0xffffffff
+
+const (
+	signature     = "CGPH"
+	version       = 1
+	chunkFanout   = "OIDF"
+	chunkLookup   = "OIDL"
+	chunkData     = "CDAT"
+	chunkEdges    = "EDGE"
+	parentNone    = 0x70000000
+	parentEdges   = 0x80000000
+	maxGeneration = 1<<30 - 1
+	headerSize    = 8
+	chunkRowSize  = 12
+)
+
+// Commit is what the graph records for one commit.
+type Commit struct {
+	Hash       string
+	TreeHash   string
+	Parents    []string
+	Generation uint32
+	CommitTime int64
+}
+
+// Graph is a commit-graph file loaded into memory.
+type Graph struct {
+	format objectformat.Format
+	fanout [256]uint32
+	hashes []byte
+	data   []byte
+	edges  []byte
+}
+
+func Path(objectsPath string) string {
+	return filepath.Join(objectsPath, filepath.FromSlash(File))
+}
+
+// Open loads the commit-graph of objectsPath. When there is none the
+// error satisfies os.IsNotExist.
+func Open(objectsPath string, format objectformat.Format) (*Graph, error) {
+	data, err := os.ReadFile(Path(objectsPath))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, format)
+}
+
+// Parse decodes a commit-graph file and checks its trailing checksum.
+func Parse(data []byte, format objectformat.Format) (*Graph, error) {
+	if len(data) < headerSize+format.Size || string(data[:4]) != signature {
+		return nil, fmt.Errorf("invalid commit-graph signature")
+	}
+	if data[4] != version {
+		return nil, fmt.Errorf("unsupported commit-graph version %d", data[4])
+	}
+	if data[5] != hashVersion(format) {
+		return nil, fmt.Errorf("commit-graph hash version %d does not match %s", data[5], format.Name)
+	}
+	if data[7] != 0 {
+		return nil, fmt.Errorf("split commit-graphs are not supported")
+	}
+	body := data[:len(data)-format.Size]
+	hasher := format.New()
+	hasher.Write(body)
+	if !bytes.Equal(hasher.Sum(nil), data[len(body):]) {
+		return nil, fmt.Errorf("commit-graph checksum mismatch")
+	}
+
+	chunks := make(map[string][]byte)
+	numChunks := int(data[6])
+	table := headerSize
+	if len(body) < table+(numChunks+1)*chunkRowSize {
+		return nil, fmt.Errorf("commit-graph chunk table is truncated")
+	}
+	for i := 0; i < numChunks; i++ {
+		row := data[table+i*chunkRowSize:]
+		start := binary.BigEndian.Uint64(row[4:12])
+		end := binary.BigEndian.Uint64(row[chunkRowSize+4 : chunkRowSize+12])
+		if start > end || end > uint64(len(body)) {
+			return nil, fmt.Errorf("commit-graph chunk %s is out of bounds", row[:4])
+		}
+		chunks[string(row[:4])] = data[start:end]
+	}
+
+	g := &Graph{
+		format: format,
+		hashes: chunks[chunkLookup],
+		data:   chunks[chunkData],
+		edges:  chunks[chunkEdges],
+	}
+	fanout := chunks[chunkFanout]
+	if len(fanout) != 256*4 || g.hashes == nil || g.data == nil {
+		return nil, fmt.Errorf("commit-graph is missing a required chunk")
+	}
+	for i := range g.fanout {
+		g.fanout[i] = binary.BigEndian.Uint32(fanout[i*4:])
+	}
+	count := int(g.fanout[255])
+	if len(g.hashes) != count*format.Size || len(g.data) != count*g.dataSize() {
+		return nil, fmt.Errorf("commit-graph chunk sizes do not match %d commits", count)
+	}
+	return g, nil
+}
+
+// Len returns the number of commits in the graph.
+func (g *Graph) Len() int {
+	return int(g.fanout[255])
+}
+
+// Lookup returns the entry for hash, if the graph has one.
+func (g *Graph) Lookup(hash string) (*Commit, bool) {
+	if g == nil {
+		return nil, false
+	}
+	pos, ok := g.position(hash)
+	if !ok {
+		return nil, false
+	}
+	c, err := g.commitAt(pos)
+	if err != nil {
+		return nil, false
+	}
+	return c, true
+}
+
+// Generation returns the generation of hash, or GenerationInfinity when
+// the graph does not know it.
+func (g *Graph) Generation(hash string) uint32 {
+	if g == nil {
+		return GenerationInfinity
+	}
+	pos, ok := g.position(hash)
+	if !ok {
+		return GenerationInfinity
+	}
+	return binary.BigEndian.Uint32(g.data[pos*g.dataSize()+g.format.Size+8:]) >> 2
+}
+
+func (g *Graph) dataSize() int {
+	return g.format.Size + 16
+}
+
+func (g *Graph) hashAt(pos int) string {
+	size := g.format.Size
+	return hex.EncodeToString(g.hashes[pos*size : (pos+1)*size])
+}
+
+func (g *Graph) position(hash string) (int, bool) {
+	raw, err := hex.DecodeString(hash)
+	if err != nil || len(raw) != g.format.Size {
+		return 0, false
+	}
+	lo := 0
+	if raw[0] > 0 {
+		lo = int(g.fanout[raw[0]-1])
+	}
+	hi := int(g.fanout[raw[0]])
+	size := g.format.Size
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(g.hashes[(lo+i)*size:(lo+i+1)*size], raw) >= 0
+	})
+	if i < hi && bytes.Equal(g.hashes[i*size:(i+1)*size], raw) {
+		return i, true
+	}
+	return 0, false
+}
+
+func (g *Graph) commitAt(pos int) (*Commit, error) {
+	size := g.format.Size
+	row := g.data[pos*g.dataSize() : (pos+1)*g.dataSize()]
+	c := &Commit{
+		Hash:     g.hashAt(pos),
+		TreeHash: hex.EncodeToString(row[:size]),
+	}
+	first := binary.BigEndian.Uint32(row[size:])
+	second := binary.BigEndian.Uint32(row[size+4:])
+	word := binary.BigEndian.Uint32(row[size+8:])
+	c.Generation = word >> 2
+	c.CommitTime = int64(word&3)<<32 | int64(binary.BigEndian.Uint32(row[size+12:]))
+
+	parent := func(index uint32) (string, error) {
+		if int(index) >= g.Len() {
+			return "", fmt.Errorf("commit %s has parent position %d out of range", c.Hash, index)
+		}
+		return g.hashAt(int(index)), nil
+	}
+	if first != parentNone {
+		hash, err := parent(first)
+		if err != nil {
+			return nil, err
+		}
+		c.Parents = append(c.Parents, hash)
+	}
+	switch {
+	case second == parentNone:
+	case second&parentEdges == 0:
+		hash, err := parent(second)
+		if err != nil {
+			return nil, err
+		}
+		c.Parents = append(c.Parents, hash)
+	default:
+		// Octopus merges list their remaining parents in the EDGE chunk,
+		// the last one marked by the high bit.
+		for i := int(second &^ parentEdges); ; i++ {
+			if (i+1)*4 > len(g.edges) {
+				return nil, fmt.Errorf("commit %s has edge list out of range", c.Hash)
+			}
+			edge := binary.BigEndian.Uint32(g.edges[i*4:])
+			hash, err := parent(edge &^ parentEdges)
+			if err != nil {
+				return nil, err
+			}
+			c.Parents = append(c.Parents, hash)
+			if edge&parentEdges != 0 {
+				break
+			}
+		}
+	}
+	return c, nil
+}
+
+// Build reads every commit reachable from tips and computes their
+// generation numbers, ready to be passed to Write.
+func Build(store object.ObjectStore, tips []string) ([]*Commit, error) {
+	commits := make(map[string]*Commit)
+	load := func(hash string) (*Commit, error) {
+		if c, ok := commits[hash]; ok {
+			return c, nil
+		}
+		com, err := commit.Read(store, hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %v", hash, err)
+		}
+		c := &Commit{
+			Hash:       hash,
+			TreeHash:   com.TreeHash,
+			CommitTime: com.CommitterDate.Unix(),
+			Parents:    com.Parents,
+		}
+		commits[hash] = c
+		return c, nil
+	}
+
+	// Generations are assigned parents first. An explicit stack keeps
+	// long histories from exhausting the goroutine stack.
+	for _, tip := range tips {
+		c, err := load(tip)
+		if err != nil {
+			return nil, err
+		}
+		stack := []*Commit{c}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.Generation != 0 {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			generation := uint32(1)
+			pending := false
+			for _, parentHash := range top.Parents {
+				parent, err := load(parentHash)
+				if err != nil {
+					return nil, err
+				}
+				if parent.Generation == 0 {
+					stack = append(stack, parent)
+					pending = true
+				} else if parent.Generation >= generation {
+					generation = parent.Generation + 1
+				}
+			}
+			if !pending {
+				top.Generation = min(generation, maxGeneration)
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	result := make([]*Commit, 0, len(commits))
+	for _, c := range commits {
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// Write stores commits as the commit-graph of objectsPath, replacing any
+// existing one. Every parent must itself be among commits.
+func Write(objectsPath string, format objectformat.Format, commits []*Commit) error {
+	sorted := append([]*Commit(nil), commits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Hash < sorted[j].Hash })
+	positions := make(map[string]uint32, len(sorted))
+	for i, c := range sorted {
+		positions[c.Hash] = uint32(i)
+	}
+
+	var fanout, lookup, data, edges bytes.Buffer
+	var counts [256]uint32
+	for _, c := range sorted {
+		raw, err := hex.DecodeString(c.Hash)
+		if err != nil || len(raw) != format.Size {
+			return fmt.Errorf("invalid commit hash %q", c.Hash)
+		}
+		counts[raw[0]]++
+		lookup.Write(raw)
+
+		tree, err := hex.DecodeString(c.TreeHash)
+		if err != nil || len(tree) != format.Size {
+			return fmt.Errorf("invalid tree hash %q for commit %s", c.TreeHash, c.Hash)
+		}
+		data.Write(tree)
+		parents := make([]uint32, len(c.Parents))
+		for i, parentHash := range c.Parents {
+			pos, ok := positions[parentHash]
+			if !ok {
+				return fmt.Errorf("parent %s of commit %s is not in the graph", parentHash, c.Hash)
+			}
+			parents[i] = pos
+		}
+		first, second := uint32(parentNone), uint32(parentNone)
+		switch len(parents) {
+		case 0:
+		case 1:
+			first = parents[0]
+		case 2:
+			first, second = parents[0], parents[1]
+		default:
+			first, second = parents[0], parentEdges|uint32(edges.Len()/4)
+			for i, pos := range parents[1:] {
+				if i == len(parents)-2 {
+					pos |= parentEdges
+				}
+				binary.Write(&edges, binary.BigEndian, pos)
+			}
+		}
+		binary.Write(&data, binary.BigEndian, first)
+		binary.Write(&data, binary.BigEndian, second)
+		binary.Write(&data, binary.BigEndian, c.Generation<<2|uint32(c.CommitTime>>32)&3)
+		binary.Write(&data, binary.BigEndian, uint32(c.CommitTime))
+	}
+	total := uint32(0)
+	for _, count := range counts {
+		total += count
+		binary.Write(&fanout, binary.BigEndian, total)
+	}
+
+	type chunk struct {
+		id      string
+		content []byte
+	}
+	chunks := []chunk{
+		{chunkFanout, fanout.Bytes()},
+		{chunkLookup, lookup.Bytes()},
+		{chunkData, data.Bytes()},
+	}
+	if edges.Len() > 0 {
+		chunks = append(chunks, chunk{chunkEdges, edges.Bytes()})
+	}
+
+	var out bytes.Buffer
+	out.WriteString(signature)
+	out.Write([]byte{version, hashVersion(format), byte(len(chunks)), 0})
+	offset := uint64(headerSize + (len(chunks)+1)*chunkRowSize)
+	for _, c := range chunks {
+		out.WriteString(c.id)
+		binary.Write(&out, binary.BigEndian, offset)
+		offset += uint64(len(c.content))
+	}
+	out.Write([]byte{0, 0, 0, 0})
+	binary.Write(&out, binary.BigEndian, offset)
+	for _, c := range chunks {
+		out.Write(c.content)
+	}
+	hasher := format.New()
+	hasher.Write(out.Bytes())
+	out.Write(hasher.Sum(nil))
+
+	path := Path(objectsPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "tmp_graph_")
+	if err != nil {
+		return fmt.Errorf("failed to create commit-graph: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write commit-graph: %v", err)
+	}
+	if err := tmp.Chmod(0444); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write commit-graph: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write commit-graph: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write commit-graph: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace commit-graph: %v", err)
+	}
+	return nil
+}
+
+// Verify checks every entry of the graph against the commit objects in
+// store and returns one error per problem found.
+func (g *Graph) Verify(store object.ObjectStore) []error {
+	var problems []error
+	for pos := 0; pos < g.Len(); pos++ {
+		entry, err := g.commitAt(pos)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if found, ok := g.position(entry.Hash); !ok || found != pos {
+			problems = append(problems, fmt.Errorf("commit %s is out of order in the commit-graph", entry.Hash))
+		}
+		com, err := commit.Read(store, entry.Hash)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("failed to read commit %s: %v", entry.Hash, err))
+			continue
+		}
+		parents := com.Parents
+		switch {
+		case entry.TreeHash != com.TreeHash:
+			problems = append(problems, fmt.Errorf("commit %s has tree %s in the graph, %s in the object", entry.Hash, entry.TreeHash, com.TreeHash))
+		case fmt.Sprint(entry.Parents) != fmt.Sprint(parents):
+			problems = append(problems, fmt.Errorf("commit %s has parents %v in the graph, %v in the object", entry.Hash, entry.Parents, parents))
+		case entry.CommitTime != com.CommitterDate.Unix():
+			problems = append(problems, fmt.Errorf("commit %s has date %d in the graph, %d in the object", entry.Hash, entry.CommitTime, com.CommitterDate.Unix()))
+		}
+
+		want := uint32(1)
+		for _, parentHash := range entry.Parents {
+			if generation := g.Generation(parentHash); generation >= want {
+				want = generation + 1
+			}
+		}
+		if want > maxGeneration {
+			want = maxGeneration
+		}
+		if entry.Generation != want {
+			problems = append(problems, fmt.Errorf("commit %s has generation %d, expected %d", entry.Hash, entry.Generation, want))
+		}
+	}
+	return problems
+}
+
+func hashVersion(format objectformat.Format) byte {
+	if format == objectformat.SHA256 {
+		return 2
+	}
+	return 1
+}
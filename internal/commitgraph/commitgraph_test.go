@@ -0,0 +1,160 @@
+package commitgraph
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+	"github.com/HalilFocic/gitgo/internal/tree"
+)
+
+// history writes a chain of n commits and returns their IDs, oldest first.
+func history(t *testing.T, store object.ObjectStore, n int) []string {
+	t.Helper()
+	treeHash, err := tree.New().Write(store)
+	if err != nil {
+		t.Fatalf("Failed to write tree: %v", err)
+	}
+	var hashes []string
+	var parents []string
+	for i := 0; i < n; i++ {
+		com, err := commit.New(treeHash, parents, "Test User <test@example.com>", "commit")
+		if err != nil {
+			t.Fatalf("Failed to create commit: %v", err)
+		}
+		com.AuthorDate = time.Unix(int64(1700000000+i), 0)
+		com.CommitterDate = com.AuthorDate
+		hash, err := com.Write(store)
+		if err != nil {
+			t.Fatalf("Failed to write commit: %v", err)
+		}
+		parents = []string{hash}
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+func TestCommitGraph(t *testing.T) {
+	cwd, _ := os.Getwd()
+	objectsPath := filepath.Join(cwd, "testdata", "objects")
+
+	t.Run("1.1: Write, read and verify", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		for _, format := range []objectformat.Format{objectformat.SHA1, objectformat.SHA256} {
+			store := object.NewMemoryStore(format)
+			hashes := history(t, store, 5)
+			commits, err := Build(store, []string{hashes[4], hashes[2]})
+			if err != nil {
+				t.Fatalf("Failed to build graph: %v", err)
+			}
+			if err := Write(objectsPath, format, commits); err != nil {
+				t.Fatalf("Failed to write graph: %v", err)
+			}
+
+			g, err := Open(objectsPath, format)
+			if err != nil {
+				t.Fatalf("Failed to open graph: %v", err)
+			}
+			if g.Len() != 5 {
+				t.Errorf("Len = %d; want 5", g.Len())
+			}
+			for i, hash := range hashes {
+				c, ok := g.Lookup(hash)
+				if !ok {
+					t.Fatalf("Commit %s missing from graph", hash)
+				}
+				if c.Generation != uint32(i+1) || c.CommitTime != int64(1700000000+i) {
+					t.Errorf("Commit %d: generation %d, time %d", i, c.Generation, c.CommitTime)
+				}
+				if i > 0 && (len(c.Parents) != 1 || c.Parents[0] != hashes[i-1]) {
+					t.Errorf("Commit %d: parents %v; want %s", i, c.Parents, hashes[i-1])
+				}
+			}
+			if problems := g.Verify(store); len(problems) != 0 {
+				t.Errorf("Unexpected problems: %v", problems)
+			}
+			missing := object.Hash(format, object.TypeCommit, []byte("missing"))
+			if _, ok := g.Lookup(missing); ok || g.Generation(missing) != GenerationInfinity {
+				t.Error("Unknown commits should not be found")
+			}
+		}
+	})
+
+	t.Run("1.2: Corrupt and stale graphs", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		if _, err := Open(objectsPath, objectformat.SHA1); !os.IsNotExist(err) {
+			t.Errorf("Expected not-exist error, got %v", err)
+		}
+
+		store := object.NewMemoryStore(objectformat.SHA1)
+		hashes := history(t, store, 3)
+		commits, _ := Build(store, hashes[2:])
+		commits[0].Generation = 7
+		if err := Write(objectsPath, objectformat.SHA1, commits); err != nil {
+			t.Fatalf("Failed to write graph: %v", err)
+		}
+		g, err := Open(objectsPath, objectformat.SHA1)
+		if err != nil {
+			t.Fatalf("Failed to open graph: %v", err)
+		}
+		if problems := g.Verify(store); len(problems) == 0 {
+			t.Error("Expected a generation problem")
+		}
+
+		data, _ := os.ReadFile(Path(objectsPath))
+		data[len(data)/2] ^= 0xff
+		if _, err := Parse(data, objectformat.SHA1); err == nil {
+			t.Error("Expected checksum error")
+		}
+		if _, err := Parse(data, objectformat.SHA256); err == nil {
+			t.Error("Expected error for the wrong hash version")
+		}
+	})
+
+	t.Run("1.3: Merge and octopus parents", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		hash := func(s string) string {
+			return object.Hash(objectformat.SHA1, object.TypeCommit, []byte(s))
+		}
+		treeHash := object.Hash(objectformat.SHA1, object.TypeTree, nil)
+		a, b, c, d := hash("a"), hash("b"), hash("c"), hash("d")
+		commits := []*Commit{
+			{Hash: a, TreeHash: treeHash, Generation: 1},
+			{Hash: b, TreeHash: treeHash, Generation: 1},
+			{Hash: c, TreeHash: treeHash, Parents: []string{a, b}, Generation: 2},
+			{Hash: d, TreeHash: treeHash, Parents: []string{c, a, b}, Generation: 3},
+		}
+		if err := Write(objectsPath, objectformat.SHA1, commits); err != nil {
+			t.Fatalf("Failed to write graph: %v", err)
+		}
+		g, err := Open(objectsPath, objectformat.SHA1)
+		if err != nil {
+			t.Fatalf("Failed to open graph: %v", err)
+		}
+		for _, want := range commits {
+			got, ok := g.Lookup(want.Hash)
+			if !ok || len(got.Parents) != len(want.Parents) {
+				t.Fatalf("Lookup(%s) = %+v; want parents %v", want.Hash, got, want.Parents)
+			}
+			for i := range want.Parents {
+				if got.Parents[i] != want.Parents[i] {
+					t.Errorf("Lookup(%s) parents = %v; want %v", want.Hash, got.Parents, want.Parents)
+				}
+			}
+		}
+
+		if err := Write(objectsPath, objectformat.SHA1, commits[2:]); err == nil {
+			t.Error("Expected error when parents are missing from the graph")
+		}
+	})
+}
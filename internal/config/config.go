@@ -1,5 +1,188 @@
 package config
 
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
 const (
-	GitDirName = ".gitgo"
+	GitDirName      = ".gitgo"
+	StockGitDirName = ".git"
+	FileName        = "config"
 )
+
+// GitDirNames lists the metadata directories a repository may use, in the
+// order they are looked up when a repository is opened.
+var GitDirNames = []string{GitDirName, StockGitDirName}
+
+// Config holds the settings of a repository's git-style config file.
+// Keys are addressed as "section.name", e.g. "extensions.objectformat".
+type Config struct {
+	sections []*section
+}
+
+type section struct {
+	name   string
+	values []keyValue
+}
+
+type keyValue struct {
+	key   string
+	value string
+}
+
+// Load reads the config file in gitDir. A missing file yields an empty
+// config.
+func Load(gitDir string) (*Config, error) {
+	c := &Config{}
+	file, err := os.Open(filepath.Join(gitDir, FileName))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	defer file.Close()
+
+	var current *section
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid config section on line %d", lineNumber)
+			}
+			current = c.section(sectionName(line[1:end]), true)
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("config value outside of a section on line %d", lineNumber)
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			value = "true"
+		}
+		current.values = append(current.values, keyValue{
+			key:   strings.ToLower(strings.TrimSpace(key)),
+			value: strings.Trim(strings.TrimSpace(value), `"`),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	return c, nil
+}
+
+// sectionName turns `remote "origin"` into "remote.origin".
+func sectionName(header string) string {
+	name, sub, found := strings.Cut(strings.TrimSpace(header), " ")
+	name = strings.ToLower(name)
+	if !found {
+		return name
+	}
+	return name + "." + strings.Trim(strings.TrimSpace(sub), `"`)
+}
+
+func (c *Config) section(name string, create bool) *section {
+	for _, s := range c.sections {
+		if s.name == name {
+			return s
+		}
+	}
+	if !create {
+		return nil
+	}
+	s := &section{name: name}
+	c.sections = append(c.sections, s)
+	return s
+}
+
+func splitKey(key string) (string, string) {
+	i := strings.LastIndexByte(key, '.')
+	if i == -1 {
+		return "", strings.ToLower(key)
+	}
+	return strings.ToLower(key[:i]), strings.ToLower(key[i+1:])
+}
+
+// Get returns the last value set for key, or "" when it is not set.
+func (c *Config) Get(key string) string {
+	sectionKey, name := splitKey(key)
+	s := c.section(sectionKey, false)
+	if s == nil {
+		return ""
+	}
+	value := ""
+	for _, kv := range s.values {
+		if kv.key == name {
+			value = kv.value
+		}
+	}
+	return value
+}
+
+// Set replaces every value of key with value.
+func (c *Config) Set(key, value string) {
+	sectionKey, name := splitKey(key)
+	s := c.section(sectionKey, true)
+	for i := range s.values {
+		if s.values[i].key == name {
+			s.values[i].value = value
+			return
+		}
+	}
+	s.values = append(s.values, keyValue{key: name, value: value})
+}
+
+// Save writes the config file into gitDir.
+func (c *Config) Save(gitDir string) error {
+	var b strings.Builder
+	for _, s := range c.sections {
+		name, sub, found := strings.Cut(s.name, ".")
+		if found {
+			fmt.Fprintf(&b, "[%s %q]\n", name, sub)
+		} else {
+			fmt.Fprintf(&b, "[%s]\n", name)
+		}
+		for _, kv := range s.values {
+			fmt.Fprintf(&b, "\t%s = %s\n", kv.key, kv.value)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, FileName), []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+	return nil
+}
+
+// ParseSize parses an integer value with an optional k, m or g suffix,
+// as git does for size settings such as core.bigFileThreshold.
+func ParseSize(value string) (int64, error) {
+	multiplier := int64(1)
+	number := strings.ToLower(strings.TrimSpace(value))
+	if n := len(number); n > 0 {
+		switch number[n-1] {
+		case 'k':
+			multiplier = 1 << 10
+		case 'm':
+			multiplier = 1 << 20
+		case 'g':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			number = number[:n-1]
+		}
+	}
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return size * multiplier, nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDir := filepath.Join(cwd, "testdata")
+
+	t.Run("1.1: Read stock git config", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		defer os.RemoveAll(testDir)
+
+		content := "[core]\n\trepositoryformatversion = 1\n\tBare = false\n; comment\n" +
+			"[extensions]\n\tobjectFormat = sha256\n[remote \"origin\"]\n\turl = /tmp/repo\n"
+		os.WriteFile(filepath.Join(testDir, FileName), []byte(content), 0644)
+
+		cfg, err := Load(testDir)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		cases := map[string]string{
+			"core.repositoryformatversion": "1",
+			"core.bare":                    "false",
+			"extensions.objectformat":      "sha256",
+			"remote.origin.url":            "/tmp/repo",
+			"core.missing":                 "",
+		}
+		for key, want := range cases {
+			if got := cfg.Get(key); got != want {
+				t.Errorf("Get(%s) = %q; want %q", key, got, want)
+			}
+		}
+	})
+
+	t.Run("1.2: Save and reload", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		defer os.RemoveAll(testDir)
+
+		cfg, err := Load(testDir)
+		if err != nil {
+			t.Fatalf("Missing config should load as empty: %v", err)
+		}
+		cfg.Set("core.repositoryformatversion", "0")
+		cfg.Set("gc.pruneExpire", "2.weeks.ago")
+		cfg.Set("core.repositoryformatversion", "1")
+		if err := cfg.Save(testDir); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+
+		reloaded, err := Load(testDir)
+		if err != nil {
+			t.Fatalf("Failed to reload config: %v", err)
+		}
+		if got := reloaded.Get("core.repositoryformatversion"); got != "1" {
+			t.Errorf("repositoryformatversion = %q; want 1", got)
+		}
+		if got := reloaded.Get("gc.pruneexpire"); got != "2.weeks.ago" {
+			t.Errorf("gc.pruneexpire = %q; want 2.weeks.ago", got)
+		}
+	})
+
+	t.Run("1.3: Malformed config", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		defer os.RemoveAll(testDir)
+
+		os.WriteFile(filepath.Join(testDir, FileName), []byte("key = value\n"), 0644)
+		if _, err := Load(testDir); err == nil {
+			t.Error("Expected error for value outside of a section")
+		}
+	})
+	t.Run("1.4: Size values", func(t *testing.T) {
+		cases := map[string]int64{
+			"0":    0,
+			"4096": 4096,
+			"32k":  32 << 10,
+			"96m":  96 << 20,
+			"1G":   1 << 30,
+		}
+		for value, want := range cases {
+			if got, err := ParseSize(value); err != nil || got != want {
+				t.Errorf("ParseSize(%q) = %d, %v; want %d", value, got, err, want)
+			}
+		}
+		for _, value := range []string{"", "m", "-1", "12x", "ten"} {
+			if _, err := ParseSize(value); err == nil {
+				t.Errorf("Expected error for %q", value)
+			}
+		}
+	})
+}
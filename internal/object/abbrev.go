@@ -0,0 +1,128 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MinAbbrev is the shortest prefix accepted in place of a full object ID.
+const MinAbbrev = 4
+
+// DefaultAbbrev is the length object IDs are shortened to for display,
+// as in git. IDs that share that prefix with another object get a longer
+// one.
+const DefaultAbbrev = 7
+
+// PrefixStore is implemented by stores that can find objects by a prefix
+// of their ID without visiting every object.
+type PrefixStore interface {
+	ObjectStore
+	HashesWithPrefix(prefix string) ([]string, error)
+}
+
+// AmbiguousError is returned by Resolve when more than one object starts
+// with the given prefix.
+type AmbiguousError struct {
+	Prefix     string
+	Candidates []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("short object ID %s is ambiguous, candidates are:\n  %s",
+		e.Prefix, strings.Join(e.Candidates, "\n  "))
+}
+
+// HashesWithPrefix returns the sorted IDs of every stored object starting
+// with prefix.
+func HashesWithPrefix(store ObjectStore, prefix string) ([]string, error) {
+	var matches []string
+	if s, ok := store.(PrefixStore); ok {
+		var err error
+		if matches, err = s.HashesWithPrefix(prefix); err != nil {
+			return nil, err
+		}
+	} else {
+		err := store.Iterate(func(hash string) error {
+			if strings.HasPrefix(hash, prefix) {
+				matches = append(matches, hash)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// Resolve expands an abbreviated object ID of at least MinAbbrev hex
+// characters to the full ID of the single object it matches.
+func Resolve(store ObjectStore, prefix string) (string, error) {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < MinAbbrev || len(prefix) > store.Format().HexSize() || !isHex(prefix) {
+		return "", fmt.Errorf("invalid object ID %q", prefix)
+	}
+	if len(prefix) == store.Format().HexSize() {
+		if !store.Has(prefix) {
+			return "", fmt.Errorf("object %s not found", prefix)
+		}
+		return prefix, nil
+	}
+
+	matches, err := HashesWithPrefix(store, prefix)
+	if err != nil {
+		return "", err
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no object matches %s", prefix)
+	case 1:
+		return matches[0], nil
+	}
+	candidates := make([]string, len(matches))
+	for i, hash := range matches {
+		candidates[i] = hash
+		if objectType, _, err := ReadHeader(store, hash); err == nil {
+			candidates[i] += " " + objectType
+		}
+	}
+	return "", &AmbiguousError{Prefix: prefix, Candidates: candidates}
+}
+
+// Abbreviate returns the shortest prefix of hash, at least minLength
+// characters long, that no other stored object shares.
+func Abbreviate(store ObjectStore, hash string, minLength int) (string, error) {
+	length := max(minLength, MinAbbrev)
+	if length >= len(hash) {
+		return hash, nil
+	}
+	// Only objects sharing the shortest prefix can collide with a longer one.
+	matches, err := HashesWithPrefix(store, hash[:length])
+	if err != nil {
+		return "", err
+	}
+	for ; length < len(hash); length++ {
+		unique := true
+		for _, other := range matches {
+			if other != hash && strings.HasPrefix(other, hash[:length]) {
+				unique = false
+				break
+			}
+		}
+		if unique {
+			break
+		}
+	}
+	return hash[:length], nil
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
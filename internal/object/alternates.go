@@ -0,0 +1,136 @@
+package object
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AlternatesFile lists, one per line, other objects directories whose
+// objects a FileStore may read but never writes to. Relative paths are
+// taken from the objects directory and lines starting with # are
+// ignored, as in git.
+const AlternatesFile = "info/alternates"
+
+// maxAlternateDepth limits how many alternates of alternates are
+// followed, which also stops cycles.
+const maxAlternateDepth = 5
+
+// LocalStore is implemented by stores that may borrow objects from other
+// stores.
+type LocalStore interface {
+	ObjectStore
+	// HasLocal reports whether hash is stored without borrowing it.
+	HasLocal(hash string) bool
+}
+
+// HasLocal reports whether store keeps hash itself rather than reading
+// it from an alternate.
+func HasLocal(store ObjectStore, hash string) bool {
+	if s, ok := store.(LocalStore); ok {
+		return s.HasLocal(hash)
+	}
+	return store.Has(hash)
+}
+
+// ReadAlternates returns the absolute paths listed in the alternates
+// file of objectsPath. A missing file lists none.
+func ReadAlternates(objectsPath string) ([]string, error) {
+	file, err := os.Open(filepath.Join(objectsPath, AlternatesFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alternates: %v", err)
+	}
+	defer file.Close()
+
+	var paths []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(objectsPath, line)
+		}
+		paths = append(paths, filepath.Clean(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read alternates: %v", err)
+	}
+	return paths, nil
+}
+
+// AddAlternate appends dir to the alternates file of objectsPath unless
+// it is already listed.
+func AddAlternate(objectsPath, dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	existing, err := ReadAlternates(objectsPath)
+	if err != nil {
+		return err
+	}
+	for _, path := range existing {
+		if path == dir {
+			return nil
+		}
+	}
+	altPath := filepath.Join(objectsPath, AlternatesFile)
+	if err := os.MkdirAll(filepath.Dir(altPath), 0755); err != nil {
+		return fmt.Errorf("failed to create info directory: %v", err)
+	}
+	file, err := os.OpenFile(altPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write alternates: %v", err)
+	}
+	if _, err := fmt.Fprintln(file, dir); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write alternates: %v", err)
+	}
+	return file.Close()
+}
+
+// alternates opens the stores listed in the alternates file the first
+// time they are needed. Unreadable entries are skipped, as git does.
+func (s *FileStore) alternates() []*FileStore {
+	s.alternatesOnce.Do(func() {
+		if s.depth >= maxAlternateDepth {
+			return
+		}
+		paths, err := ReadAlternates(s.path)
+		if err != nil {
+			return
+		}
+		self, _ := filepath.Abs(s.path)
+		for _, path := range paths {
+			if path == self {
+				continue
+			}
+			if info, err := os.Stat(path); err != nil || !info.IsDir() {
+				continue
+			}
+			s.alternateStores = append(s.alternateStores, &FileStore{
+				path:   path,
+				format: s.format,
+				depth:  s.depth + 1,
+			})
+		}
+	})
+	return s.alternateStores
+}
+
+// borrowedFrom returns the first alternate that has hash, or nil.
+func (s *FileStore) borrowedFrom(hash string) *FileStore {
+	for _, alt := range s.alternates() {
+		if alt.Has(hash) {
+			return alt
+		}
+	}
+	return nil
+}
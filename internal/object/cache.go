@@ -0,0 +1,163 @@
+package object
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+// DefaultCacheLimit is the number of bytes of object content a
+// CachedStore keeps when no limit is configured.
+const DefaultCacheLimit = 32 << 20
+
+// CachedStore keeps recently read objects in memory so that walking the
+// same trees and commits again does not inflate them again. It is safe
+// for concurrent use; when full, the least recently used objects are
+// dropped first.
+type CachedStore struct {
+	store    ObjectStore
+	maxBytes int64
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	bytes   int64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// CacheStats reports how well a CachedStore is doing.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Objects int
+	Bytes   int64
+}
+
+type cacheEntry struct {
+	hash string
+	obj  *Object
+}
+
+// NewCachedStore wraps store with a cache holding up to maxBytes of
+// object content. Objects larger than an eighth of the limit are never
+// cached so that reading one big blob cannot evict every tree.
+func NewCachedStore(store ObjectStore, maxBytes int64) *CachedStore {
+	return &CachedStore{
+		store:    store,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Unwrap returns the store the cache reads through to.
+func (c *CachedStore) Unwrap() ObjectStore {
+	return c.store
+}
+
+func (c *CachedStore) Format() objectformat.Format {
+	return c.store.Format()
+}
+
+func (c *CachedStore) Has(hash string) bool {
+	if _, ok := c.lookup(hash); ok {
+		return true
+	}
+	return c.store.Has(hash)
+}
+
+// Get returns the object from the cache when present. The returned Data
+// is shared with the cache and must not be modified.
+func (c *CachedStore) Get(hash string) (*Object, error) {
+	if obj, ok := c.lookup(hash); ok {
+		c.hits.Add(1)
+		return &Object{Type: obj.Type, Size: obj.Size, Data: obj.Data}, nil
+	}
+	c.misses.Add(1)
+	obj, err := c.store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	c.add(hash, obj)
+	return &Object{Type: obj.Type, Size: obj.Size, Data: obj.Data}, nil
+}
+
+func (c *CachedStore) HasLocal(hash string) bool {
+	return HasLocal(c.store, hash)
+}
+
+func (c *CachedStore) Put(objectType string, data []byte) (string, error) {
+	return c.store.Put(objectType, data)
+}
+
+func (c *CachedStore) Iterate(fn func(hash string) error) error {
+	return c.store.Iterate(fn)
+}
+
+func (c *CachedStore) PutFrom(objectType string, r io.Reader, size int64) (string, error) {
+	return PutFrom(c.store, objectType, r, size)
+}
+
+// Open serves cached objects from memory and streams the rest from the
+// underlying store without caching them.
+func (c *CachedStore) Open(hash string) (string, int64, io.ReadCloser, error) {
+	if obj, ok := c.lookup(hash); ok {
+		return obj.Type, int64(obj.Size), io.NopCloser(bytes.NewReader(obj.Data)), nil
+	}
+	return Open(c.store, hash)
+}
+
+func (c *CachedStore) HashesWithPrefix(prefix string) ([]string, error) {
+	return HashesWithPrefix(c.store, prefix)
+}
+
+// Stats returns the hit and miss counts of Get since the store was
+// created together with what the cache currently holds.
+func (c *CachedStore) Stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Objects: len(c.entries),
+		Bytes:   c.bytes,
+	}
+}
+
+func (c *CachedStore) lookup(hash string) (*Object, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[hash]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).obj, true
+}
+
+func (c *CachedStore) add(hash string, obj *Object) {
+	size := int64(len(obj.Data))
+	if size > c.maxBytes/8 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.entries[hash]; ok {
+		return
+	}
+	c.entries[hash] = c.order.PushFront(&cacheEntry{hash: hash, obj: obj})
+	c.bytes += size
+	for c.bytes > c.maxBytes {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.hash)
+		c.bytes -= int64(len(entry.obj.Data))
+	}
+}
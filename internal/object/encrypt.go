@@ -0,0 +1,242 @@
+package object
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// KeySize is the length of an object encryption key in bytes.
+const KeySize = 32
+
+// An encrypted loose object starts with encryptedMagic and a random
+// salt, followed by the zlib stream split into segments that are each
+// sealed with AES-256-GCM. Every object is sealed with its own key,
+// derived from the repository key and the salt with HKDF-SHA256, so
+// nonces only have to be unique within one object. The nonce of a segment
+// is the segment number and a flag marking the last segment, so segments
+// cannot be reordered, dropped or cut off without failing authentication.
+const (
+	encryptedMagic      = "GGE\x02"
+	saltSize            = 32
+	encryptedHeaderSize = len(encryptedMagic) + saltSize
+	segmentSize         = 64 << 10
+	encryptedOverhead   = 16
+)
+
+// Cipher seals and opens the payloads of loose objects. Object IDs are
+// still computed over the plaintext, so identical content is stored once.
+type Cipher struct {
+	key   []byte
+	check string
+}
+
+// NewCipher returns a Cipher for a KeySize-byte AES-256 key.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("gitgo object encryption key check"))
+	return &Cipher{
+		key:   append([]byte(nil), key...),
+		check: hex.EncodeToString(mac.Sum(nil)[:8]),
+	}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// objectCipher derives the key of one object from its salt with HKDF
+// (RFC 5869). SHA-256 yields exactly one KeySize block, so the expand
+// step is a single HMAC.
+func (c *Cipher) objectCipher(salt []byte) (cipher.AEAD, error) {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(c.key)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte("gitgo object encryption\x01"))
+	return newGCM(expand.Sum(nil))
+}
+
+// GenerateKey returns a new random key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	return key, nil
+}
+
+// ParseKey decodes a key written as hex, as found in a key file or the
+// environment. Surrounding whitespace is ignored.
+func ParseKey(text string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(text))
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d hex characters", 2*KeySize)
+	}
+	return key, nil
+}
+
+// KeyCheck returns a short value derived from the key that can be stored
+// next to the objects to tell a wrong key from corrupt objects.
+func (c *Cipher) KeyCheck() string {
+	return c.check
+}
+
+// nonce returns the nonce of a segment: zeros, the segment number and
+// the last flag.
+func nonce(aead cipher.AEAD, counter uint32, last bool) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint32(nonce[len(nonce)-5:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// sealWriter encrypts everything written to it onto w. Close seals the
+// last segment and must be called for the output to be readable.
+type sealWriter struct {
+	aead    cipher.AEAD
+	w       io.Writer
+	counter uint32
+	buf     []byte
+}
+
+func (c *Cipher) newSealWriter(w io.Writer) (*sealWriter, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	aead, err := c.objectCipher(salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, encryptedMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+	return &sealWriter{
+		aead: aead,
+		w:    w,
+		buf:  make([]byte, 0, segmentSize),
+	}, nil
+}
+
+func (s *sealWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full segment is only sealed once more data arrives, since
+		// the last one has to be sealed differently.
+		if len(s.buf) == segmentSize {
+			if err := s.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(s.buf[len(s.buf):segmentSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (s *sealWriter) Close() error {
+	return s.seal(true)
+}
+
+func (s *sealWriter) seal(last bool) error {
+	if s.counter == ^uint32(0) {
+		return fmt.Errorf("object too large to encrypt")
+	}
+	sealed := s.aead.Seal(nil, nonce(s.aead, s.counter, last), s.buf, nil)
+	s.counter++
+	s.buf = s.buf[:0]
+	_, err := s.w.Write(sealed)
+	return err
+}
+
+// openReader decrypts and authenticates the segments read from r.
+type openReader struct {
+	aead    cipher.AEAD
+	r       *bufio.Reader
+	counter uint32
+	plain   []byte
+	done    bool
+}
+
+func (c *Cipher) newOpenReader(r io.Reader) (*openReader, error) {
+	magic := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, fmt.Errorf("object is not encrypted")
+	}
+	if string(magic) != encryptedMagic {
+		return nil, fmt.Errorf("object is not encrypted")
+	}
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return nil, fmt.Errorf("failed to decrypt object: truncated")
+	}
+	aead, err := c.objectCipher(salt)
+	if err != nil {
+		return nil, err
+	}
+	return &openReader{
+		aead: aead,
+		r:    bufio.NewReaderSize(r, segmentSize+encryptedOverhead+1),
+	}, nil
+}
+
+func (o *openReader) Read(p []byte) (int, error) {
+	for len(o.plain) == 0 {
+		if o.done {
+			return 0, io.EOF
+		}
+		if err := o.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, o.plain)
+	o.plain = o.plain[n:]
+	return n, nil
+}
+
+func (o *openReader) next() error {
+	sealed := make([]byte, segmentSize+encryptedOverhead)
+	n, err := io.ReadFull(o.r, sealed)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return fmt.Errorf("failed to decrypt object: truncated")
+		}
+		return err
+	}
+	last := err == io.ErrUnexpectedEOF
+	if !last {
+		if _, err := o.r.Peek(1); err == io.EOF {
+			last = true
+		}
+	}
+	plain, err := o.aead.Open(sealed[:0], nonce(o.aead, o.counter, last), sealed[:n], nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt object: %v", err)
+	}
+	o.counter++
+	o.plain = plain
+	o.done = last
+	return nil
+}
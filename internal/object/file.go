@@ -0,0 +1,466 @@
+package object
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+	"github.com/HalilFocic/gitgo/internal/pack"
+)
+
+// FileStore is the objects directory of a repository: zlib-compressed
+// loose objects under two-character fan-out directories, plus packs.
+// Objects it does not have are looked up in its alternates.
+type FileStore struct {
+	path   string
+	format objectformat.Format
+	cipher *Cipher
+
+	depth           int
+	alternatesOnce  sync.Once
+	alternateStores []*FileStore
+}
+
+func NewFileStore(objectsPath string, format objectformat.Format) *FileStore {
+	return &FileStore{
+		path:   objectsPath,
+		format: format,
+	}
+}
+
+// NewEncryptedFileStore returns a FileStore that seals the loose objects
+// it writes with c and only reads loose objects sealed with the same key.
+func NewEncryptedFileStore(objectsPath string, format objectformat.Format, c *Cipher) *FileStore {
+	return &FileStore{
+		path:   objectsPath,
+		format: format,
+		cipher: c,
+	}
+}
+
+func (s *FileStore) Format() objectformat.Format {
+	return s.format
+}
+
+// Encrypted reports whether loose objects are sealed with a key.
+func (s *FileStore) Encrypted() bool {
+	return s.cipher != nil
+}
+
+// Path returns the objects directory.
+func (s *FileStore) Path() string {
+	return s.path
+}
+
+func (s *FileStore) Has(hash string) bool {
+	return s.HasLocal(hash) || s.borrowedFrom(hash) != nil
+}
+
+// HasLocal reports whether hash is stored in this objects directory,
+// loose or packed, rather than in an alternate.
+func (s *FileStore) HasLocal(hash string) bool {
+	objectPath, err := Path(s.path, hash)
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(objectPath); err == nil {
+		return true
+	}
+	return s.hasPacked(hash)
+}
+
+func (s *FileStore) Get(hash string) (*Object, error) {
+	objectPath, err := Path(s.path, hash)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(objectPath)
+	if os.IsNotExist(err) {
+		obj, found, packErr := s.readPacked(hash)
+		if packErr != nil || found {
+			return obj, packErr
+		}
+		if alt := s.borrowedFrom(hash); alt != nil {
+			return alt.Get(hash)
+		}
+		return nil, fmt.Errorf("failed to read object file: %v", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object file: %v", err)
+	}
+	defer file.Close()
+
+	payload, err := s.payload(file)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := zlib.NewReader(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zlib reader: %v", err)
+	}
+	defer reader.Close()
+
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress data: %v", err)
+	}
+	return Parse(raw)
+}
+
+// readPacked looks hash up in the packs under the pack directory.
+func (s *FileStore) readPacked(hash string) (*Object, bool, error) {
+	objectType, data, found, err := pack.Lookup(filepath.Join(s.path, "pack"), hash)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return &Object{
+		Type: objectType,
+		Size: len(data),
+		Data: data,
+	}, true, nil
+}
+
+// Put stores data as a loose object of the given type and returns its
+// hash.
+func (s *FileStore) Put(objectType string, data []byte) (string, error) {
+	if err := checkType(objectType); err != nil {
+		return "", err
+	}
+	hash := Hash(s.format, objectType, data)
+	if s.stored(hash) {
+		return hash, nil
+	}
+	return s.PutFrom(objectType, bytes.NewReader(data), int64(len(data)))
+}
+
+// PutFrom stores size bytes read from r as a loose object, hashing and
+// compressing them as they stream through so the content is never held
+// in memory. The object is written to a temporary file, synced and
+// renamed into place, so a crash never leaves a truncated object behind.
+// Objects that are already stored intact, loose or packed, or that an
+// alternate has, are left alone.
+func (s *FileStore) PutFrom(objectType string, r io.Reader, size int64) (string, error) {
+	if err := checkType(objectType); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(s.path, 0755); err != nil {
+		return "", fmt.Errorf("failed to create objects directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(s.path, "tmp_obj_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create object file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var out io.Writer = tmp
+	var sealer *sealWriter
+	if s.cipher != nil {
+		if sealer, err = s.cipher.newSealWriter(tmp); err != nil {
+			return "", fmt.Errorf("failed to encrypt object: %v", err)
+		}
+		out = sealer
+	}
+	hasher := s.format.New()
+	zw := zlib.NewWriter(out)
+	writer := io.MultiWriter(zw, hasher)
+	if _, err := fmt.Fprintf(writer, "%s %d\x00", objectType, size); err != nil {
+		return "", fmt.Errorf("failed to compress data: %v", err)
+	}
+	if err := copyContent(writer, r, size); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress data: %v", err)
+	}
+	if sealer != nil {
+		if err := sealer.Close(); err != nil {
+			return "", fmt.Errorf("failed to encrypt object: %v", err)
+		}
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if s.stored(hash) {
+		return hash, nil
+	}
+	if err := tmp.Chmod(0444); err != nil {
+		return "", fmt.Errorf("failed to set object permissions: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", fmt.Errorf("failed to sync object file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write object file: %v", err)
+	}
+
+	objectPath := filepath.Join(s.path, hash[:2], hash[2:])
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create object directory: %v", err)
+	}
+	// A corrupt copy may be in the way; it is replaced by the new one.
+	if err := os.Rename(tmp.Name(), objectPath); err != nil {
+		return "", fmt.Errorf("failed to write object file: %v", err)
+	}
+	return hash, nil
+}
+
+// stored reports whether writing hash can be skipped: it is a loose
+// object that is still intact, sits in a local pack, or an alternate
+// has it.
+func (s *FileStore) stored(hash string) bool {
+	if s.exists(hash) || s.hasPacked(hash) {
+		return true
+	}
+	return s.borrowedFrom(hash) != nil
+}
+
+// hasPacked reports whether hash is in one of the local packs.
+func (s *FileStore) hasPacked(hash string) bool {
+	packs, err := s.packs()
+	if err != nil {
+		return false
+	}
+	for _, p := range packs {
+		if p.Has(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// exists reports whether hash is already stored as a loose object whose
+// content still matches its ID. The object's mtime is refreshed so that
+// it counts as recently written.
+func (s *FileStore) exists(hash string) bool {
+	objectPath := filepath.Join(s.path, hash[:2], hash[2:])
+	file, err := os.Open(objectPath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	payload, err := s.payload(file)
+	if err != nil {
+		return false
+	}
+	zr, err := zlib.NewReader(payload)
+	if err != nil {
+		return false
+	}
+	defer zr.Close()
+	hasher := s.format.New()
+	if _, err := io.Copy(hasher, zr); err != nil {
+		return false
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != hash {
+		return false
+	}
+	now := time.Now()
+	os.Chtimes(objectPath, now, now)
+	return true
+}
+
+// Open returns the type and size of the object together with a reader
+// over its content. Loose objects are inflated as the reader is consumed;
+// packed objects are read into memory first.
+func (s *FileStore) Open(hash string) (string, int64, io.ReadCloser, error) {
+	objectPath, err := Path(s.path, hash)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	file, err := os.Open(objectPath)
+	if os.IsNotExist(err) {
+		obj, found, packErr := s.readPacked(hash)
+		if packErr != nil {
+			return "", 0, nil, packErr
+		}
+		if found {
+			return obj.Type, int64(obj.Size), io.NopCloser(bytes.NewReader(obj.Data)), nil
+		}
+		if alt := s.borrowedFrom(hash); alt != nil {
+			return alt.Open(hash)
+		}
+		return "", 0, nil, fmt.Errorf("failed to read object file: %v", err)
+	}
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to read object file: %v", err)
+	}
+
+	payload, err := s.payload(file)
+	if err != nil {
+		file.Close()
+		return "", 0, nil, err
+	}
+	zr, err := zlib.NewReader(payload)
+	if err != nil {
+		file.Close()
+		return "", 0, nil, fmt.Errorf("failed to create zlib reader: %v", err)
+	}
+	reader := bufio.NewReader(zr)
+	header, err := reader.ReadBytes(0)
+	if err != nil {
+		file.Close()
+		return "", 0, nil, fmt.Errorf("invalid object header: no null byte found")
+	}
+	objectType, size, err := parseHeader(header[:len(header)-1])
+	if err != nil {
+		file.Close()
+		return "", 0, nil, err
+	}
+	return objectType, int64(size), &looseReader{
+		reader:    reader,
+		size:      int64(size),
+		remaining: int64(size),
+		zlib:      zr,
+		file:      file,
+	}, nil
+}
+
+// payload returns a reader over the zlib stream stored in a loose object
+// file, decrypting it when the store is encrypted.
+func (s *FileStore) payload(file io.Reader) (io.Reader, error) {
+	if s.cipher == nil {
+		return file, nil
+	}
+	return s.cipher.newOpenReader(file)
+}
+
+// looseReader streams the content of a loose object and closes both the
+// inflater and the underlying file. Like copyContent, it fails when
+// bytes are left over after the size in the header.
+type looseReader struct {
+	reader    io.Reader
+	size      int64
+	remaining int64
+	zlib      io.ReadCloser
+	file      *os.File
+}
+
+func (r *looseReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		var extra [1]byte
+		n, err := io.ReadFull(r.reader, extra[:])
+		if n != 0 {
+			return 0, fmt.Errorf("content length mismatch: expected %d, got more", r.size)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+func (r *looseReader) Close() error {
+	r.zlib.Close()
+	return r.file.Close()
+}
+
+// Iterate visits every loose object and then every packed object that is
+// not also stored loose. Objects borrowed from alternates are not
+// visited; they belong to the repository that stores them.
+func (s *FileStore) Iterate(fn func(hash string) error) error {
+	seen := make(map[string]bool)
+	fanout, err := os.ReadDir(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read objects directory: %v", err)
+	}
+	for _, dir := range fanout {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(s.path, dir.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read objects directory: %v", err)
+		}
+		for _, file := range files {
+			hash := dir.Name() + file.Name()
+			if !s.format.IsValid(hash) {
+				continue
+			}
+			seen[hash] = true
+			if err := fn(hash); err != nil {
+				return err
+			}
+		}
+	}
+
+	packs, err := s.packs()
+	if err != nil {
+		return err
+	}
+	for _, p := range packs {
+		for _, hash := range p.Hashes() {
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			if err := fn(hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// HashesWithPrefix lists the loose objects in the fan-out directory of
+// prefix together with the matching packed and borrowed objects.
+func (s *FileStore) HashesWithPrefix(prefix string) ([]string, error) {
+	if len(prefix) < 2 {
+		return nil, fmt.Errorf("prefix %q is too short", prefix)
+	}
+	seen := make(map[string]bool)
+	var matches []string
+	files, err := os.ReadDir(filepath.Join(s.path, prefix[:2]))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read objects directory: %v", err)
+	}
+	for _, file := range files {
+		hash := prefix[:2] + file.Name()
+		if s.format.IsValid(hash) && strings.HasPrefix(hash, prefix) {
+			seen[hash] = true
+			matches = append(matches, hash)
+		}
+	}
+
+	packs, err := s.packs()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packs {
+		for _, hash := range p.HashesWithPrefix(prefix) {
+			if !seen[hash] {
+				seen[hash] = true
+				matches = append(matches, hash)
+			}
+		}
+	}
+	for _, alt := range s.alternates() {
+		borrowed, err := alt.HashesWithPrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range borrowed {
+			if !seen[hash] {
+				seen[hash] = true
+				matches = append(matches, hash)
+			}
+		}
+	}
+	return matches, nil
+}
+
+func (s *FileStore) packs() ([]*pack.Pack, error) {
+	return pack.OpenDir(filepath.Join(s.path, "pack"), s.format)
+}
@@ -0,0 +1,80 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+// MemoryStore keeps objects in a map. It is meant for tests and for
+// building histories that never need to touch the disk.
+type MemoryStore struct {
+	format  objectformat.Format
+	mutex   sync.RWMutex
+	objects map[string]*Object
+}
+
+func NewMemoryStore(format objectformat.Format) *MemoryStore {
+	return &MemoryStore{
+		format:  format,
+		objects: make(map[string]*Object),
+	}
+}
+
+func (s *MemoryStore) Format() objectformat.Format {
+	return s.format
+}
+
+func (s *MemoryStore) Has(hash string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, ok := s.objects[hash]
+	return ok
+}
+
+func (s *MemoryStore) Get(hash string) (*Object, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	obj, ok := s.objects[hash]
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", hash)
+	}
+	return &Object{Type: obj.Type, Size: obj.Size, Data: obj.Data}, nil
+}
+
+func (s *MemoryStore) Put(objectType string, data []byte) (string, error) {
+	if err := checkType(objectType); err != nil {
+		return "", err
+	}
+	hash := Hash(s.format, objectType, data)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.objects[hash]; !ok {
+		s.objects[hash] = &Object{
+			Type: objectType,
+			Size: len(data),
+			Data: append([]byte(nil), data...),
+		}
+	}
+	return hash, nil
+}
+
+// Iterate visits objects in hash order.
+func (s *MemoryStore) Iterate(fn func(hash string) error) error {
+	s.mutex.RLock()
+	hashes := make([]string, 0, len(s.objects))
+	for hash := range s.objects {
+		hashes = append(hashes, hash)
+	}
+	s.mutex.RUnlock()
+
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		if err := fn(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package object
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+const (
+	TypeBlob   = "blob"
+	TypeTree   = "tree"
+	TypeCommit = "commit"
+	TypeTag    = "tag"
+)
+
+// Object is a stored object with its header already split off.
+type Object struct {
+	Type string
+	Size int
+	Data []byte
+}
+
+func Path(objectsPath, hash string) (string, error) {
+	format, err := objectformat.ForHash(hash)
+	if err != nil || !format.IsValid(hash) {
+		return "", fmt.Errorf("invalid object hash %q", hash)
+	}
+	return filepath.Join(objectsPath, hash[:2], hash[2:]), nil
+}
+
+// Parse splits the "<type> <size>\x00" header from raw, uncompressed
+// object bytes and checks the declared size.
+func Parse(raw []byte) (*Object, error) {
+	nullIndex := bytes.IndexByte(raw, 0)
+	if nullIndex == -1 {
+		return nil, fmt.Errorf("invalid object header: no null byte found")
+	}
+	objectType, size, err := parseHeader(raw[:nullIndex])
+	if err != nil {
+		return nil, err
+	}
+	data := raw[nullIndex+1:]
+	if len(data) != size {
+		return nil, fmt.Errorf("content length mismatch: expected %d, got %d", size, len(data))
+	}
+	return &Object{
+		Type: objectType,
+		Size: size,
+		Data: data,
+	}, nil
+}
+
+func parseHeader(header []byte) (string, int, error) {
+	parts := bytes.Fields(header)
+	if len(parts) != 2 {
+		return "", 0, fmt.Errorf("invalid object header %q", header)
+	}
+	objectType := string(parts[0])
+	if err := checkType(objectType); err != nil {
+		return "", 0, err
+	}
+	size, err := strconv.Atoi(string(parts[1]))
+	if err != nil || size < 0 {
+		return "", 0, fmt.Errorf("invalid object size %q", parts[1])
+	}
+	return objectType, size, nil
+}
+
+func checkType(objectType string) error {
+	switch objectType {
+	case TypeBlob, TypeTree, TypeCommit, TypeTag:
+		return nil
+	}
+	return fmt.Errorf("unknown object type %q", objectType)
+}
+
+// Hash returns the object ID of data stored as objectType, computed over
+// the uncompressed "<type> <size>\x00<data>" form.
+func Hash(format objectformat.Format, objectType string, data []byte) string {
+	return format.Sum(encode(objectType, data))
+}
+
+// HashFrom is Hash for size bytes read from r, which are hashed as they
+// stream rather than held in memory.
+func HashFrom(format objectformat.Format, objectType string, r io.Reader, size int64) (string, error) {
+	hasher := format.New()
+	fmt.Fprintf(hasher, "%s %d\x00", objectType, size)
+	if err := copyContent(hasher, r, size); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// copyContent copies exactly size bytes from r to w. A reader that ends
+// early or has bytes left over is an error, since the size is already
+// part of the object header.
+func copyContent(w io.Writer, r io.Reader, size int64) error {
+	n, err := io.Copy(w, io.LimitReader(r, size))
+	if err != nil {
+		return fmt.Errorf("failed to read content: %v", err)
+	}
+	if n != size {
+		return fmt.Errorf("content length mismatch: expected %d, got %d", size, n)
+	}
+	var extra [1]byte
+	if n, _ := io.ReadFull(r, extra[:]); n != 0 {
+		return fmt.Errorf("content length mismatch: expected %d, got more", size)
+	}
+	return nil
+}
+
+func encode(objectType string, data []byte) []byte {
+	header := fmt.Sprintf("%s %d\x00", objectType, len(data))
+	raw := make([]byte, 0, len(header)+len(data))
+	raw = append(raw, header...)
+	return append(raw, data...)
+}
@@ -0,0 +1,671 @@
+package object
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+	"github.com/HalilFocic/gitgo/internal/pack"
+)
+
+func writeLoose(t *testing.T, objectsPath, hash string, raw []byte) {
+	t.Helper()
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(raw)
+	zw.Close()
+
+	objectPath := filepath.Join(objectsPath, hash[:2], hash[2:])
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		t.Fatalf("Failed to create object directory: %v", err)
+	}
+	if err := os.WriteFile(objectPath, compressed.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write object: %v", err)
+	}
+}
+
+func TestObjectRead(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	objectsPath := filepath.Join(cwd, "testdata", "objects")
+	store := NewFileStore(objectsPath, objectformat.SHA1)
+	hash := "1234567890123456789012345678901234567890"
+
+	t.Run("1.1: Read any object type", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		cases := []struct {
+			raw      string
+			wantType string
+			wantData string
+		}{
+			{"blob 5\x00hello", TypeBlob, "hello"},
+			{"tree 0\x00", TypeTree, ""},
+			{"commit 3\x00abc", TypeCommit, "abc"},
+		}
+		for _, tc := range cases {
+			writeLoose(t, objectsPath, hash, []byte(tc.raw))
+
+			obj, err := store.Get(hash)
+			if err != nil {
+				t.Fatalf("Failed to read %s object: %v", tc.wantType, err)
+			}
+			if obj.Type != tc.wantType {
+				t.Errorf("Type = %s; want %s", obj.Type, tc.wantType)
+			}
+			if obj.Size != len(tc.wantData) || string(obj.Data) != tc.wantData {
+				t.Errorf("Data = %q (size %d); want %q", obj.Data, obj.Size, tc.wantData)
+			}
+
+			objectType, size, err := ReadHeader(store, hash)
+			if err != nil {
+				t.Fatalf("Failed to read header: %v", err)
+			}
+			if objectType != tc.wantType || size != len(tc.wantData) {
+				t.Errorf("Header = %s %d; want %s %d", objectType, size, tc.wantType, len(tc.wantData))
+			}
+		}
+	})
+
+	t.Run("1.2: Malformed objects", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		cases := []struct {
+			raw  string
+			desc string
+		}{
+			{"blob 5hello", "missing null byte"},
+			{"blob 9\x00hello", "size mismatch"},
+			{"banana 5\x00hello", "unknown type"},
+			{"blob five\x00hello", "invalid size"},
+		}
+		for _, tc := range cases {
+			writeLoose(t, objectsPath, hash, []byte(tc.raw))
+			if _, err := store.Get(hash); err == nil {
+				t.Errorf("Expected error for %s", tc.desc)
+			}
+		}
+	})
+
+	t.Run("1.3: Invalid hash", func(t *testing.T) {
+		for _, h := range []string{"", "12", "123456789012345678901234567890123456789g"} {
+			if _, err := store.Get(h); err == nil {
+				t.Errorf("Expected error for hash %q", h)
+			}
+		}
+	})
+}
+
+func TestObjectWrite(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	objectsPath := filepath.Join(cwd, "testdata", "objects")
+	store := NewFileStore(objectsPath, objectformat.SHA1)
+
+	t.Run("2.1: Objects are written read-only", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		hash, err := store.Put(TypeBlob, []byte("hello"))
+		if err != nil {
+			t.Fatalf("Failed to write object: %v", err)
+		}
+		objectPath, _ := Path(objectsPath, hash)
+		info, err := os.Stat(objectPath)
+		if err != nil {
+			t.Fatalf("Object was not written: %v", err)
+		}
+		if info.Mode().Perm() != 0444 {
+			t.Errorf("Mode = %v; want 0444", info.Mode().Perm())
+		}
+		entries, _ := os.ReadDir(objectsPath)
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				t.Errorf("Temporary file %s left behind", entry.Name())
+			}
+		}
+	})
+
+	t.Run("2.2: Existing objects are not rewritten", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		hash, err := store.Put(TypeBlob, []byte("hello"))
+		if err != nil {
+			t.Fatalf("Failed to write object: %v", err)
+		}
+		objectPath, _ := Path(objectsPath, hash)
+		before, _ := os.Stat(objectPath)
+
+		for _, write := range []func() (string, error){
+			func() (string, error) { return store.Put(TypeBlob, []byte("hello")) },
+			func() (string, error) {
+				return store.PutFrom(TypeBlob, bytes.NewReader([]byte("hello")), 5)
+			},
+		} {
+			again, err := write()
+			if err != nil || again != hash {
+				t.Fatalf("Rewrite returned %s, %v; want %s", again, err, hash)
+			}
+			after, _ := os.Stat(objectPath)
+			if !os.SameFile(before, after) {
+				t.Error("Valid object was replaced")
+			}
+		}
+	})
+
+	t.Run("2.3: Corrupt objects are replaced", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		hash, err := store.Put(TypeBlob, []byte("hello"))
+		if err != nil {
+			t.Fatalf("Failed to write object: %v", err)
+		}
+		objectPath, _ := Path(objectsPath, hash)
+		os.Chmod(objectPath, 0644)
+		writeLoose(t, objectsPath, hash, []byte("blob 5\x00jello"))
+
+		if _, err := store.Put(TypeBlob, []byte("hello")); err != nil {
+			t.Fatalf("Failed to write object: %v", err)
+		}
+		obj, err := store.Get(hash)
+		if err != nil {
+			t.Fatalf("Failed to read repaired object: %v", err)
+		}
+		if string(obj.Data) != "hello" {
+			t.Errorf("Object was not repaired: %q", obj.Data)
+		}
+	})
+
+	t.Run("2.4: Packed objects are not written loose", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		data := []byte("packed")
+		hash := Hash(objectformat.SHA1, TypeBlob, data)
+		entries := []pack.Entry{{Hash: hash, Type: TypeBlob, Data: data}}
+		if _, err := pack.Write(filepath.Join(objectsPath, "pack"), objectformat.SHA1, entries); err != nil {
+			t.Fatalf("Failed to write pack: %v", err)
+		}
+		for _, write := range []func() (string, error){
+			func() (string, error) { return store.Put(TypeBlob, data) },
+			func() (string, error) { return store.PutFrom(TypeBlob, bytes.NewReader(data), int64(len(data))) },
+		} {
+			if again, err := write(); err != nil || again != hash {
+				t.Fatalf("Write returned %s, %v; want %s", again, err, hash)
+			}
+			objectPath, _ := Path(objectsPath, hash)
+			if _, err := os.Stat(objectPath); err == nil {
+				t.Error("Packed object was written again as a loose object")
+			}
+		}
+	})
+
+	t.Run("2.5: Content must match the given size", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		for _, store := range []ObjectStore{store, NewMemoryStore(objectformat.SHA1)} {
+			for _, size := range []int64{4, 6} {
+				if _, err := PutFrom(store, TypeBlob, bytes.NewReader([]byte("hello")), size); err == nil {
+					t.Errorf("Expected error storing 5 bytes as %d in %T", size, store)
+				}
+			}
+		}
+		if _, err := HashFrom(objectformat.SHA1, TypeBlob, bytes.NewReader([]byte("hello")), 4); err == nil {
+			t.Error("Expected error hashing 5 bytes as 4")
+		}
+	})
+}
+
+func TestObjectStores(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+
+	t.Run("3.1: Memory store", func(t *testing.T) {
+		store := NewMemoryStore(objectformat.SHA1)
+		hash, err := store.Put(TypeBlob, []byte("hello"))
+		if err != nil {
+			t.Fatalf("Failed to put object: %v", err)
+		}
+		if hash != Hash(objectformat.SHA1, TypeBlob, []byte("hello")) {
+			t.Errorf("Unexpected hash %s", hash)
+		}
+		if !store.Has(hash) || store.Has(Hash(objectformat.SHA1, TypeBlob, nil)) {
+			t.Error("Has reports the wrong objects")
+		}
+		obj, err := store.Get(hash)
+		if err != nil {
+			t.Fatalf("Failed to get object: %v", err)
+		}
+		if obj.Type != TypeBlob || string(obj.Data) != "hello" {
+			t.Errorf("Got %s %q; want blob \"hello\"", obj.Type, obj.Data)
+		}
+		if _, err := store.Put("banana", nil); err == nil {
+			t.Error("Expected error for unknown object type")
+		}
+	})
+
+	t.Run("3.2: Iterate loose and packed objects", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+		objectsPath := filepath.Join(cwd, "testdata", "objects")
+
+		stores := []ObjectStore{
+			NewMemoryStore(objectformat.SHA1),
+			NewFileStore(objectsPath, objectformat.SHA1),
+		}
+		for _, store := range stores {
+			want := make(map[string]bool)
+			for _, content := range []string{"one", "two", "three"} {
+				hash, err := store.Put(TypeBlob, []byte(content))
+				if err != nil {
+					t.Fatalf("Failed to put object: %v", err)
+				}
+				want[hash] = true
+			}
+			if _, ok := store.(*FileStore); ok {
+				data := []byte("packed")
+				hash := Hash(objectformat.SHA1, TypeBlob, data)
+				entries := []pack.Entry{{Hash: hash, Type: TypeBlob, Data: data}}
+				if _, err := pack.Write(filepath.Join(objectsPath, "pack"), objectformat.SHA1, entries); err != nil {
+					t.Fatalf("Failed to write pack: %v", err)
+				}
+				want[hash] = true
+			}
+
+			got := make(map[string]bool)
+			err := store.Iterate(func(hash string) error {
+				if got[hash] {
+					t.Errorf("%s visited twice", hash)
+				}
+				got[hash] = true
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to iterate: %v", err)
+			}
+			if len(got) != len(want) {
+				t.Errorf("Visited %d objects; want %d", len(got), len(want))
+			}
+			for hash := range want {
+				if !got[hash] || !store.Has(hash) {
+					t.Errorf("Object %s missing from store", hash)
+				}
+			}
+		}
+	})
+}
+
+func TestResolve(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+
+	// putColliding stores blobs until two share their first MinAbbrev
+	// characters and returns both IDs.
+	putColliding := func(t *testing.T, store ObjectStore) (string, string) {
+		seen := make(map[string]string)
+		for i := 0; ; i++ {
+			hash, err := store.Put(TypeBlob, []byte(fmt.Sprintf("blob %d", i)))
+			if err != nil {
+				t.Fatalf("Failed to put object: %v", err)
+			}
+			if other, ok := seen[hash[:MinAbbrev]]; ok {
+				return other, hash
+			}
+			seen[hash[:MinAbbrev]] = hash
+		}
+	}
+
+	t.Run("4.1: Unique, ambiguous and unknown prefixes", func(t *testing.T) {
+		store := NewMemoryStore(objectformat.SHA1)
+		first, second := putColliding(t, store)
+
+		if _, err := Resolve(store, first[:MinAbbrev]); err == nil {
+			t.Fatal("Expected ambiguity error")
+		} else if amb, ok := err.(*AmbiguousError); !ok || len(amb.Candidates) != 2 {
+			t.Errorf("Expected two candidates, got %v", err)
+		} else if !strings.Contains(err.Error(), first) || !strings.Contains(err.Error(), second) {
+			t.Errorf("Candidates missing from error: %v", err)
+		}
+
+		short, err := Abbreviate(store, first, MinAbbrev)
+		if err != nil {
+			t.Fatalf("Failed to abbreviate: %v", err)
+		}
+		if len(short) <= MinAbbrev || !strings.HasPrefix(first, short) {
+			t.Errorf("Abbreviate(%s) = %s; want a longer unique prefix", first, short)
+		}
+		hash, err := Resolve(store, strings.ToUpper(short))
+		if err != nil || hash != first {
+			t.Errorf("Resolve(%s) = %s, %v; want %s", short, hash, err, first)
+		}
+		if hash, err := Resolve(store, first); err != nil || hash != first {
+			t.Errorf("Resolve of full ID = %s, %v", hash, err)
+		}
+
+		for _, bad := range []string{first[:MinAbbrev-1], "xyzw", first + "0"} {
+			if _, err := Resolve(store, bad); err == nil {
+				t.Errorf("Expected error for %q", bad)
+			}
+		}
+		missing := Hash(objectformat.SHA1, TypeBlob, []byte("missing"))
+		if _, err := Resolve(store, missing); err == nil {
+			t.Error("Expected error for missing full ID")
+		}
+	})
+
+	t.Run("4.2: Packed objects", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+		objectsPath := filepath.Join(cwd, "testdata", "objects")
+		store := NewFileStore(objectsPath, objectformat.SHA1)
+
+		data := []byte("packed")
+		hash := Hash(objectformat.SHA1, TypeBlob, data)
+		entries := []pack.Entry{{Hash: hash, Type: TypeBlob, Data: data}}
+		if _, err := pack.Write(filepath.Join(objectsPath, "pack"), objectformat.SHA1, entries); err != nil {
+			t.Fatalf("Failed to write pack: %v", err)
+		}
+		loose, err := store.Put(TypeBlob, []byte("loose"))
+		if err != nil {
+			t.Fatalf("Failed to put object: %v", err)
+		}
+
+		for _, want := range []string{hash, loose} {
+			got, err := Resolve(store, want[:MinAbbrev+2])
+			if err != nil || got != want {
+				t.Errorf("Resolve(%s) = %s, %v; want %s", want[:MinAbbrev+2], got, err, want)
+			}
+			if short, _ := Abbreviate(store, want, 7); short != want[:7] {
+				t.Errorf("Abbreviate(%s, 7) = %s", want, short)
+			}
+		}
+	})
+}
+
+func TestCachedStore(t *testing.T) {
+	t.Run("5.1: Hits, misses and eviction", func(t *testing.T) {
+		backing := NewMemoryStore(objectformat.SHA1)
+		cache := NewCachedStore(backing, 80)
+
+		var hashes []string
+		for i := 0; i < 3; i++ {
+			hash, err := cache.Put(TypeBlob, bytes.Repeat([]byte{byte('a' + i)}, 10))
+			if err != nil {
+				t.Fatalf("Failed to put object: %v", err)
+			}
+			hashes = append(hashes, hash)
+		}
+		for _, hash := range append(hashes, hashes...) {
+			obj, err := cache.Get(hash)
+			if err != nil || obj.Size != 10 {
+				t.Fatalf("Failed to get object %s: %v", hash, err)
+			}
+		}
+		if stats := cache.Stats(); stats.Hits != 3 || stats.Misses != 3 || stats.Objects != 3 || stats.Bytes != 30 {
+			t.Errorf("Stats = %+v; want 3 hits, 3 misses, 3 objects, 30 bytes", stats)
+		}
+
+		// Touch the first object so the second is the least recently used.
+		cache.Get(hashes[0])
+		for i := 0; i < 6; i++ {
+			hash, _ := backing.Put(TypeBlob, bytes.Repeat([]byte{byte('t' + i)}, 10))
+			cache.Get(hash)
+		}
+		stats := cache.Stats()
+		if stats.Bytes > 80 {
+			t.Errorf("Cache holds %d bytes; limit is 80", stats.Bytes)
+		}
+		misses := stats.Misses
+		cache.Get(hashes[1])
+		if cache.Stats().Misses != misses+1 {
+			t.Error("Least recently used object should have been evicted")
+		}
+
+		big, _ := backing.Put(TypeBlob, bytes.Repeat([]byte{'z'}, 11))
+		cache.Get(big)
+		cache.Get(big)
+		if cache.Stats().Misses != misses+3 {
+			t.Error("Objects over an eighth of the limit should not be cached")
+		}
+	})
+
+	t.Run("5.2: Concurrent readers", func(t *testing.T) {
+		backing := NewMemoryStore(objectformat.SHA1)
+		cache := NewCachedStore(backing, 1<<10)
+		var hashes []string
+		for i := 0; i < 50; i++ {
+			hash, _ := backing.Put(TypeBlob, []byte(fmt.Sprintf("object %d", i)))
+			hashes = append(hashes, hash)
+		}
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for _, hash := range hashes {
+					if _, err := cache.Get(hash); err != nil {
+						t.Errorf("Failed to get object %s: %v", hash, err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if stats := cache.Stats(); stats.Hits+stats.Misses != 8*50 {
+			t.Errorf("Stats = %+v; want %d lookups", stats, 8*50)
+		}
+	})
+}
+
+func TestEncryptedStore(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	objectsPath := filepath.Join(cwd, "testdata", "objects")
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %v", err)
+	}
+	store := NewEncryptedFileStore(objectsPath, objectformat.SHA1, c)
+
+	t.Run("6.1: Sealed on disk, plaintext IDs", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		// Several segments, so the sealing of full segments is covered.
+		large := bytes.Repeat([]byte("regulated data "), 3*segmentSize/15+7)
+		for _, data := range [][]byte{[]byte(""), []byte("hello"), large} {
+			hash, err := store.Put(TypeBlob, data)
+			if err != nil {
+				t.Fatalf("Failed to write object: %v", err)
+			}
+			if want := Hash(objectformat.SHA1, TypeBlob, data); hash != want {
+				t.Errorf("Hash = %s; want %s", hash, want)
+			}
+			objectPath, _ := Path(objectsPath, hash)
+			raw, _ := os.ReadFile(objectPath)
+			if !bytes.HasPrefix(raw, []byte(encryptedMagic)) {
+				t.Error("Object is not encrypted")
+			}
+			if _, err := NewFileStore(objectsPath, objectformat.SHA1).Get(hash); err == nil {
+				t.Error("Plain store read an encrypted object")
+			}
+
+			obj, err := store.Get(hash)
+			if err != nil || !bytes.Equal(obj.Data, data) {
+				t.Fatalf("Get returned %d bytes, %v", len(obj.Data), err)
+			}
+			_, size, reader, err := store.Open(hash)
+			if err != nil {
+				t.Fatalf("Failed to open object: %v", err)
+			}
+			streamed, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil || size != int64(len(data)) || !bytes.Equal(streamed, data) {
+				t.Errorf("Open returned %d of %d bytes, %v", len(streamed), size, err)
+			}
+		}
+	})
+
+	t.Run("6.2: Wrong key, tampering and truncation", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		// Random content does not compress, so it spans several segments.
+		data := make([]byte, 2*segmentSize)
+		rand.New(rand.NewSource(1)).Read(data)
+		hash, err := store.Put(TypeBlob, data)
+		if err != nil {
+			t.Fatalf("Failed to write object: %v", err)
+		}
+		otherKey, _ := GenerateKey()
+		other, _ := NewCipher(otherKey)
+		if other.KeyCheck() == c.KeyCheck() {
+			t.Error("Different keys share a key check")
+		}
+		if _, err := NewEncryptedFileStore(objectsPath, objectformat.SHA1, other).Get(hash); err == nil {
+			t.Error("Object decrypted with the wrong key")
+		}
+
+		objectPath, _ := Path(objectsPath, hash)
+		raw, _ := os.ReadFile(objectPath)
+		os.Chmod(objectPath, 0644)
+		tampered := append([]byte(nil), raw...)
+		tampered[len(tampered)-20] ^= 1
+		for name, content := range map[string][]byte{
+			"tampered":     tampered,
+			"truncated":    raw[:len(raw)-1],
+			"last dropped": raw[:encryptedHeaderSize+segmentSize+encryptedOverhead],
+			"header only":  raw[:encryptedHeaderSize],
+		} {
+			os.WriteFile(objectPath, content, 0644)
+			if _, err := store.Get(hash); err == nil {
+				t.Errorf("%s object was read", name)
+			}
+		}
+	})
+
+	t.Run("6.3: Every object has its own key", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		// The same plaintext sealed twice must not share salt or
+		// ciphertext.
+		var sealed [2]bytes.Buffer
+		for i := range sealed {
+			w, err := c.newSealWriter(&sealed[i])
+			if err != nil {
+				t.Fatalf("Failed to seal: %v", err)
+			}
+			w.Write([]byte("same content"))
+			w.Close()
+		}
+		if bytes.Equal(sealed[0].Bytes()[:encryptedHeaderSize], sealed[1].Bytes()[:encryptedHeaderSize]) ||
+			bytes.Equal(sealed[0].Bytes()[encryptedHeaderSize:], sealed[1].Bytes()[encryptedHeaderSize:]) {
+			t.Error("Two objects were sealed with the same salt or key")
+		}
+
+		// Only the current format is read.
+		hash, err := store.Put(TypeBlob, []byte("other format"))
+		if err != nil {
+			t.Fatalf("Failed to write object: %v", err)
+		}
+		objectPath, _ := Path(objectsPath, hash)
+		raw, _ := os.ReadFile(objectPath)
+		for _, magic := range []string{"GGE\x01", "GGE\x03"} {
+			os.WriteFile(objectPath, append([]byte(magic), raw[len(magic):]...), 0644)
+			if _, err := store.Get(hash); err == nil {
+				t.Errorf("Object with magic %q was read", magic)
+			}
+		}
+	})
+}
+
+func TestAlternates(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	sharedPath := filepath.Join(cwd, "testdata", "shared", "objects")
+	objectsPath := filepath.Join(cwd, "testdata", "objects")
+
+	t.Run("7.1: Borrowed objects are read, not copied", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		shared := NewFileStore(sharedPath, objectformat.SHA1)
+		borrowed, _ := shared.Put(TypeBlob, []byte("shared content"))
+		os.MkdirAll(filepath.Join(objectsPath, "info"), 0755)
+		os.WriteFile(filepath.Join(objectsPath, AlternatesFile),
+			[]byte("# shared objects\n../shared/objects\n"+objectsPath+"\n"), 0644)
+		store := NewFileStore(objectsPath, objectformat.SHA1)
+
+		if !store.Has(borrowed) || store.HasLocal(borrowed) {
+			t.Error("Object should be borrowed from the alternate")
+		}
+		if obj, err := store.Get(borrowed); err != nil || string(obj.Data) != "shared content" {
+			t.Errorf("Failed to read borrowed object: %v", err)
+		}
+		if _, _, reader, err := store.Open(borrowed); err != nil {
+			t.Errorf("Failed to open borrowed object: %v", err)
+		} else {
+			reader.Close()
+		}
+		if hash, err := Resolve(store, borrowed[:8]); err != nil || hash != borrowed {
+			t.Errorf("Resolve = %s, %v; want %s", hash, err, borrowed)
+		}
+
+		if _, err := store.Put(TypeBlob, []byte("shared content")); err != nil {
+			t.Fatalf("Failed to write object: %v", err)
+		}
+		if store.HasLocal(borrowed) {
+			t.Error("Borrowed object was copied on write")
+		}
+		local, _ := store.Put(TypeBlob, []byte("local content"))
+		var visited []string
+		store.Iterate(func(hash string) error {
+			visited = append(visited, hash)
+			return nil
+		})
+		if len(visited) != 1 || visited[0] != local {
+			t.Errorf("Iterate visited %v; want only %s", visited, local)
+		}
+		if shared.Has(local) {
+			t.Error("Object was written to the alternate")
+		}
+
+		if err := AddAlternate(objectsPath, sharedPath); err != nil {
+			t.Fatalf("Failed to add alternate: %v", err)
+		}
+		paths, _ := ReadAlternates(objectsPath)
+		if len(paths) != 2 || paths[0] != sharedPath {
+			t.Errorf("Alternates = %v; want %s listed once", paths, sharedPath)
+		}
+	})
+}
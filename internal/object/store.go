@@ -0,0 +1,69 @@
+package object
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+// ObjectStore is where a repository keeps its objects. blob, tree and
+// commit read and write through it, so the same code works against the
+// repository on disk and against an in-memory store in tests.
+type ObjectStore interface {
+	// Format is the object format used to compute IDs in this store.
+	Format() objectformat.Format
+	Has(hash string) bool
+	Get(hash string) (*Object, error)
+	// Put stores data as an object of the given type and returns its ID.
+	// Storing an object that already exists is not an error.
+	Put(objectType string, data []byte) (string, error)
+	// Iterate calls fn with the ID of every stored object and stops at the
+	// first error fn returns.
+	Iterate(fn func(hash string) error) error
+}
+
+// StreamStore is implemented by stores that can move object content
+// without holding all of it in memory.
+type StreamStore interface {
+	ObjectStore
+	PutFrom(objectType string, r io.Reader, size int64) (string, error)
+	Open(hash string) (string, int64, io.ReadCloser, error)
+}
+
+// PutFrom stores size bytes read from r, streaming them when store
+// supports it.
+func PutFrom(store ObjectStore, objectType string, r io.Reader, size int64) (string, error) {
+	if s, ok := store.(StreamStore); ok {
+		return s.PutFrom(objectType, r, size)
+	}
+	var data bytes.Buffer
+	if err := copyContent(&data, r, size); err != nil {
+		return "", err
+	}
+	return store.Put(objectType, data.Bytes())
+}
+
+// Open returns the type and size of hash together with a reader over its
+// content, streaming it when store supports it.
+func Open(store ObjectStore, hash string) (string, int64, io.ReadCloser, error) {
+	if s, ok := store.(StreamStore); ok {
+		return s.Open(hash)
+	}
+	obj, err := store.Get(hash)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	return obj.Type, int64(obj.Size), io.NopCloser(bytes.NewReader(obj.Data)), nil
+}
+
+// ReadHeader reports the type and size of hash without reading more of
+// its content than the store requires.
+func ReadHeader(store ObjectStore, hash string) (string, int, error) {
+	objectType, size, reader, err := Open(store, hash)
+	if err != nil {
+		return "", 0, err
+	}
+	reader.Close()
+	return objectType, int(size), nil
+}
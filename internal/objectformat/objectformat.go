@@ -0,0 +1,94 @@
+package objectformat
+
+import (
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/config"
+)
+
+// Format is the hash algorithm a repository uses for object IDs.
+type Format struct {
+	Name string
+	// Size is the length of a raw object ID in bytes.
+	Size int
+	hash crypto.Hash
+}
+
+var (
+	SHA1   = Format{Name: "sha1", Size: sha1.Size, hash: crypto.SHA1}
+	SHA256 = Format{Name: "sha256", Size: sha256.Size, hash: crypto.SHA256}
+)
+
+// ConfigKey is where the format is recorded in the repository config.
+const ConfigKey = "extensions.objectformat"
+
+// HexSize is the length of an object ID written as hex.
+func (f Format) HexSize() int {
+	return 2 * f.Size
+}
+
+func (f Format) New() hash.Hash {
+	return f.hash.New()
+}
+
+// Sum returns the hex encoded hash of data.
+func (f Format) Sum(data []byte) string {
+	h := f.hash.New()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Zero is the all-zero object ID, used for "no object".
+func (f Format) Zero() string {
+	return strings.Repeat("0", f.HexSize())
+}
+
+// IsValid reports whether hash is a full lowercase hex ID in this format.
+func (f Format) IsValid(hash string) bool {
+	if len(hash) != f.HexSize() {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func Parse(name string) (Format, error) {
+	switch name {
+	case "", SHA1.Name:
+		return SHA1, nil
+	case SHA256.Name:
+		return SHA256, nil
+	}
+	return Format{}, fmt.Errorf("unsupported object format %q", name)
+}
+
+// ForHash picks the format from the length of a full object ID.
+func ForHash(hash string) (Format, error) {
+	switch len(hash) {
+	case SHA1.HexSize():
+		return SHA1, nil
+	case SHA256.HexSize():
+		return SHA256, nil
+	}
+	return Format{}, fmt.Errorf("invalid object hash %q", hash)
+}
+
+// ForGitDir reads the format recorded in the repository config. A
+// repository without the setting uses SHA-1.
+func ForGitDir(gitDir string) (Format, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return Format{}, err
+	}
+	return Parse(cfg.Get(ConfigKey))
+}
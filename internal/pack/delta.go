@@ -0,0 +1,198 @@
+package pack
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	deltaBlockSize = 16
+	maxCopySize    = 0xffffff
+	maxInsertSize  = 0x7f
+)
+
+// Delta encodes target as a sequence of copy and insert instructions
+// against base, in the format git uses for OFS_DELTA and REF_DELTA
+// entries.
+func Delta(base, target []byte) []byte {
+	var out bytes.Buffer
+	writeDeltaSize(&out, len(base))
+	writeDeltaSize(&out, len(target))
+
+	blocks := make(map[string]int)
+	for i := 0; i+deltaBlockSize <= len(base); i += deltaBlockSize {
+		key := string(base[i : i+deltaBlockSize])
+		if _, exists := blocks[key]; !exists {
+			blocks[key] = i
+		}
+	}
+
+	var pending []byte
+	flush := func() {
+		for len(pending) > 0 {
+			n := min(len(pending), maxInsertSize)
+			out.WriteByte(byte(n))
+			out.Write(pending[:n])
+			pending = pending[n:]
+		}
+	}
+
+	i := 0
+	for i < len(target) {
+		if i+deltaBlockSize > len(target) {
+			pending = append(pending, target[i:]...)
+			break
+		}
+		offset, found := blocks[string(target[i:i+deltaBlockSize])]
+		if !found {
+			pending = append(pending, target[i])
+			i++
+			continue
+		}
+
+		// Grow the match backwards into pending literals, then forwards.
+		for offset > 0 && len(pending) > 0 && base[offset-1] == pending[len(pending)-1] {
+			offset--
+			i--
+			pending = pending[:len(pending)-1]
+		}
+		length := deltaBlockSize
+		for i+length < len(target) && offset+length < len(base) && target[i+length] == base[offset+length] {
+			length++
+		}
+		flush()
+		writeCopy(&out, offset, length)
+		i += length
+	}
+	flush()
+	return out.Bytes()
+}
+
+func writeDeltaSize(out *bytes.Buffer, size int) {
+	for {
+		b := byte(size & 0x7f)
+		size >>= 7
+		if size == 0 {
+			out.WriteByte(b)
+			return
+		}
+		out.WriteByte(b | 0x80)
+	}
+}
+
+func writeCopy(out *bytes.Buffer, offset, length int) {
+	for length > 0 {
+		n := min(length, maxCopySize)
+		op := byte(0x80)
+		var args []byte
+		for shift := 0; shift < 4; shift++ {
+			if b := byte(offset >> (8 * shift)); b != 0 {
+				op |= 1 << shift
+				args = append(args, b)
+			}
+		}
+		for shift := 0; shift < 3; shift++ {
+			if b := byte(n >> (8 * shift)); b != 0 {
+				op |= 1 << (4 + shift)
+				args = append(args, b)
+			}
+		}
+		out.WriteByte(op)
+		out.Write(args)
+		offset += n
+		length -= n
+	}
+}
+
+// ApplyDelta rebuilds the target object from base and a delta produced
+// by Delta or by git.
+func ApplyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != len(base) {
+		return nil, fmt.Errorf("delta base size mismatch: expected %d, got %d", baseSize, len(base))
+	}
+	targetSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	// targetSize comes from the delta itself. Reserve no more than the
+	// base and the delta could plausibly expand to, and let instructions
+	// that would go past targetSize fail rather than grow the target.
+	target := make([]byte, 0, min(targetSize, len(base)+len(delta)))
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 == 0 {
+			if op == 0 {
+				return nil, fmt.Errorf("invalid delta instruction 0")
+			}
+			n := int(op)
+			if n > len(delta) {
+				return nil, fmt.Errorf("truncated delta insert")
+			}
+			if len(target)+n > targetSize {
+				return nil, fmt.Errorf("delta target larger than %d bytes", targetSize)
+			}
+			target = append(target, delta[:n]...)
+			delta = delta[n:]
+			continue
+		}
+
+		var offset, length int
+		for shift := 0; shift < 4; shift++ {
+			if op&(1<<shift) != 0 {
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta copy")
+				}
+				offset |= int(delta[0]) << (8 * shift)
+				delta = delta[1:]
+			}
+		}
+		for shift := 0; shift < 3; shift++ {
+			if op&(1<<(4+shift)) != 0 {
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta copy")
+				}
+				length |= int(delta[0]) << (8 * shift)
+				delta = delta[1:]
+			}
+		}
+		if length == 0 {
+			length = 0x10000
+		}
+		if offset+length > len(base) {
+			return nil, fmt.Errorf("delta copy out of range")
+		}
+		if len(target)+length > targetSize {
+			return nil, fmt.Errorf("delta target larger than %d bytes", targetSize)
+		}
+		target = append(target, base[offset:offset+length]...)
+	}
+
+	if len(target) != targetSize {
+		return nil, fmt.Errorf("delta target size mismatch: expected %d, got %d", targetSize, len(target))
+	}
+	return target, nil
+}
+
+func readDeltaSize(delta []byte) (int, []byte, error) {
+	size := 0
+	shift := 0
+	for i, b := range delta {
+		// Sizes past 2^56 cannot be real and would overflow size.
+		if shift > 49 {
+			return 0, nil, fmt.Errorf("delta size too large")
+		}
+		size |= int(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			return size, delta[i+1:], nil
+		}
+	}
+	return 0, nil, fmt.Errorf("truncated delta header")
+}
@@ -0,0 +1,51 @@
+package pack
+
+import (
+	"fmt"
+)
+
+// Object types as encoded in a pack entry header.
+const (
+	typeCommit   = 1
+	typeTree     = 2
+	typeBlob     = 3
+	typeTag      = 4
+	typeOfsDelta = 6
+	typeRefDelta = 7
+)
+
+const (
+	packSignature = "PACK"
+	packVersion   = 2
+	idxVersion    = 2
+)
+
+var idxSignature = []byte{0xff, 't', 'O', 'c'}
+
+func typeCode(objectType string) (int, error) {
+	switch objectType {
+	case "commit":
+		return typeCommit, nil
+	case "tree":
+		return typeTree, nil
+	case "blob":
+		return typeBlob, nil
+	case "tag":
+		return typeTag, nil
+	}
+	return 0, fmt.Errorf("unknown object type %q", objectType)
+}
+
+func typeName(code int) (string, error) {
+	switch code {
+	case typeCommit:
+		return "commit", nil
+	case typeTree:
+		return "tree", nil
+	case typeBlob:
+		return "blob", nil
+	case typeTag:
+		return "tag", nil
+	}
+	return "", fmt.Errorf("unknown pack object type %d", code)
+}
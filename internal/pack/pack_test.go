@@ -0,0 +1,379 @@
+package pack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+func testEntry(objectType, content, path string) Entry {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s %d\x00%s", objectType, len(content), content)))
+	return Entry{
+		Hash: hex.EncodeToString(sum[:]),
+		Type: objectType,
+		Data: []byte(content),
+		Path: path,
+	}
+}
+
+func TestDelta(t *testing.T) {
+	t.Run("1.1: Delta round trip", func(t *testing.T) {
+		base := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 50))
+		cases := []struct {
+			target []byte
+			desc   string
+		}{
+			{append([]byte("header\n"), base...), "prepended text"},
+			{append(append([]byte{}, base[:500]...), []byte("middle\n")...), "truncated and appended"},
+			{[]byte("completely different"), "no shared content"},
+			{[]byte{}, "empty target"},
+			{base, "identical"},
+		}
+		for _, tc := range cases {
+			delta := Delta(base, tc.target)
+			got, err := ApplyDelta(base, delta)
+			if err != nil {
+				t.Fatalf("Failed to apply delta (%s): %v", tc.desc, err)
+			}
+			if !bytes.Equal(got, tc.target) {
+				t.Errorf("Delta round trip mismatch (%s)", tc.desc)
+			}
+		}
+	})
+
+	t.Run("1.2: Similar content produces a small delta", func(t *testing.T) {
+		base := []byte(strings.Repeat("0123456789abcdef", 1000))
+		target := append([]byte("x"), base...)
+		if delta := Delta(base, target); len(delta) > 64 {
+			t.Errorf("Expected a small delta, got %d bytes", len(delta))
+		}
+	})
+
+	t.Run("1.3: Corrupt delta", func(t *testing.T) {
+		base := []byte("base content")
+		delta := Delta(base, []byte("base content and more"))
+		if _, err := ApplyDelta([]byte("other"), delta); err == nil {
+			t.Error("Expected error for wrong base size")
+		}
+		if _, err := ApplyDelta(base, delta[:len(delta)-3]); err == nil {
+			t.Error("Expected error for truncated delta")
+		}
+	})
+
+	t.Run("1.4: Delta larger than it declares", func(t *testing.T) {
+		base := []byte("base content")
+		// A target size of 2^49 followed by an insert and a copy.
+		huge := []byte{byte(len(base)), 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01, 1, 'x'}
+		if _, err := ApplyDelta(base, huge); err == nil {
+			t.Error("Expected error for a delta shorter than its target")
+		}
+		short := []byte{byte(len(base)), 3, 0x90, byte(len(base))}
+		if _, err := ApplyDelta(base, short); err == nil {
+			t.Error("Expected error for a copy past the target size")
+		}
+		overflow := append([]byte{byte(len(base))}, bytes.Repeat([]byte{0xff}, 10)...)
+		if _, err := ApplyDelta(base, append(overflow, 0x01)); err == nil {
+			t.Error("Expected error for a size that overflows")
+		}
+	})
+}
+
+func TestPackWriteRead(t *testing.T) {
+	cwd, _ := os.Getwd()
+	packDir := filepath.Join(cwd, "testdata", "pack")
+
+	t.Run("2.1: Write and read back objects", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		content := strings.Repeat("line of text\n", 200)
+		entries := []Entry{
+			testEntry("commit", "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n\nmsg", ""),
+			testEntry("tree", "", ""),
+			testEntry("blob", content, "file.txt"),
+			testEntry("blob", content+"one more line\n", "file.txt"),
+			testEntry("blob", "changed\n"+content+"another line\n", "file.txt"),
+			testEntry("blob", "small", "other.txt"),
+		}
+
+		packPath, err := Write(packDir, objectformat.SHA1, entries)
+		if err != nil {
+			t.Fatalf("Failed to write pack: %v", err)
+		}
+		if _, err := os.Stat(strings.TrimSuffix(packPath, ".pack") + ".idx"); err != nil {
+			t.Fatalf("Index file missing: %v", err)
+		}
+
+		for _, entry := range entries {
+			objectType, data, found, err := Lookup(packDir, entry.Hash)
+			if err != nil || !found {
+				t.Fatalf("Failed to look up %s: found=%v err=%v", entry.Hash, found, err)
+			}
+			if objectType != entry.Type || !bytes.Equal(data, entry.Data) {
+				t.Errorf("Object %s mismatch: got %s %q", entry.Hash, objectType, data)
+			}
+		}
+
+		packData, _ := os.ReadFile(packPath)
+		if len(packData) >= 3*len(content) {
+			t.Errorf("Expected similar blobs to be delta compressed, pack is %d bytes", len(packData))
+		}
+	})
+
+	t.Run("2.2: Missing object", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		if _, err := Write(packDir, objectformat.SHA1, []Entry{testEntry("blob", "present", "")}); err != nil {
+			t.Fatalf("Failed to write pack: %v", err)
+		}
+		missing := testEntry("blob", "absent", "")
+		_, _, found, err := Lookup(packDir, missing.Hash)
+		if err != nil || found {
+			t.Errorf("Expected missing object to be reported as not found, found=%v err=%v", found, err)
+		}
+	})
+
+	t.Run("2.3: Corrupt fanout table", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+
+		entries := []Entry{
+			testEntry("blob", "one", ""),
+			testEntry("blob", "two", ""),
+			testEntry("blob", "three", ""),
+		}
+		packPath, err := Write(packDir, objectformat.SHA1, entries)
+		if err != nil {
+			t.Fatalf("Failed to write pack: %v", err)
+		}
+		idxPath := strings.TrimSuffix(packPath, ".pack") + ".idx"
+		original, _ := os.ReadFile(idxPath)
+
+		for name, corrupt := range map[string]func(fanout []byte){
+			// A bucket past the object count, then a smaller one.
+			"decreasing": func(fanout []byte) {
+				binary.BigEndian.PutUint32(fanout, 0xffffffff)
+			},
+			// Every object counted in the last bucket only.
+			"misplaced": func(fanout []byte) {
+				for i := 0; i < 255; i++ {
+					binary.BigEndian.PutUint32(fanout[i*4:], 0)
+				}
+			},
+		} {
+			data := bytes.Clone(original)
+			corrupt(data[8 : 8+256*4])
+			os.WriteFile(idxPath, data, 0644)
+			if _, err := Open(idxPath, objectformat.SHA1); err == nil || !strings.Contains(err.Error(), "corrupt pack index") {
+				t.Errorf("Open with %s fanout = %v; want corrupt pack index", name, err)
+			}
+			if _, _, _, err := Lookup(packDir, entries[0].Hash); err == nil {
+				t.Errorf("Lookup with %s fanout succeeded", name)
+			}
+		}
+	})
+}
+
+// writeRefDeltaPack builds a pack by hand whose second entry is a
+// REF_DELTA against base, which Write itself never produces.
+func writeRefDeltaPack(t *testing.T, packDir string, base, target Entry, includeBase bool) {
+	t.Helper()
+	os.MkdirAll(packDir, 0755)
+
+	var records []record
+	var body bytes.Buffer
+	body.WriteString(packSignature)
+	count := 1
+	if includeBase {
+		count = 2
+	}
+	body.Write([]byte{0, 0, 0, packVersion, 0, 0, 0, byte(count)})
+
+	if includeBase {
+		raw, _, err := encodeEntry(base, 0, nil)
+		if err != nil {
+			t.Fatalf("Failed to encode base: %v", err)
+		}
+		hash, _ := hex.DecodeString(base.Hash)
+		records = append(records, record{hash: hash, offset: int64(body.Len())})
+		body.Write(raw)
+	}
+
+	var raw bytes.Buffer
+	delta := Delta(base.Data, target.Data)
+	writeEntryHeader(&raw, typeRefDelta, len(delta))
+	baseHash, _ := hex.DecodeString(base.Hash)
+	raw.Write(baseHash)
+	zw := zlib.NewWriter(&raw)
+	zw.Write(delta)
+	zw.Close()
+	hash, _ := hex.DecodeString(target.Hash)
+	records = append(records, record{hash: hash, offset: int64(body.Len())})
+	body.Write(raw.Bytes())
+
+	checksum := sha1.Sum(body.Bytes())
+	body.Write(checksum[:])
+	name := filepath.Join(packDir, "pack-"+hex.EncodeToString(checksum[:]))
+	if err := os.WriteFile(name+".pack", body.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write pack: %v", err)
+	}
+	if err := writeIndex(name+".idx", objectformat.SHA1, records, checksum[:]); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+}
+
+func TestRefDelta(t *testing.T) {
+	cwd, _ := os.Getwd()
+	packDir := filepath.Join(cwd, "testdata", "pack")
+	content := strings.Repeat("shared line\n", 100)
+	base := testEntry("blob", content, "")
+	target := testEntry("blob", content+"tail\n", "")
+
+	t.Run("3.1: REF_DELTA base in the same pack", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+		writeRefDeltaPack(t, packDir, base, target, true)
+
+		objectType, data, found, err := Lookup(packDir, target.Hash)
+		if err != nil || !found {
+			t.Fatalf("Failed to read REF_DELTA object: found=%v err=%v", found, err)
+		}
+		if objectType != "blob" || !bytes.Equal(data, target.Data) {
+			t.Errorf("REF_DELTA object mismatch: got %s %q", objectType, data)
+		}
+	})
+
+	t.Run("3.2: REF_DELTA base in another pack", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+		writeRefDeltaPack(t, packDir, base, target, false)
+
+		packs, _ := OpenDir(packDir, objectformat.SHA1)
+		if _, _, err := packs[0].Read(target.Hash); err == nil {
+			t.Error("Expected error when the base is missing")
+		}
+
+		if _, err := Write(packDir, objectformat.SHA1, []Entry{base}); err != nil {
+			t.Fatalf("Failed to write base pack: %v", err)
+		}
+		_, data, found, err := Lookup(packDir, target.Hash)
+		if err != nil || !found {
+			t.Fatalf("Failed to resolve base from sibling pack: found=%v err=%v", found, err)
+		}
+		if !bytes.Equal(data, target.Data) {
+			t.Error("Resolved object content mismatch")
+		}
+	})
+
+	t.Run("3.3: REF_DELTA entries naming each other", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+		// Each pack stores one object as a delta against the other's.
+		writeRefDeltaPack(t, packDir, base, target, false)
+		writeRefDeltaPack(t, packDir, target, base, false)
+
+		if _, _, _, err := Lookup(packDir, target.Hash); err == nil {
+			t.Error("Expected error for a delta cycle")
+		}
+	})
+
+	t.Run("3.4: Cached bases are not shared", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+		writeRefDeltaPack(t, packDir, base, target, true)
+
+		packs, _ := OpenDir(packDir, objectformat.SHA1)
+		packs[0].Read(target.Hash)
+		_, data, err := packs[0].Read(base.Hash)
+		if err != nil || !bytes.Equal(data, base.Data) {
+			t.Fatalf("Read base = %q, %v", data, err)
+		}
+		data[0] ^= 0xff
+		if _, again, _ := packs[0].Read(target.Hash); !bytes.Equal(again, target.Data) {
+			t.Error("Changing a returned object changed the cached base")
+		}
+	})
+}
+
+func TestUnpack(t *testing.T) {
+	cwd, _ := os.Getwd()
+	packDir := filepath.Join(cwd, "testdata", "pack")
+	content := strings.Repeat("shared line\n", 100)
+	base := testEntry("blob", content, "file")
+	target := testEntry("blob", content+"tail\n", "file")
+
+	t.Run("4.1: Unpack a written stream", func(t *testing.T) {
+		entries := []Entry{testEntry("tree", "tree data", ""), base, target}
+		var stream bytes.Buffer
+		if err := WriteStream(&stream, objectformat.SHA1, entries); err != nil {
+			t.Fatalf("Failed to write stream: %v", err)
+		}
+		unpacked, err := Unpack(&stream, objectformat.SHA1, nil)
+		if err != nil {
+			t.Fatalf("Failed to unpack: %v", err)
+		}
+		got := make(map[string]Entry)
+		for _, e := range unpacked {
+			got[e.Hash] = e
+		}
+		for _, want := range entries {
+			if e, ok := got[want.Hash]; !ok || e.Type != want.Type || !bytes.Equal(e.Data, want.Data) {
+				t.Errorf("Unpacked %s = %+v", want.Hash, e)
+			}
+		}
+		if len(unpacked) != len(entries) {
+			t.Errorf("Unpacked %d objects; want %d", len(unpacked), len(entries))
+		}
+	})
+
+	t.Run("4.2: Unpack a thin pack", func(t *testing.T) {
+		os.RemoveAll(filepath.Join(cwd, "testdata"))
+		defer os.RemoveAll(filepath.Join(cwd, "testdata"))
+		writeRefDeltaPack(t, packDir, base, target, false)
+		paths, _ := filepath.Glob(filepath.Join(packDir, "*.pack"))
+		data, _ := os.ReadFile(paths[0])
+
+		if _, err := Unpack(bytes.NewReader(data), objectformat.SHA1, nil); err == nil {
+			t.Error("Expected error for a missing delta base")
+		}
+		resolve := func(hash string) (string, []byte, error) {
+			if hash != base.Hash {
+				return "", nil, fmt.Errorf("unexpected base %s", hash)
+			}
+			return base.Type, base.Data, nil
+		}
+		unpacked, err := Unpack(bytes.NewReader(data), objectformat.SHA1, resolve)
+		if err != nil || len(unpacked) != 1 || unpacked[0].Hash != target.Hash {
+			t.Errorf("Unpack = %v, %v; want only %s", unpacked, err, target.Hash)
+		}
+	})
+
+	t.Run("4.3: Corrupt stream", func(t *testing.T) {
+		var stream bytes.Buffer
+		WriteStream(&stream, objectformat.SHA1, []Entry{base})
+		data := stream.Bytes()
+		data[len(data)-1] ^= 0xff
+		if _, err := Unpack(bytes.NewReader(data), objectformat.SHA1, nil); err == nil {
+			t.Error("Expected error for a bad checksum")
+		}
+		if _, err := Unpack(bytes.NewReader(data[:20]), objectformat.SHA1, nil); err == nil {
+			t.Error("Expected error for a truncated pack")
+		}
+		// A header claiming four billion entries must not reserve room
+		// for them before finding the stream ends.
+		claimed := append([]byte(packSignature), 0, 0, 0, packVersion, 0xff, 0xff, 0xff, 0xff)
+		if _, err := Unpack(bytes.NewReader(claimed), objectformat.SHA1, nil); err == nil {
+			t.Error("Expected error for a pack shorter than its count")
+		}
+	})
+}
@@ -0,0 +1,430 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+const (
+	maxCachedBases = 256
+
+	// maxReadDepth bounds how many bases are read to rebuild one object,
+	// so that REF_DELTA entries naming each other fail instead of
+	// recursing forever. git never writes deeper chains than this.
+	maxReadDepth = 4095
+)
+
+// Pack is an opened pack file whose .idx has been loaded into memory.
+type Pack struct {
+	path    string
+	format  objectformat.Format
+	fanout  [256]uint32
+	hashes  []byte
+	offsets []int64
+
+	basesMutex sync.Mutex
+	bases      map[int64]cachedBase
+}
+
+// cachedBase is an object already resolved while walking a delta chain.
+type cachedBase struct {
+	code int
+	data []byte
+}
+
+// Resolver returns an object that is not stored in the pack being read.
+// It is consulted for REF_DELTA bases that live elsewhere.
+type Resolver func(hash string) (objectType string, data []byte, err error)
+
+// Open loads the index at idxPath, whose object IDs use format. The
+// matching .pack file is only opened when objects are read.
+func Open(idxPath string, format objectformat.Format) (*Pack, error) {
+	data, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pack index: %v", err)
+	}
+	if len(data) < 8+256*4 || !bytes.Equal(data[:4], idxSignature) {
+		return nil, fmt.Errorf("invalid pack index %s", idxPath)
+	}
+	if version := binary.BigEndian.Uint32(data[4:8]); version != idxVersion {
+		return nil, fmt.Errorf("unsupported pack index version %d", version)
+	}
+
+	p := &Pack{
+		path:   strings.TrimSuffix(idxPath, ".idx") + ".pack",
+		format: format,
+	}
+	hashSize := format.Size
+	pos := 8
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(data[pos:])
+		pos += 4
+		if i > 0 && p.fanout[i-1] > p.fanout[i] {
+			return nil, fmt.Errorf("corrupt pack index %s", idxPath)
+		}
+	}
+	count := int(p.fanout[255])
+
+	if len(data) < pos+count*(hashSize+4+4)+2*hashSize {
+		return nil, fmt.Errorf("truncated pack index %s", idxPath)
+	}
+	p.hashes = data[pos : pos+count*hashSize]
+	pos += count * hashSize
+	// find and HashesWithPrefix only search the fanout range of an ID's
+	// first byte, so every ID has to be inside it.
+	for i := 0; i < count; i++ {
+		first := p.hashes[i*hashSize]
+		lo := uint32(0)
+		if first > 0 {
+			lo = p.fanout[first-1]
+		}
+		if uint32(i) < lo || uint32(i) >= p.fanout[first] {
+			return nil, fmt.Errorf("corrupt pack index %s", idxPath)
+		}
+	}
+	pos += count * 4 // CRC32 values
+
+	largeOffsets := pos + count*4
+	p.offsets = make([]int64, count)
+	for i := range p.offsets {
+		offset := binary.BigEndian.Uint32(data[pos:])
+		pos += 4
+		if offset&0x80000000 == 0 {
+			p.offsets[i] = int64(offset)
+			continue
+		}
+		// Offsets past 2GiB are stored in a separate table of 64-bit values.
+		at := largeOffsets + int(offset&0x7fffffff)*8
+		if at+8 > len(data)-2*hashSize {
+			return nil, fmt.Errorf("invalid large offset in pack index %s", idxPath)
+		}
+		p.offsets[i] = int64(binary.BigEndian.Uint64(data[at:]))
+	}
+	p.bases = make(map[int64]cachedBase)
+	return p, nil
+}
+
+// Path returns the location of the .pack file.
+func (p *Pack) Path() string {
+	return p.path
+}
+
+// Hashes returns every object ID stored in the pack, in sorted order.
+func (p *Pack) Hashes() []string {
+	hashSize := p.format.Size
+	hashes := make([]string, len(p.offsets))
+	for i := range hashes {
+		hashes[i] = hex.EncodeToString(p.hashes[i*hashSize : (i+1)*hashSize])
+	}
+	return hashes
+}
+
+func (p *Pack) find(hash string) (int, bool) {
+	hashSize := p.format.Size
+	key, err := hex.DecodeString(hash)
+	if err != nil || len(key) != hashSize {
+		return 0, false
+	}
+	lo := 0
+	if key[0] > 0 {
+		lo = int(p.fanout[key[0]-1])
+	}
+	hi := int(p.fanout[key[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashes[(lo+i)*hashSize:(lo+i+1)*hashSize], key) >= 0
+	})
+	if i < hi && bytes.Equal(p.hashes[i*hashSize:(i+1)*hashSize], key) {
+		return i, true
+	}
+	return 0, false
+}
+
+// HashesWithPrefix returns the object IDs in the pack that start with the
+// hex prefix, which must be at least two characters long.
+func (p *Pack) HashesWithPrefix(prefix string) []string {
+	first, err := hex.DecodeString(prefix[:2])
+	if err != nil {
+		return nil
+	}
+	lo := 0
+	if first[0] > 0 {
+		lo = int(p.fanout[first[0]-1])
+	}
+	hi := int(p.fanout[first[0]])
+
+	hashSize := p.format.Size
+	var matches []string
+	for i := lo; i < hi; i++ {
+		hash := hex.EncodeToString(p.hashes[i*hashSize : (i+1)*hashSize])
+		if strings.HasPrefix(hash, prefix) {
+			matches = append(matches, hash)
+		}
+	}
+	return matches
+}
+
+func (p *Pack) Has(hash string) bool {
+	_, found := p.find(hash)
+	return found
+}
+
+// Read returns the type and content of hash, resolving any delta chain.
+// REF_DELTA bases must be stored in the same pack.
+func (p *Pack) Read(hash string) (string, []byte, error) {
+	return p.ReadWith(hash, nil)
+}
+
+// ReadWith is like Read but falls back to resolve for REF_DELTA bases the
+// pack does not contain.
+func (p *Pack) ReadWith(hash string, resolve Resolver) (string, []byte, error) {
+	i, found := p.find(hash)
+	if !found {
+		return "", nil, fmt.Errorf("object %s not found in %s", hash, p.path)
+	}
+	file, err := os.Open(p.path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open pack: %v", err)
+	}
+	defer file.Close()
+
+	// An object read before as a delta base may still be cached. The
+	// cache keeps using its copy, so the caller gets one of its own.
+	p.basesMutex.Lock()
+	cached, ok := p.bases[p.offsets[i]]
+	p.basesMutex.Unlock()
+	if ok {
+		objectType, err := typeName(cached.code)
+		if err != nil {
+			return "", nil, err
+		}
+		return objectType, bytes.Clone(cached.data), nil
+	}
+
+	code, data, err := p.readAt(file, p.offsets[i], resolve, 0)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s from pack: %v", hash, err)
+	}
+	objectType, err := typeName(code)
+	if err != nil {
+		return "", nil, err
+	}
+	return objectType, data, nil
+}
+
+// readAt reads the entry at offset, which is depth bases away from the
+// object being read, and applies its delta chain.
+func (p *Pack) readAt(file *os.File, offset int64, resolve Resolver, depth int) (int, []byte, error) {
+	if depth > maxReadDepth {
+		return 0, nil, fmt.Errorf("delta chain deeper than %d", maxReadDepth)
+	}
+	reader := bufio.NewReader(io.NewSectionReader(file, offset, 1<<62))
+
+	c, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	code := int(c>>4) & 0x07
+	size := int(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = reader.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= int(c&0x7f) << shift
+	}
+
+	var baseOffset int64
+	var baseHash string
+	switch code {
+	case typeOfsDelta:
+		distance, err := readOffset(reader)
+		if err != nil {
+			return 0, nil, err
+		}
+		if distance <= 0 || distance > offset {
+			return 0, nil, fmt.Errorf("invalid delta base offset")
+		}
+		baseOffset = offset - distance
+	case typeRefDelta:
+		raw := make([]byte, p.format.Size)
+		if _, err := io.ReadFull(reader, raw); err != nil {
+			return 0, nil, err
+		}
+		baseHash = hex.EncodeToString(raw)
+	}
+
+	zr, err := zlib.NewReader(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, int64(size)+1))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) != size {
+		return 0, nil, fmt.Errorf("entry size mismatch: expected %d, got %d", size, len(data))
+	}
+
+	var baseCode int
+	var base []byte
+	switch code {
+	case typeOfsDelta:
+		baseCode, base, err = p.readBase(file, baseOffset, resolve, depth+1)
+	case typeRefDelta:
+		if i, found := p.find(baseHash); found {
+			baseCode, base, err = p.readBase(file, p.offsets[i], resolve, depth+1)
+		} else if resolve != nil {
+			var baseType string
+			if baseType, base, err = resolve(baseHash); err == nil {
+				baseCode, err = typeCode(baseType)
+			}
+		} else {
+			err = fmt.Errorf("delta base %s not found", baseHash)
+		}
+	default:
+		return code, data, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	target, err := ApplyDelta(base, data)
+	if err != nil {
+		return 0, nil, err
+	}
+	return baseCode, target, nil
+}
+
+// readBase reads a delta base, keeping a bounded number of them around
+// since neighbouring deltas tend to share the same bases. The data it
+// returns is shared with the cache and must not be modified.
+func (p *Pack) readBase(file *os.File, offset int64, resolve Resolver, depth int) (int, []byte, error) {
+	p.basesMutex.Lock()
+	cached, ok := p.bases[offset]
+	p.basesMutex.Unlock()
+	if ok {
+		return cached.code, cached.data, nil
+	}
+
+	code, data, err := p.readAt(file, offset, resolve, depth)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	p.basesMutex.Lock()
+	if len(p.bases) >= maxCachedBases {
+		for evict := range p.bases {
+			delete(p.bases, evict)
+			break
+		}
+	}
+	p.bases[offset] = cachedBase{code: code, data: data}
+	p.basesMutex.Unlock()
+	return code, data, nil
+}
+
+func readOffset(reader *bufio.Reader) (int64, error) {
+	c, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	offset := int64(c & 0x7f)
+	for c&0x80 != 0 {
+		if c, err = reader.ReadByte(); err != nil {
+			return 0, err
+		}
+		offset = ((offset + 1) << 7) | int64(c&0x7f)
+	}
+	return offset, nil
+}
+
+var (
+	cacheMutex sync.Mutex
+	cache      = make(map[string]*Pack)
+)
+
+// OpenDir returns every pack in packDir. Indexes are cached between calls
+// and dropped once their files disappear.
+func OpenDir(packDir string, format objectformat.Format) ([]*Pack, error) {
+	idxPaths, err := filepath.Glob(filepath.Join(packDir, "pack-*.idx"))
+	if err != nil {
+		return nil, err
+	}
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	present := make(map[string]bool)
+	var packs []*Pack
+	for _, idxPath := range idxPaths {
+		key := format.Name + ":" + idxPath
+		present[key] = true
+		p, ok := cache[key]
+		if !ok {
+			p, err = Open(idxPath, format)
+			if err != nil {
+				return nil, err
+			}
+			cache[key] = p
+		}
+		packs = append(packs, p)
+	}
+	for key, p := range cache {
+		if filepath.Dir(p.path) == filepath.Clean(packDir) && !present[key] {
+			delete(cache, key)
+		}
+	}
+	return packs, nil
+}
+
+// Lookup searches every pack in packDir for hash. found is false when no
+// pack contains it.
+func Lookup(packDir, hash string) (objectType string, data []byte, found bool, err error) {
+	format, err := objectformat.ForHash(hash)
+	if err != nil {
+		return "", nil, false, err
+	}
+	packs, err := OpenDir(packDir, format)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	// REF_DELTA bases may live in any other pack in the directory. Each
+	// pack counts its own chain, so the hops between packs are counted
+	// here.
+	var resolve Resolver
+	hops := 0
+	resolve = func(base string) (string, []byte, error) {
+		if hops >= maxReadDepth {
+			return "", nil, fmt.Errorf("delta chain deeper than %d", maxReadDepth)
+		}
+		hops++
+		defer func() { hops-- }()
+		for _, p := range packs {
+			if p.Has(base) {
+				return p.ReadWith(base, resolve)
+			}
+		}
+		return "", nil, fmt.Errorf("delta base %s not found", base)
+	}
+
+	for _, p := range packs {
+		if p.Has(hash) {
+			objectType, data, err := p.ReadWith(hash, resolve)
+			return objectType, data, true, err
+		}
+	}
+	return "", nil, false, nil
+}
@@ -0,0 +1,247 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+// maxReservedEntries caps the room reserved for a pack's entries before
+// any of them has been read.
+const maxReservedEntries = 1 << 16
+
+// unpacked is an entry read from a pack stream. Deltas keep their
+// instructions in data until their base has been resolved.
+type unpacked struct {
+	offset     int64
+	code       int
+	data       []byte
+	baseOffset int64
+	baseHash   string
+	hash       string
+}
+
+// streamReader reads a pack one byte at a time where zlib asks for it, so
+// that no byte after an entry is consumed, and hashes everything read.
+type streamReader struct {
+	r      *bufio.Reader
+	sum    hash.Hash
+	offset int64
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.sum.Write(p[:n])
+	s.offset += int64(n)
+	return n, err
+}
+
+func (s *streamReader) ReadByte() (byte, error) {
+	c, err := s.r.ReadByte()
+	if err == nil {
+		s.sum.Write([]byte{c})
+		s.offset++
+	}
+	return c, err
+}
+
+// Unpack reads a complete pack from r, which need not have an index, and
+// returns every object in it with deltas applied and IDs computed. A thin
+// pack may use REF_DELTA bases it does not contain; those are looked up
+// through resolve, which may be nil.
+func Unpack(r io.Reader, format objectformat.Format, resolve Resolver) ([]Entry, error) {
+	s := &streamReader{r: bufio.NewReader(r), sum: format.New()}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(s, header); err != nil {
+		return nil, fmt.Errorf("failed to read pack header: %v", err)
+	}
+	if string(header[:4]) != packSignature {
+		return nil, fmt.Errorf("invalid pack signature")
+	}
+	if version := binary.BigEndian.Uint32(header[4:8]); version != packVersion {
+		return nil, fmt.Errorf("unsupported pack version %d", version)
+	}
+	count := binary.BigEndian.Uint32(header[8:12])
+
+	// The count comes from the stream, so only a bounded amount is
+	// reserved up front; a real pack grows the slices as it is read.
+	reserve := min(int(count), maxReservedEntries)
+	entries := make([]*unpacked, 0, reserve)
+	byOffset := make(map[int64]*unpacked, reserve)
+	for i := uint32(0); i < count; i++ {
+		e, err := readEntry(s, format)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pack entry %d: %v", i, err)
+		}
+		entries = append(entries, e)
+		byOffset[e.offset] = e
+	}
+
+	expected := s.sum.Sum(nil)
+	trailer := make([]byte, format.Size)
+	if _, err := io.ReadFull(s.r, trailer); err != nil {
+		return nil, fmt.Errorf("failed to read pack checksum: %v", err)
+	}
+	if !bytes.Equal(trailer, expected) {
+		return nil, fmt.Errorf("pack checksum mismatch")
+	}
+
+	if err := resolveEntries(entries, byOffset, format, resolve); err != nil {
+		return nil, err
+	}
+	result := make([]Entry, len(entries))
+	for i, e := range entries {
+		objectType, _ := typeName(e.code)
+		result[i] = Entry{Hash: e.hash, Type: objectType, Data: e.data}
+	}
+	return result, nil
+}
+
+func readEntry(s *streamReader, format objectformat.Format) (*unpacked, error) {
+	e := &unpacked{offset: s.offset}
+	c, err := s.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	e.code = int(c>>4) & 0x07
+	size := int(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = s.ReadByte(); err != nil {
+			return nil, err
+		}
+		size |= int(c&0x7f) << shift
+	}
+
+	switch e.code {
+	case typeOfsDelta:
+		c, err := s.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		distance := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = s.ReadByte(); err != nil {
+				return nil, err
+			}
+			distance = ((distance + 1) << 7) | int64(c&0x7f)
+		}
+		if distance <= 0 || distance > e.offset {
+			return nil, fmt.Errorf("invalid delta base offset")
+		}
+		e.baseOffset = e.offset - distance
+	case typeRefDelta:
+		raw := make([]byte, format.Size)
+		if _, err := io.ReadFull(s, raw); err != nil {
+			return nil, err
+		}
+		e.baseHash = hex.EncodeToString(raw)
+	default:
+		if _, err := typeName(e.code); err != nil {
+			return nil, err
+		}
+	}
+
+	zr, err := zlib.NewReader(s)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	if e.data, err = io.ReadAll(io.LimitReader(zr, int64(size)+1)); err != nil {
+		return nil, err
+	}
+	if len(e.data) != size {
+		return nil, fmt.Errorf("entry size mismatch: expected %d, got %d", size, len(e.data))
+	}
+	if e.code != typeOfsDelta && e.code != typeRefDelta {
+		e.hash = objectHash(format, e.code, e.data)
+	}
+	return e, nil
+}
+
+// resolveEntries applies deltas until every entry has an ID. Bases may
+// appear after the deltas that use them, so it repeats until nothing
+// changes and only then asks resolve for bases outside the pack.
+func resolveEntries(entries []*unpacked, byOffset map[int64]*unpacked, format objectformat.Format, resolve Resolver) error {
+	byHash := make(map[string]*unpacked, len(entries))
+	var pending []*unpacked
+	for _, e := range entries {
+		if e.hash != "" {
+			byHash[e.hash] = e
+		} else {
+			pending = append(pending, e)
+		}
+	}
+
+	external := false
+	for len(pending) > 0 {
+		var remaining []*unpacked
+		for _, e := range pending {
+			var baseCode int
+			var base []byte
+			switch {
+			case e.code == typeOfsDelta:
+				b, ok := byOffset[e.baseOffset]
+				if !ok {
+					return fmt.Errorf("delta base at offset %d not found", e.baseOffset)
+				}
+				if b.hash == "" {
+					remaining = append(remaining, e)
+					continue
+				}
+				baseCode, base = b.code, b.data
+			case byHash[e.baseHash] != nil:
+				b := byHash[e.baseHash]
+				baseCode, base = b.code, b.data
+			case external && resolve != nil:
+				baseType, data, err := resolve(e.baseHash)
+				if err != nil {
+					return fmt.Errorf("delta base %s not found: %v", e.baseHash, err)
+				}
+				if baseCode, err = typeCode(baseType); err != nil {
+					return err
+				}
+				base = data
+			default:
+				remaining = append(remaining, e)
+				continue
+			}
+
+			target, err := ApplyDelta(base, e.data)
+			if err != nil {
+				return err
+			}
+			e.code, e.data = baseCode, target
+			e.hash = objectHash(format, e.code, e.data)
+			byHash[e.hash] = e
+		}
+
+		if len(remaining) == len(pending) {
+			if external || resolve == nil {
+				return fmt.Errorf("delta base %s not found", remaining[0].baseHash)
+			}
+			external = true
+		} else {
+			external = false
+		}
+		pending = remaining
+	}
+	return nil
+}
+
+// objectHash returns the ID of an object as git computes it over its
+// header and content.
+func objectHash(format objectformat.Format, code int, data []byte) string {
+	objectType, _ := typeName(code)
+	h := format.New()
+	fmt.Fprintf(h, "%s %d\x00", objectType, len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
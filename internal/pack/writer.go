@@ -0,0 +1,303 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+const (
+	deltaWindow   = 10
+	maxDeltaDepth = 50
+)
+
+// Entry is an object to be written into a pack. Path is an optional hint:
+// blobs are sorted by it so that versions of the same file end up next to
+// each other and can be stored as deltas.
+type Entry struct {
+	Hash string
+	Type string
+	Data []byte
+	Path string
+}
+
+type record struct {
+	hash   []byte
+	offset int64
+	crc    uint32
+}
+
+type written struct {
+	entry  Entry
+	offset int64
+	depth  int
+}
+
+// Write stores entries in a new pack under packDir together with its
+// version 2 .idx file and returns the path of the .pack file. The pack and
+// index checksums use the same hash as the object IDs.
+func Write(packDir string, format objectformat.Format, entries []Entry) (string, error) {
+	if err := os.MkdirAll(packDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create pack directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(packDir, "tmp_pack_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create pack file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	records, packChecksum, err := writeEntries(tmp, format, entries)
+	if err != nil {
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", fmt.Errorf("failed to sync pack: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write pack: %v", err)
+	}
+
+	name := "pack-" + hex.EncodeToString(packChecksum)
+	packPath := filepath.Join(packDir, name+".pack")
+	if err := os.Chmod(tmp.Name(), 0444); err != nil {
+		return "", fmt.Errorf("failed to set pack permissions: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), packPath); err != nil {
+		return "", fmt.Errorf("failed to move pack into place: %v", err)
+	}
+	// Readers discover packs through their .idx, so it goes in last.
+	if err := writeIndex(filepath.Join(packDir, name+".idx"), format, records, packChecksum); err != nil {
+		os.Remove(packPath)
+		return "", err
+	}
+	return packPath, nil
+}
+
+// WriteStream writes entries to w as a complete pack without an index,
+// as used inside bundles.
+func WriteStream(w io.Writer, format objectformat.Format, entries []Entry) error {
+	_, _, err := writeEntries(w, format, entries)
+	return err
+}
+
+// writeEntries writes the pack header, entries and trailing checksum to
+// out and returns the index records and the checksum.
+func writeEntries(out io.Writer, format objectformat.Format, entries []Entry) ([]record, []byte, error) {
+	checksum := format.New()
+	writer := bufio.NewWriter(io.MultiWriter(out, checksum))
+
+	var header bytes.Buffer
+	header.WriteString(packSignature)
+	binary.Write(&header, binary.BigEndian, uint32(packVersion))
+	binary.Write(&header, binary.BigEndian, uint32(len(entries)))
+	if _, err := writer.Write(header.Bytes()); err != nil {
+		return nil, nil, fmt.Errorf("failed to write pack header: %v", err)
+	}
+	offset := int64(header.Len())
+
+	records := make([]record, 0, len(entries))
+	var window []written
+	for _, entry := range orderEntries(entries) {
+		hashBytes, err := hex.DecodeString(entry.Hash)
+		if err != nil || len(hashBytes) != format.Size {
+			return nil, nil, fmt.Errorf("invalid hash %s", entry.Hash)
+		}
+
+		raw, depth, err := encodeEntry(entry, offset, window)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := writer.Write(raw); err != nil {
+			return nil, nil, fmt.Errorf("failed to write pack entry: %v", err)
+		}
+
+		records = append(records, record{
+			hash:   hashBytes,
+			offset: offset,
+			crc:    crc32.ChecksumIEEE(raw),
+		})
+		if entry.Type == "blob" {
+			window = append(window, written{entry: entry, offset: offset, depth: depth})
+			if len(window) > deltaWindow {
+				window = window[1:]
+			}
+		}
+		offset += int64(len(raw))
+	}
+
+	if err := writer.Flush(); err != nil {
+		return nil, nil, fmt.Errorf("failed to write pack: %v", err)
+	}
+	packChecksum := checksum.Sum(nil)
+	if _, err := out.Write(packChecksum); err != nil {
+		return nil, nil, fmt.Errorf("failed to write pack checksum: %v", err)
+	}
+	return records, packChecksum, nil
+}
+
+// orderEntries keeps commits, trees and tags in the order given and moves
+// blobs to the end, grouped by path and largest first, so that the delta
+// window sees similar content together.
+func orderEntries(entries []Entry) []Entry {
+	var ordered, blobs []Entry
+	for _, entry := range entries {
+		if entry.Type == "blob" {
+			blobs = append(blobs, entry)
+		} else {
+			ordered = append(ordered, entry)
+		}
+	}
+	sort.SliceStable(blobs, func(i, j int) bool {
+		if blobs[i].Path != blobs[j].Path {
+			return blobs[i].Path < blobs[j].Path
+		}
+		return len(blobs[i].Data) > len(blobs[j].Data)
+	})
+	return append(ordered, blobs...)
+}
+
+// encodeEntry returns the raw bytes of a pack entry, trying a delta
+// against every blob in window and keeping the smallest one.
+func encodeEntry(entry Entry, offset int64, window []written) ([]byte, int, error) {
+	var best []byte
+	var base written
+	if entry.Type == "blob" {
+		for _, candidate := range window {
+			if candidate.depth >= maxDeltaDepth {
+				continue
+			}
+			delta := Delta(candidate.entry.Data, entry.Data)
+			if len(delta) < len(entry.Data)/2 && (best == nil || len(delta) < len(best)) {
+				best = delta
+				base = candidate
+			}
+		}
+	}
+
+	var raw bytes.Buffer
+	depth := 0
+	payload := entry.Data
+	if best != nil {
+		writeEntryHeader(&raw, typeOfsDelta, len(best))
+		writeOffset(&raw, offset-base.offset)
+		payload = best
+		depth = base.depth + 1
+	} else {
+		code, err := typeCode(entry.Type)
+		if err != nil {
+			return nil, 0, err
+		}
+		writeEntryHeader(&raw, code, len(entry.Data))
+	}
+
+	zw := zlib.NewWriter(&raw)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, 0, fmt.Errorf("failed to compress %s: %v", entry.Hash, err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to compress %s: %v", entry.Hash, err)
+	}
+	return raw.Bytes(), depth, nil
+}
+
+func writeEntryHeader(out *bytes.Buffer, code int, size int) {
+	b := byte(code<<4) | byte(size&0x0f)
+	size >>= 4
+	for size > 0 {
+		out.WriteByte(b | 0x80)
+		b = byte(size & 0x7f)
+		size >>= 7
+	}
+	out.WriteByte(b)
+}
+
+// writeOffset encodes the distance back to an OFS_DELTA base. Each
+// continuation byte implicitly adds one, so no value has two encodings.
+func writeOffset(out *bytes.Buffer, distance int64) {
+	var buf [10]byte
+	pos := len(buf) - 1
+	buf[pos] = byte(distance & 0x7f)
+	for distance >>= 7; distance > 0; distance >>= 7 {
+		distance--
+		pos--
+		buf[pos] = 0x80 | byte(distance&0x7f)
+	}
+	out.Write(buf[pos:])
+}
+
+func writeIndex(path string, format objectformat.Format, records []record, packChecksum []byte) error {
+	sort.Slice(records, func(i, j int) bool {
+		return bytes.Compare(records[i].hash, records[j].hash) < 0
+	})
+
+	var buf bytes.Buffer
+	buf.Write(idxSignature)
+	binary.Write(&buf, binary.BigEndian, uint32(idxVersion))
+
+	var fanout [256]uint32
+	for _, r := range records {
+		fanout[r.hash[0]]++
+	}
+	for i := 1; i < 256; i++ {
+		fanout[i] += fanout[i-1]
+	}
+	binary.Write(&buf, binary.BigEndian, fanout)
+
+	for _, r := range records {
+		buf.Write(r.hash)
+	}
+	for _, r := range records {
+		binary.Write(&buf, binary.BigEndian, r.crc)
+	}
+	var largeOffsets []uint64
+	for _, r := range records {
+		if r.offset < 0x80000000 {
+			binary.Write(&buf, binary.BigEndian, uint32(r.offset))
+			continue
+		}
+		binary.Write(&buf, binary.BigEndian, uint32(0x80000000|len(largeOffsets)))
+		largeOffsets = append(largeOffsets, uint64(r.offset))
+	}
+	for _, offset := range largeOffsets {
+		binary.Write(&buf, binary.BigEndian, offset)
+	}
+
+	buf.Write(packChecksum)
+	idxChecksum := format.New()
+	idxChecksum.Write(buf.Bytes())
+	buf.Write(idxChecksum.Sum(nil))
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "tmp_idx_")
+	if err != nil {
+		return fmt.Errorf("failed to create pack index: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write pack index: %v", err)
+	}
+	if err := tmp.Chmod(0444); err != nil {
+		return fmt.Errorf("failed to set pack index permissions: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync pack index: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write pack index: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move pack index into place: %v", err)
+	}
+	return nil
+}
@@ -2,9 +2,13 @@ package refs
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/HalilFocic/gitgo/internal/repository"
 )
 
 const (
@@ -16,6 +20,10 @@ const (
 	HeadFile = "HEAD"
 	RefsDir  = "refs"
 	HeadsDir = "refs/heads"
+	TagsDir  = "refs/tags"
+	LogsDir  = "logs"
+
+	PackedRefsFile = "packed-refs"
 )
 
 type Reference struct {
@@ -25,10 +33,48 @@ type Reference struct {
 	rootPath string
 }
 
+// CheckName reports whether name may be written as a reference taken
+// from untrusted input, such as a bundle or a fast-import stream. Like
+// git's check-ref-format it must live under refs/, and its components
+// must not be empty, start with a dot, end in .lock or contain "..",
+// control characters or any of the characters git reserves. This keeps
+// the name inside the refs directory.
+func CheckName(name string) error {
+	if !strings.HasPrefix(name, RefsDir+"/") {
+		return fmt.Errorf("invalid reference name %q: must start with %s/", name, RefsDir)
+	}
+	if strings.Contains(name, "..") || strings.Contains(name, "@{") || strings.HasSuffix(name, ".") {
+		return fmt.Errorf("invalid reference name %q", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part[0] == '.' || strings.HasSuffix(part, ".lock") {
+			return fmt.Errorf("invalid reference name %q", name)
+		}
+	}
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c) {
+			return fmt.Errorf("invalid reference name %q", name)
+		}
+	}
+	return nil
+}
+
 func ReadRef(rootPath, name string) (Reference, error) {
-	refPath := filepath.Join(rootPath, ".gitgo", name)
+	refPath := filepath.Join(repository.GitDir(rootPath), name)
 
 	content, err := os.ReadFile(refPath)
+	if os.IsNotExist(err) {
+		// Stock git moves references into packed-refs during gc.
+		packed, packedErr := readPackedRefs(repository.GitDir(rootPath))
+		if target, ok := packed[name]; ok && packedErr == nil {
+			return Reference{
+				Name:     name,
+				Type:     RefTypeCommit,
+				Target:   target,
+				rootPath: rootPath,
+			}, nil
+		}
+	}
 	if err != nil {
 		return Reference{}, fmt.Errorf("failed to read reference %s: %v", name, err)
 	}
@@ -48,12 +94,27 @@ func ReadRef(rootPath, name string) (Reference, error) {
 	return ref, nil
 }
 
+// writableGitDir returns the metadata directory references are written
+// to, or an error when it is a stock .git directory, see
+// repository.ReadOnly.
+func writableGitDir(rootPath string) (string, error) {
+	gitDir := repository.GitDir(rootPath)
+	if repository.ReadOnly(rootPath) {
+		return "", fmt.Errorf("%s is read-only", gitDir)
+	}
+	return gitDir, nil
+}
+
 func ReadHead(rootPath string) (Reference, error) {
 	return ReadRef(rootPath, HeadFile)
 }
 
 func UpdateRef(rootPath, name, target string, isSymbolic bool) error {
-	fullPath := filepath.Join(rootPath, ".gitgo", name)
+	gitDir, err := writableGitDir(rootPath)
+	if err != nil {
+		return fmt.Errorf("failed to write reference %s: %v", name, err)
+	}
+	fullPath := filepath.Join(gitDir, name)
 
 	var content string
 	if isSymbolic {
@@ -70,6 +131,54 @@ func UpdateRef(rootPath, name, target string, isSymbolic bool) error {
 	return nil
 }
 
+// CompareAndSwapRef points name at target if it currently points at
+// old. An empty old requires that name does not exist yet. The update
+// holds name.lock, as git does, so concurrent writers cannot both win.
+func CompareAndSwapRef(rootPath, name, old, target string) error {
+	gitDir, err := writableGitDir(rootPath)
+	if err != nil {
+		return fmt.Errorf("failed to write reference %s: %v", name, err)
+	}
+	fullPath := filepath.Join(gitDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directories for %s: %v", name, err)
+	}
+	lockPath := fullPath + ".lock"
+	lock, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to lock reference %s: %v", name, err)
+	}
+	defer os.Remove(lockPath)
+
+	current := ""
+	if ref, err := ReadRef(rootPath, name); err == nil {
+		if ref.Type == RefTypeSymbolic {
+			lock.Close()
+			return fmt.Errorf("reference %s is symbolic", name)
+		}
+		current = ref.Target
+	}
+	if current != old {
+		lock.Close()
+		if old == "" {
+			return fmt.Errorf("reference %s already exists", name)
+		}
+		return fmt.Errorf("reference %s is at %s, expected %s", name, current, old)
+	}
+
+	if _, err := lock.WriteString(target + "\n"); err != nil {
+		lock.Close()
+		return fmt.Errorf("failed to write reference %s: %v", name, err)
+	}
+	if err := lock.Close(); err != nil {
+		return fmt.Errorf("failed to write reference %s: %v", name, err)
+	}
+	if err := os.Rename(lockPath, fullPath); err != nil {
+		return fmt.Errorf("failed to update reference %s: %v", name, err)
+	}
+	return nil
+}
+
 func WriteHead(rootPath, target string, isSymbol bool) error {
 	return UpdateRef(rootPath, HeadFile, target, isSymbol)
 }
@@ -90,7 +199,7 @@ func CreateBranch(rootPath, name, commitHash string) error {
 }
 
 func DeleteBranch(rootPath, name string) error {
-	branchRef := filepath.Join("refs", "heads", name)
+	branchRef := HeadsDir + "/" + name
 	_, err := ReadRef(rootPath, branchRef)
 	if err != nil {
 		return fmt.Errorf("branch %s does not exist", name)
@@ -103,15 +212,19 @@ func DeleteBranch(rootPath, name string) error {
 	if head.Type == RefTypeSymbolic && head.Target == branchRef {
 		return fmt.Errorf("cannot delete current branch %s", name)
 	}
-	branchPath := filepath.Join(rootPath, ".gitgo", branchRef)
-	if err := os.Remove(branchPath); err != nil {
+	gitDir, err := writableGitDir(rootPath)
+	if err != nil {
 		return fmt.Errorf("failed to delete branch %s: %v", name, err)
 	}
-	return nil
+	err = os.Remove(filepath.Join(gitDir, filepath.FromSlash(branchRef)))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete branch %s: %v", name, err)
+	}
+	return removePackedRef(gitDir, branchRef)
 }
 
 func ListBranches(rootPath string) ([]string, error) {
-	headsDir := filepath.Join(rootPath, ".gitgo", "refs", "heads")
+	headsDir := filepath.Join(repository.GitDir(rootPath), "refs", "heads")
 
 	files, err := os.ReadDir(headsDir)
 	if err != nil {
@@ -127,3 +240,234 @@ func ListBranches(rootPath string) ([]string, error) {
 
 	return branches, nil
 }
+
+func CreateTag(rootPath, name, hash string) error {
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("tag cannot contain slashes")
+	}
+	if name == "" {
+		return fmt.Errorf("tag name cannot be empty")
+	}
+
+	tagRef := TagsDir + "/" + name
+	if _, err := ReadRef(rootPath, tagRef); err == nil {
+		return fmt.Errorf("tag %s already exists", name)
+	}
+	return UpdateRef(rootPath, tagRef, hash, false)
+}
+
+// DeleteTag removes a tag, whether it is stored loose or in packed-refs.
+func DeleteTag(rootPath, name string) error {
+	tagRef := TagsDir + "/" + name
+	if _, err := ReadRef(rootPath, tagRef); err != nil {
+		return fmt.Errorf("tag %s does not exist", name)
+	}
+	gitDir, err := writableGitDir(rootPath)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag %s: %v", name, err)
+	}
+	err = os.Remove(filepath.Join(gitDir, filepath.FromSlash(tagRef)))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete tag %s: %v", name, err)
+	}
+	return removePackedRef(gitDir, tagRef)
+}
+
+// ListTags returns the names of all tags, without the refs/tags/ prefix,
+// in lexical order.
+func ListTags(rootPath string) ([]string, error) {
+	names, err := ListRefs(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, name := range names {
+		if tag, found := strings.CutPrefix(name, TagsDir+"/"); found {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// ListRefs returns the names of every reference under refs/, such as
+// "refs/heads/main", in lexical order.
+func ListRefs(rootPath string) ([]string, error) {
+	gitDir := repository.GitDir(rootPath)
+	var names []string
+	err := filepath.WalkDir(filepath.Join(gitDir, RefsDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(gitDir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(name))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list references: %v", err)
+	}
+
+	packed, err := readPackedRefs(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	loose := make(map[string]bool)
+	for _, name := range names {
+		loose[name] = true
+	}
+	for name := range packed {
+		if !loose[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readPackedRefs parses the packed-refs file stock git writes, mapping
+// reference names to their targets. Peeled "^" lines are skipped.
+func readPackedRefs(gitDir string) (map[string]string, error) {
+	refs := make(map[string]string)
+	content, err := os.ReadFile(filepath.Join(gitDir, PackedRefsFile))
+	if os.IsNotExist(err) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read packed references: %v", err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		target, name, found := strings.Cut(line, " ")
+		if !found {
+			return nil, fmt.Errorf("invalid packed reference line %q", line)
+		}
+		refs[name] = target
+	}
+	return refs, nil
+}
+
+// removePackedRef drops name and its peeled line from packed-refs,
+// leaving the file untouched when name is not in it.
+func removePackedRef(gitDir, name string) error {
+	path := filepath.Join(gitDir, PackedRefsFile)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read packed references: %v", err)
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	var kept []string
+	removed := false
+	for i := 0; i < len(lines); i++ {
+		if _, refName, _ := strings.Cut(strings.TrimSuffix(lines[i], "\n"), " "); refName == name {
+			removed = true
+			if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "^") {
+				i++
+			}
+			continue
+		}
+		kept = append(kept, lines[i])
+	}
+	if !removed {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(kept, "")), 0644); err != nil {
+		return fmt.Errorf("failed to write packed references: %v", err)
+	}
+	return nil
+}
+
+// ReflogHashes returns every object ID recorded in the reflogs under
+// logs/, old and new values alike. Repositories without reflogs return
+// nothing.
+func ReflogHashes(rootPath string) ([]string, error) {
+	var hashes []string
+	err := walkReflogs(rootPath, func(path string, content []byte) error {
+		// Each line is "<old> <new> <committer> <timestamp> <tz>\t<message>".
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			for _, hash := range fields[:2] {
+				if strings.Trim(hash, "0") != "" {
+					hashes = append(hashes, hash)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reflogs: %v", err)
+	}
+	return hashes, nil
+}
+
+// RewriteReflogs replaces the old and new object IDs of every reflog
+// entry found in ids with the ID they map to. The rest of each line is
+// kept as it is.
+func RewriteReflogs(rootPath string, ids map[string]string) error {
+	if _, err := writableGitDir(rootPath); err != nil {
+		return fmt.Errorf("failed to rewrite reflogs: %v", err)
+	}
+	err := walkReflogs(rootPath, func(path string, content []byte) error {
+		lines := strings.SplitAfter(string(content), "\n")
+		changed := false
+		for i, line := range lines {
+			oldID, rest, found := strings.Cut(line, " ")
+			if !found {
+				continue
+			}
+			newID, rest, found := strings.Cut(rest, " ")
+			if !found {
+				continue
+			}
+			if id, ok := ids[oldID]; ok {
+				oldID, changed = id, true
+			}
+			if id, ok := ids[newID]; ok {
+				newID, changed = id, true
+			}
+			lines[i] = oldID + " " + newID + " " + rest
+		}
+		if !changed {
+			return nil
+		}
+		return os.WriteFile(path, []byte(strings.Join(lines, "")), 0644)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rewrite reflogs: %v", err)
+	}
+	return nil
+}
+
+// walkReflogs calls fn with the path and content of every file under
+// logs/.
+func walkReflogs(rootPath string, fn func(path string, content []byte) error) error {
+	logsPath := filepath.Join(repository.GitDir(rootPath), LogsDir)
+	return filepath.WalkDir(logsPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == logsPath {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return fn(path, content)
+	})
+}
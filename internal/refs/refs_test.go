@@ -194,4 +194,166 @@ func TestReferences(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("2.5: Reference names from untrusted input", func(t *testing.T) {
+		for _, name := range []string{"refs/heads/main", "refs/tags/v1.0", "refs/remotes/origin/feature-x"} {
+			if err := CheckName(name); err != nil {
+				t.Errorf("CheckName(%q) = %v; want nil", name, err)
+			}
+		}
+		for _, name := range []string{
+			"", "HEAD", "config", "main", "../../hooks/post-checkout", "refs/../config",
+			"refs/heads/../../config", "refs/heads/", "refs//main", "refs/heads/.hidden",
+			"refs/heads/main.lock", "refs/heads/a b", "refs/heads/a:b", "refs/heads/a\\b",
+			"refs/heads/a@{1}", "refs/heads/main.", "refs/heads/a\x00",
+		} {
+			if err := CheckName(name); err == nil {
+				t.Errorf("Expected error for reference name %q", name)
+			}
+		}
+	})
+}
+
+func TestPackedRefsAndReflogs(t *testing.T) {
+	cwd, _ := os.Getwd()
+	testDir := filepath.Join(cwd, "testdata")
+
+	t.Run("3.1: Packed references", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(filepath.Join(testDir, ".gitgo", "refs", "heads"), 0755)
+		defer os.RemoveAll(testDir)
+
+		loose := "1234567890123456789012345678901234567890"
+		packed := "abcdefabcdefabcdefabcdefabcdefabcdefabcd"
+		os.WriteFile(filepath.Join(testDir, ".gitgo", "refs", "heads", "main"), []byte(loose), 0644)
+		content := "# pack-refs with: peeled fully-peeled sorted\n" +
+			packed + " refs/heads/main\n" +
+			packed + " refs/tags/v1\n" +
+			"^" + loose + "\n"
+		os.WriteFile(filepath.Join(testDir, ".gitgo", "packed-refs"), []byte(content), 0644)
+
+		names, err := ListRefs(testDir)
+		if err != nil {
+			t.Fatalf("Failed to list refs: %v", err)
+		}
+		if len(names) != 2 || names[0] != "refs/heads/main" || names[1] != "refs/tags/v1" {
+			t.Errorf("ListRefs = %v; want [refs/heads/main refs/tags/v1]", names)
+		}
+
+		ref, err := ReadRef(testDir, "refs/heads/main")
+		if err != nil || ref.Target != loose {
+			t.Errorf("Loose ref should win over packed-refs: %+v, %v", ref, err)
+		}
+		ref, err = ReadRef(testDir, "refs/tags/v1")
+		if err != nil || ref.Target != packed {
+			t.Errorf("Packed ref = %+v, %v; want %s", ref, err, packed)
+		}
+	})
+
+	t.Run("3.2: Reflog hashes", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(filepath.Join(testDir, ".gitgo", "refs", "heads"), 0755)
+		defer os.RemoveAll(testDir)
+
+		hashes, err := ReflogHashes(testDir)
+		if err != nil || len(hashes) != 0 {
+			t.Fatalf("Expected no reflog hashes, got %v, %v", hashes, err)
+		}
+
+		zero := "0000000000000000000000000000000000000000"
+		first := "1234567890123456789012345678901234567890"
+		second := "abcdefabcdefabcdefabcdefabcdefabcdefabcd"
+		logPath := filepath.Join(testDir, ".gitgo", "logs", "refs", "heads", "main")
+		os.MkdirAll(filepath.Dir(logPath), 0755)
+		content := zero + " " + first + " A <a@example.com> 1700000000 +0000\tcommit (initial): one\n" +
+			first + " " + second + " A <a@example.com> 1700000100 +0000\tcommit: two\n"
+		os.WriteFile(logPath, []byte(content), 0644)
+
+		hashes, err = ReflogHashes(testDir)
+		if err != nil {
+			t.Fatalf("Failed to read reflogs: %v", err)
+		}
+		want := []string{first, first, second}
+		if len(hashes) != len(want) {
+			t.Fatalf("ReflogHashes = %v; want %v", hashes, want)
+		}
+		for i := range want {
+			if hashes[i] != want[i] {
+				t.Errorf("ReflogHashes = %v; want %v", hashes, want)
+				break
+			}
+		}
+	})
+
+	t.Run("3.3: Create, list and delete tags", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(filepath.Join(testDir, ".gitgo", "refs", "heads"), 0755)
+		defer os.RemoveAll(testDir)
+		os.WriteFile(filepath.Join(testDir, ".gitgo", "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+
+		hash := "1234567890123456789012345678901234567890"
+		packed := "abcdefabcdefabcdefabcdefabcdefabcdefabcd"
+		content := packed + " refs/heads/main\n" +
+			packed + " refs/tags/v0\n" +
+			"^" + hash + "\n"
+		os.WriteFile(filepath.Join(testDir, ".gitgo", "packed-refs"), []byte(content), 0644)
+
+		if err := CreateTag(testDir, "v1", hash); err != nil {
+			t.Fatalf("Failed to create tag: %v", err)
+		}
+		if err := CreateTag(testDir, "v1", hash); err == nil {
+			t.Error("Expected error creating an existing tag")
+		}
+		if err := CreateTag(testDir, "release/v1", hash); err == nil {
+			t.Error("Expected error for tag with slashes")
+		}
+		tags, err := ListTags(testDir)
+		if err != nil || len(tags) != 2 || tags[0] != "v0" || tags[1] != "v1" {
+			t.Errorf("ListTags = %v, %v; want [v0 v1]", tags, err)
+		}
+
+		for _, name := range []string{"v0", "v1"} {
+			if err := DeleteTag(testDir, name); err != nil {
+				t.Errorf("Failed to delete tag %s: %v", name, err)
+			}
+		}
+		if tags, _ := ListTags(testDir); len(tags) != 0 {
+			t.Errorf("Tags left after delete: %v", tags)
+		}
+		if err := DeleteTag(testDir, "v1"); err == nil {
+			t.Error("Expected error deleting a missing tag")
+		}
+		packedContent, _ := os.ReadFile(filepath.Join(testDir, ".gitgo", "packed-refs"))
+		if string(packedContent) != packed+" refs/heads/main\n" {
+			t.Errorf("packed-refs = %q; want only refs/heads/main", packedContent)
+		}
+	})
+
+	t.Run("3.4: Delete packed branches", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(filepath.Join(testDir, ".gitgo", "refs", "heads"), 0755)
+		defer os.RemoveAll(testDir)
+		os.WriteFile(filepath.Join(testDir, ".gitgo", "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+
+		loose := "1234567890123456789012345678901234567890"
+		packed := "abcdefabcdefabcdefabcdefabcdefabcdefabcd"
+		content := packed + " refs/heads/both\n" +
+			packed + " refs/heads/main\n" +
+			packed + " refs/heads/packed\n"
+		os.WriteFile(filepath.Join(testDir, ".gitgo", "packed-refs"), []byte(content), 0644)
+		os.WriteFile(filepath.Join(testDir, ".gitgo", "refs", "heads", "both"), []byte(loose+"\n"), 0644)
+
+		for _, name := range []string{"packed", "both"} {
+			if err := DeleteBranch(testDir, name); err != nil {
+				t.Errorf("Failed to delete branch %s: %v", name, err)
+			}
+			if ref, err := ReadRef(testDir, HeadsDir+"/"+name); err == nil {
+				t.Errorf("Branch %s still resolves to %s after delete", name, ref.Target)
+			}
+		}
+		packedContent, _ := os.ReadFile(filepath.Join(testDir, ".gitgo", "packed-refs"))
+		if string(packedContent) != packed+" refs/heads/main\n" {
+			t.Errorf("packed-refs = %q; want only refs/heads/main", packedContent)
+		}
+	})
 }
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/HalilFocic/gitgo/internal/config"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+// Encrypted repositories record their cipher as a repository extension so
+// that stock git refuses them instead of misreading the objects.
+const (
+	EncryptionConfigKey = "extensions.gitgoEncryption"
+	EncryptionAlgorithm = "aes-256-gcm"
+
+	// EncryptionKeyFileKey names a file holding the key as hex. Relative
+	// paths are taken from the metadata directory.
+	EncryptionKeyFileKey = "gitgo.encryptionKeyFile"
+	// EncryptionKeyCheckKey stores a value derived from the key so a wrong
+	// key is reported as such rather than as corrupt objects.
+	EncryptionKeyCheckKey = "gitgo.encryptionKeyCheck"
+
+	// EncryptionKeyEnv holds the key as hex and EncryptionKeyFileEnv the
+	// path of a key file. Both take precedence over the config.
+	EncryptionKeyEnv     = "GITGO_ENCRYPTION_KEY"
+	EncryptionKeyFileEnv = "GITGO_ENCRYPTION_KEY_FILE"
+)
+
+// InitEncrypted creates a repository whose loose objects are encrypted.
+// The key is taken from the environment when keyFile is empty. Otherwise
+// it is read from keyFile, which is created with a new random key when it
+// does not exist yet, and the file is recorded in the config.
+func InitEncrypted(path string, format objectformat.Format, keyFile string) (*Repository, error) {
+	var key []byte
+	var err error
+	if keyFile == "" {
+		key, err = keyFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return nil, fmt.Errorf("encryption needs a key file or %s", EncryptionKeyEnv)
+		}
+	} else {
+		if keyFile, err = filepath.Abs(keyFile); err != nil {
+			return nil, err
+		}
+		if key, err = createKeyFile(keyFile); err != nil {
+			return nil, err
+		}
+	}
+	c, err := object.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := InitWithObjectFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.Load(repo.GitgoDir)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Set("core.repositoryformatversion", "1")
+	cfg.Set(EncryptionConfigKey, EncryptionAlgorithm)
+	cfg.Set(EncryptionKeyCheckKey, c.KeyCheck())
+	if keyFile != "" {
+		cfg.Set(EncryptionKeyFileKey, keyFile)
+	}
+	if err := cfg.Save(repo.GitgoDir); err != nil {
+		os.RemoveAll(repo.GitgoDir)
+		return nil, err
+	}
+
+	objects, err := openObjects(repo.GitgoDir, format)
+	if err != nil {
+		return nil, err
+	}
+	repo.Objects = objects
+	repo.Encrypted = true
+	return repo, nil
+}
+
+// createKeyFile reads the key in path, writing a new one first when the
+// file does not exist.
+func createKeyFile(path string) ([]byte, error) {
+	if _, err := os.Stat(path); err == nil {
+		return readKeyFile(path)
+	}
+	key, err := object.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("%x\n", key)), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write key file: %v", err)
+	}
+	return key, nil
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	key, err := object.ParseKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", path, err)
+	}
+	return key, nil
+}
+
+// keyFromEnv returns the key given in the environment, or nil when none
+// is set.
+func keyFromEnv() ([]byte, error) {
+	if value := os.Getenv(EncryptionKeyEnv); value != "" {
+		key, err := object.ParseKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", EncryptionKeyEnv, err)
+		}
+		return key, nil
+	}
+	if path := os.Getenv(EncryptionKeyFileEnv); path != "" {
+		return readKeyFile(path)
+	}
+	return nil, nil
+}
+
+// objectCipher returns the cipher for an encrypted repository and nil for
+// a plain one. The key must match the check value recorded at init.
+func objectCipher(gitDir string, cfg *config.Config) (*object.Cipher, error) {
+	switch algorithm := cfg.Get(EncryptionConfigKey); algorithm {
+	case "":
+		return nil, nil
+	case EncryptionAlgorithm:
+	default:
+		return nil, fmt.Errorf("unsupported object encryption %q", algorithm)
+	}
+
+	key, err := keyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		path := cfg.Get(EncryptionKeyFileKey)
+		if path == "" {
+			return nil, fmt.Errorf("repository objects are encrypted; set %s or %s",
+				EncryptionKeyEnv, EncryptionKeyFileEnv)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(gitDir, path)
+		}
+		if key, err = readKeyFile(path); err != nil {
+			return nil, err
+		}
+	}
+	c, err := object.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if check := cfg.Get(EncryptionKeyCheckKey); check != "" && check != c.KeyCheck() {
+		return nil, fmt.Errorf("encryption key does not match this repository")
+	}
+	return c, nil
+}
+
+// isEncrypted reports whether store, or the store a cache reads through
+// to, seals its objects.
+func isEncrypted(store object.ObjectStore) bool {
+	if cached, ok := store.(*object.CachedStore); ok {
+		store = cached.Unwrap()
+	}
+	files, ok := store.(*object.FileStore)
+	return ok && files.Encrypted()
+}
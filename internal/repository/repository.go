@@ -1,16 +1,37 @@
 package repository
+
 import (
-    "fmt"
-    "os"
-    "path/filepath"
-    "github.com/HalilFocic/gitgo/internal/config"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/HalilFocic/gitgo/internal/config"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
 )
+
+// ObjectCacheLimitKey sets how many bytes of decoded objects a repository
+// handle keeps in memory. Sizes take a k, m or g suffix and 0 disables
+// the cache.
+const ObjectCacheLimitKey = "core.objectCacheLimit"
+
 type Repository struct {
-	Path     string
-	GitgoDir string
+	Path         string
+	GitgoDir     string
+	ObjectFormat objectformat.Format
+	Objects      object.ObjectStore
+	// Encrypted is set when loose objects are sealed with a key, see
+	// InitEncrypted. Such objects must not be written to packs.
+	Encrypted bool
 }
 
 func Init(path string) (*Repository, error) {
+	return InitWithObjectFormat(path, objectformat.SHA1)
+}
+
+// InitWithObjectFormat creates a repository whose object IDs use format.
+// The choice is recorded in the repository config.
+func InitWithObjectFormat(path string, format objectformat.Format) (*Repository, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return nil, err
@@ -55,6 +76,20 @@ func Init(path string) (*Repository, error) {
 
 	indexFile.Close()
 	refMainFile.Close()
+
+	cfg := &config.Config{}
+	if format == objectformat.SHA1 {
+		cfg.Set("core.repositoryformatversion", "0")
+	} else {
+		// Git refuses to read extensions unless the format version is 1.
+		cfg.Set("core.repositoryformatversion", "1")
+		cfg.Set(objectformat.ConfigKey, format.Name)
+	}
+	if err := cfg.Save(gitGoPath); err != nil {
+		os.RemoveAll(gitGoPath)
+		return nil, err
+	}
+
 	headPath := filepath.Join(gitGoPath, "HEAD")
 	err = os.WriteFile(headPath, []byte("ref: refs/heads/main\n"), 0644)
 	if err != nil {
@@ -62,16 +97,105 @@ func Init(path string) (*Repository, error) {
 		return nil, fmt.Errorf("failed to create HEAD file: %v", err)
 	}
 	return &Repository{
-		Path:     absPath,
-		GitgoDir: gitGoPath,
+		Path:         absPath,
+		GitgoDir:     gitGoPath,
+		ObjectFormat: format,
+		Objects:      object.NewCachedStore(object.NewFileStore(objectsPath, format), object.DefaultCacheLimit),
 	}, nil
 }
+
+// Open returns the repository rooted at path. Both gitgo's own .gitgo
+// directory and a stock .git directory are accepted, with .gitgo taking
+// precedence when both exist.
+func Open(path string) (*Repository, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range config.GitDirNames {
+		gitDir := filepath.Join(absPath, name)
+		if isGitDir(gitDir) {
+			format, err := objectformat.ForGitDir(gitDir)
+			if err != nil {
+				return nil, err
+			}
+			objects, err := openObjects(gitDir, format)
+			if err != nil {
+				return nil, err
+			}
+			return &Repository{
+				Path:         absPath,
+				GitgoDir:     gitDir,
+				ObjectFormat: format,
+				Objects:      objects,
+				Encrypted:    isEncrypted(objects),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("not a gitgo repository: %s", absPath)
+}
+
+// openObjects returns the object store of gitDir, wrapped in a cache
+// sized by core.objectCacheLimit. Encrypted repositories fail to open
+// without their key.
+func openObjects(gitDir string, format objectformat.Format) (object.ObjectStore, error) {
+	cfg, err := config.Load(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	c, err := objectCipher(gitDir, cfg)
+	if err != nil {
+		return nil, err
+	}
+	var store object.ObjectStore = object.NewFileStore(filepath.Join(gitDir, "objects"), format)
+	if c != nil {
+		store = object.NewEncryptedFileStore(filepath.Join(gitDir, "objects"), format, c)
+	}
+	limit := int64(object.DefaultCacheLimit)
+	if value := cfg.Get(ObjectCacheLimitKey); value != "" {
+		if limit, err = config.ParseSize(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", ObjectCacheLimitKey, err)
+		}
+	}
+	if limit == 0 {
+		return store, nil
+	}
+	return object.NewCachedStore(store, limit), nil
+}
+
+// GitDir returns the metadata directory for the working tree at rootPath.
+// When neither .gitgo nor .git exists the .gitgo path is returned.
+func GitDir(rootPath string) string {
+	for _, name := range config.GitDirNames {
+		gitDir := filepath.Join(rootPath, name)
+		if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
+			return gitDir
+		}
+	}
+	return filepath.Join(rootPath, config.GitDirName)
+}
+
+// ReadOnly reports whether the repository at rootPath keeps its metadata
+// in a stock .git directory. gitgo reads such repositories but does not
+// write their index or references, which it cannot store faithfully.
+func ReadOnly(rootPath string) bool {
+	return filepath.Base(GitDir(rootPath)) == config.StockGitDirName
+}
+
 func IsRepository(path string) bool {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return false
 	}
-    gitGoPath := filepath.Join(absPath,config.GitDirName)
+	for _, name := range config.GitDirNames {
+		if isGitDir(filepath.Join(absPath, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isGitDir(gitDir string) bool {
 	dirs := []string{
 		".",
 		"./objects",
@@ -79,7 +203,7 @@ func IsRepository(path string) bool {
 		"./refs/heads",
 	}
 	for _, d := range dirs {
-		p := filepath.Join(gitGoPath, d)
+		p := filepath.Join(gitDir, d)
 		file, err := os.Stat(p)
 		if file == nil || err != nil {
 			return false
@@ -95,4 +219,3 @@ func (r *Repository) ObjectPath() string {
 func (r *Repository) RefsPath() string {
 	return filepath.Join(r.GitgoDir, "refs")
 }
-
@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"github.com/HalilFocic/gitgo/internal/config"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
 )
 
 func TestInitRepository(t *testing.T) {
@@ -137,3 +141,203 @@ func TestIsRepository(t *testing.T) {
 		}
 	})
 }
+
+func TestOpenRepository(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current working directory: %v", err)
+	}
+	testDir := filepath.Join(cwd, "testdata")
+
+	t.Run("4.1: Open stock git directory", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		defer os.RemoveAll(testDir)
+		for _, dir := range []string{"objects", "refs/heads"} {
+			os.MkdirAll(filepath.Join(testDir, config.StockGitDirName, dir), 0755)
+		}
+
+		repo, err := Open(testDir)
+		if err != nil {
+			t.Fatalf("Failed to open .git repository: %v", err)
+		}
+		want := filepath.Join(testDir, config.StockGitDirName)
+		if repo.GitgoDir != want {
+			t.Errorf("GitgoDir = %s; want %s", repo.GitgoDir, want)
+		}
+		if repo.ObjectPath() != filepath.Join(want, "objects") {
+			t.Errorf("ObjectPath() = %s; want it inside %s", repo.ObjectPath(), want)
+		}
+		if GitDir(testDir) != want {
+			t.Errorf("GitDir() = %s; want %s", GitDir(testDir), want)
+		}
+		if !IsRepository(testDir) {
+			t.Error("IsRepository() = false, want true for .git repository")
+		}
+	})
+
+	t.Run("4.2: Prefer .gitgo over .git", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		defer os.RemoveAll(testDir)
+		for _, dir := range []string{"objects", "refs/heads"} {
+			os.MkdirAll(filepath.Join(testDir, config.StockGitDirName, dir), 0755)
+		}
+		if _, err := Init(testDir); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+
+		repo, err := Open(testDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		if repo.GitgoDir != filepath.Join(testDir, config.GitDirName) {
+			t.Errorf("GitgoDir = %s; want the .gitgo directory", repo.GitgoDir)
+		}
+	})
+
+	t.Run("4.3: Open without repository", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		os.MkdirAll(testDir, 0755)
+		defer os.RemoveAll(testDir)
+
+		if _, err := Open(testDir); err == nil {
+			t.Error("Expected error opening a directory without a repository")
+		}
+	})
+}
+
+func TestObjectFormat(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current working directory: %v", err)
+	}
+	testDir := filepath.Join(cwd, "testdata")
+
+	t.Run("5.1: Default repository uses SHA-1", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		defer os.RemoveAll(testDir)
+		if _, err := Init(testDir); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		repo, err := Open(testDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		if repo.ObjectFormat != objectformat.SHA1 {
+			t.Errorf("ObjectFormat = %s; want sha1", repo.ObjectFormat.Name)
+		}
+	})
+
+	t.Run("5.2: SHA-256 repository", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		defer os.RemoveAll(testDir)
+		if _, err := InitWithObjectFormat(testDir, objectformat.SHA256); err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		repo, err := Open(testDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		if repo.ObjectFormat != objectformat.SHA256 {
+			t.Errorf("ObjectFormat = %s; want sha256", repo.ObjectFormat.Name)
+		}
+		cfg, _ := config.Load(repo.GitgoDir)
+		if cfg.Get(objectformat.ConfigKey) != "sha256" {
+			t.Error("Object format not recorded in repository config")
+		}
+	})
+}
+
+func TestObjectCache(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current working directory: %v", err)
+	}
+	testDir := filepath.Join(cwd, "testdata")
+
+	t.Run("6.1: Cache limit from config", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		defer os.RemoveAll(testDir)
+		repo, err := Init(testDir)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		if _, ok := repo.Objects.(*object.CachedStore); !ok {
+			t.Errorf("Objects = %T; want a cached store", repo.Objects)
+		}
+
+		cfg, _ := config.Load(repo.GitgoDir)
+		cfg.Set(ObjectCacheLimitKey, "0")
+		cfg.Save(repo.GitgoDir)
+		repo, err = Open(testDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		if _, ok := repo.Objects.(*object.FileStore); !ok {
+			t.Errorf("Objects = %T; want the file store with the cache disabled", repo.Objects)
+		}
+
+		cfg.Set(ObjectCacheLimitKey, "lots")
+		cfg.Save(repo.GitgoDir)
+		if _, err := Open(testDir); err == nil {
+			t.Error("Expected error for invalid cache limit")
+		}
+	})
+}
+
+func TestEncryptedRepository(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current working directory: %v", err)
+	}
+	testDir := filepath.Join(cwd, "testdata")
+	keyFile := filepath.Join(cwd, "testdata-key")
+
+	t.Run("7.1: Key from key file and environment", func(t *testing.T) {
+		os.RemoveAll(testDir)
+		os.Remove(keyFile)
+		defer os.RemoveAll(testDir)
+		defer os.Remove(keyFile)
+
+		repo, err := InitEncrypted(testDir, objectformat.SHA1, keyFile)
+		if err != nil {
+			t.Fatalf("Failed to initialize repository: %v", err)
+		}
+		if !repo.Encrypted {
+			t.Error("Repository is not marked encrypted")
+		}
+		if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0600 {
+			t.Fatalf("Key file was not created private: %v", err)
+		}
+		hash, err := repo.Objects.Put(object.TypeBlob, []byte("secret"))
+		if err != nil {
+			t.Fatalf("Failed to write object: %v", err)
+		}
+
+		repo, err = Open(testDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		if obj, err := repo.Objects.Get(hash); err != nil || string(obj.Data) != "secret" {
+			t.Errorf("Failed to read object: %v", err)
+		}
+
+		wrong, _ := object.GenerateKey()
+		t.Setenv(EncryptionKeyEnv, fmt.Sprintf("%x", wrong))
+		if _, err := Open(testDir); err == nil || !strings.Contains(err.Error(), "does not match") {
+			t.Errorf("Expected wrong key error, got %v", err)
+		}
+		key, _ := os.ReadFile(keyFile)
+		t.Setenv(EncryptionKeyEnv, string(key))
+		if _, err := Open(testDir); err != nil {
+			t.Errorf("Failed to open with key from environment: %v", err)
+		}
+
+		t.Setenv(EncryptionKeyEnv, "")
+		cfg, _ := config.Load(repo.GitgoDir)
+		cfg.Set(EncryptionKeyFileKey, "")
+		cfg.Save(repo.GitgoDir)
+		if _, err := Open(testDir); err == nil {
+			t.Error("Opened encrypted repository without a key")
+		}
+	})
+}
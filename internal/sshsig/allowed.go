@@ -0,0 +1,176 @@
+package sshsig
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Signer is one line of an allowed signers file: the principals, usually
+// email addresses, that key may sign as.
+type Signer struct {
+	Principals []string
+	Namespaces []string
+	// ValidAfter and ValidBefore limit when signatures by Key are
+	// trusted. Either is zero when the line does not limit it.
+	ValidAfter  time.Time
+	ValidBefore time.Time
+	Key         ed25519.PublicKey
+}
+
+// AllowedSigners is the trust list "ssh-keygen -Y verify -f" and git's
+// gpg.ssh.allowedSignersFile use.
+type AllowedSigners []Signer
+
+// ReadAllowedSigners reads an allowed signers file.
+func ReadAllowedSigners(path string) (AllowedSigners, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseAllowedSigners(file)
+}
+
+// ParseAllowedSigners parses lines of the form
+// "principal[,principal...] [options] ssh-ed25519 <base64> [comment]".
+// The namespaces, valid-after and valid-before options are honoured.
+// Lines with any other option, such as cert-authority, are skipped rather
+// than trusted more widely than they say, and so are lines for other key
+// types since they could never verify.
+func ParseAllowedSigners(r io.Reader) (AllowedSigners, error) {
+	var signers AllowedSigners
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		principals, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+
+		var options string
+		if !strings.HasPrefix(rest, "ssh-") && !strings.HasPrefix(rest, "ecdsa-") && !strings.HasPrefix(rest, "sk-") {
+			options, rest = cutOptions(rest)
+		}
+		keyType, _, _ := strings.Cut(rest, " ")
+		if keyType != KeyType {
+			continue
+		}
+		key, err := ParsePublicKey(rest)
+		if err != nil {
+			return nil, fmt.Errorf("allowed signers line %d: %v", lineNo, err)
+		}
+		signer := Signer{Principals: strings.Split(principals, ","), Key: key}
+		understood := true
+		for _, option := range splitOptions(options) {
+			name, value, _ := strings.Cut(option, "=")
+			value = strings.Trim(value, `"`)
+			switch strings.ToLower(name) {
+			case "namespaces":
+				signer.Namespaces = strings.Split(value, ",")
+			case "valid-after":
+				signer.ValidAfter, err = parseValidity(value)
+			case "valid-before":
+				signer.ValidBefore, err = parseValidity(value)
+			default:
+				understood = false
+			}
+			if err != nil {
+				return nil, fmt.Errorf("allowed signers line %d: %v", lineNo, err)
+			}
+		}
+		if understood {
+			signers = append(signers, signer)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return signers, nil
+}
+
+// Principal returns the first principal allowed to sign in namespace
+// with key at the time signed, and false when the key is not trusted.
+func (a AllowedSigners) Principal(key ed25519.PublicKey, namespace string, signed time.Time) (string, bool) {
+	for _, signer := range a {
+		if !bytes.Equal(signer.Key, key) {
+			continue
+		}
+		if signer.Namespaces != nil && !slices.Contains(signer.Namespaces, namespace) {
+			continue
+		}
+		if !signer.ValidAfter.IsZero() && signed.Before(signer.ValidAfter) {
+			continue
+		}
+		if !signer.ValidBefore.IsZero() && signed.After(signer.ValidBefore) {
+			continue
+		}
+		return signer.Principals[0], true
+	}
+	return "", false
+}
+
+// parseValidity reads a valid-after or valid-before time, which is
+// YYYYMMDD, YYYYMMDDHHMM or YYYYMMDDHHMMSS in local time, or in UTC with
+// a trailing Z.
+func parseValidity(value string) (time.Time, error) {
+	location := time.Local
+	if rest, ok := strings.CutSuffix(value, "Z"); ok {
+		value, location = rest, time.UTC
+	}
+	layouts := map[int]string{8: "20060102", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[len(value)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+	t, err := time.ParseInLocation(layout, value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+	return t, nil
+}
+
+// cutOptions splits the options field, which ends at the first space
+// outside double quotes, from the rest of the line.
+func cutOptions(s string) (string, string) {
+	quoted := false
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ' ' && !quoted:
+			return s[:i], strings.TrimSpace(s[i:])
+		}
+	}
+	return s, ""
+}
+
+// splitOptions splits comma separated options, keeping commas inside
+// double quotes.
+func splitOptions(s string) []string {
+	var options []string
+	quoted := false
+	start := 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			options = append(options, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		options = append(options, s[start:])
+	}
+	return options
+}
@@ -0,0 +1,166 @@
+package sshsig
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// KeyType is the only SSH key type supported, both for signing and for
+// allowed signers.
+const KeyType = "ssh-ed25519"
+
+const (
+	privateKeyBlock = "OPENSSH PRIVATE KEY"
+	privateKeyMagic = "openssh-key-v1\x00"
+)
+
+// ParsePrivateKey decodes an unencrypted ed25519 key in the OpenSSH
+// format ssh-keygen writes by default.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != privateKeyBlock {
+		return nil, fmt.Errorf("not an OpenSSH private key")
+	}
+	rest, ok := bytes.CutPrefix(block.Bytes, []byte(privateKeyMagic))
+	if !ok {
+		return nil, fmt.Errorf("not an OpenSSH private key")
+	}
+
+	r := &reader{buf: rest}
+	cipher := r.string()
+	kdf := r.string()
+	r.string() // KDF options
+	count := r.uint32()
+	r.string() // public key
+	private := &reader{buf: r.bytes()}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid OpenSSH private key: %v", r.err)
+	}
+	if cipher != "none" || kdf != "none" {
+		return nil, fmt.Errorf("encrypted private keys are not supported, decrypt it with ssh-keygen -p")
+	}
+	if count != 1 {
+		return nil, fmt.Errorf("expected one key in the private key file, found %d", count)
+	}
+
+	// Two equal check words catch a wrong passphrase in encrypted keys.
+	if private.uint32() != private.uint32() {
+		return nil, fmt.Errorf("invalid OpenSSH private key: check words differ")
+	}
+	keyType := private.string()
+	if private.err == nil && keyType != KeyType {
+		return nil, fmt.Errorf("unsupported key type %s, only %s keys can sign", keyType, KeyType)
+	}
+	public := private.bytes()
+	key := private.bytes()
+	if private.err != nil {
+		return nil, fmt.Errorf("invalid OpenSSH private key: %v", private.err)
+	}
+	if len(key) != ed25519.PrivateKeySize || !bytes.Equal(key[32:], public) {
+		return nil, fmt.Errorf("invalid ed25519 private key")
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// ParsePublicKey decodes an authorized_keys style line,
+// "ssh-ed25519 <base64> [comment]", or just its key type and base64.
+func ParsePublicKey(line string) (ed25519.PublicKey, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid public key %q", line)
+	}
+	if fields[0] != KeyType {
+		return nil, fmt.Errorf("unsupported key type %s", fields[0])
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	return parsePublicBlob(blob)
+}
+
+// parsePublicBlob decodes a public key in the SSH wire format.
+func parsePublicBlob(blob []byte) (ed25519.PublicKey, error) {
+	r := &reader{buf: blob}
+	keyType := r.string()
+	key := r.bytes()
+	if r.err != nil || len(r.buf) != 0 {
+		return nil, fmt.Errorf("invalid public key")
+	}
+	if keyType != KeyType {
+		return nil, fmt.Errorf("unsupported key type %s", keyType)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key length %d", len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// MarshalPublicKey returns key in the SSH wire format.
+func MarshalPublicKey(key ed25519.PublicKey) []byte {
+	var buf bytes.Buffer
+	writeString(&buf, []byte(KeyType))
+	writeString(&buf, key)
+	return buf.Bytes()
+}
+
+// FormatPublicKey returns key as an authorized_keys style line without
+// a comment.
+func FormatPublicKey(key ed25519.PublicKey) string {
+	return KeyType + " " + base64.StdEncoding.EncodeToString(MarshalPublicKey(key))
+}
+
+// Fingerprint returns the SHA256 fingerprint ssh-keygen -l prints.
+func Fingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(MarshalPublicKey(key))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// reader decodes the length-prefixed strings and integers of the SSH
+// wire format. The first error sticks and later reads return zero values.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 4 {
+		r.err = fmt.Errorf("unexpected end of data")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+func (r *reader) bytes() []byte {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+	if uint32(len(r.buf)) < n {
+		r.err = fmt.Errorf("unexpected end of data")
+		return nil
+	}
+	v := r.buf[:n]
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+func writeString(buf *bytes.Buffer, s []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.Write(s)
+}
@@ -0,0 +1,136 @@
+// Package sshsig signs and verifies data in the SSHSIG format that
+// "ssh-keygen -Y sign" produces and git stores in the gpgsig header of
+// SSH-signed commits. Only ed25519 keys are supported.
+package sshsig
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const (
+	magic         = "SSHSIG"
+	version       = 1
+	hashAlgorithm = "sha512"
+
+	BeginArmor = "-----BEGIN SSH SIGNATURE-----"
+	EndArmor   = "-----END SSH SIGNATURE-----"
+
+	armorWidth = 70
+)
+
+// Sign signs message for namespace and returns the armored signature.
+func Sign(key ed25519.PrivateKey, namespace string, message []byte) string {
+	public := key.Public().(ed25519.PublicKey)
+	signature := ed25519.Sign(key, signedData(namespace, message))
+
+	var sig bytes.Buffer
+	writeString(&sig, []byte(KeyType))
+	writeString(&sig, signature)
+
+	var blob bytes.Buffer
+	blob.WriteString(magic)
+	blob.Write([]byte{0, 0, 0, version})
+	writeString(&blob, MarshalPublicKey(public))
+	writeString(&blob, []byte(namespace))
+	writeString(&blob, nil) // reserved
+	writeString(&blob, []byte(hashAlgorithm))
+	writeString(&blob, sig.Bytes())
+	return armor(blob.Bytes())
+}
+
+// Verify checks an armored signature of message made for namespace and
+// returns the key that made it. Whether that key is trusted is up to the
+// caller, see AllowedSigners.
+func Verify(armored, namespace string, message []byte) (ed25519.PublicKey, error) {
+	blob, err := unarmor(armored)
+	if err != nil {
+		return nil, err
+	}
+	rest, ok := bytes.CutPrefix(blob, []byte(magic))
+	if !ok {
+		return nil, fmt.Errorf("not an SSH signature")
+	}
+	r := &reader{buf: rest}
+	if v := r.uint32(); r.err == nil && v != version {
+		return nil, fmt.Errorf("unsupported signature version %d", v)
+	}
+	publicBlob := r.bytes()
+	signedNamespace := r.string()
+	r.bytes() // reserved
+	hash := r.string()
+	sig := &reader{buf: r.bytes()}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", r.err)
+	}
+
+	public, err := parsePublicBlob(publicBlob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", err)
+	}
+	if signedNamespace != namespace {
+		return nil, fmt.Errorf("signature is for namespace %q, not %q", signedNamespace, namespace)
+	}
+	if hash != hashAlgorithm {
+		return nil, fmt.Errorf("unsupported signature hash %s", hash)
+	}
+	sigType := sig.string()
+	signature := sig.bytes()
+	if sig.err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", sig.err)
+	}
+	if sigType != KeyType {
+		return nil, fmt.Errorf("unsupported signature type %s", sigType)
+	}
+	if !ed25519.Verify(public, signedData(namespace, message), signature) {
+		return nil, fmt.Errorf("signature does not match")
+	}
+	return public, nil
+}
+
+// signedData is what the key actually signs: the namespace and a hash
+// of the message, so that a signature cannot be replayed for another
+// purpose.
+func signedData(namespace string, message []byte) []byte {
+	sum := sha512.Sum512(message)
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	writeString(&buf, []byte(namespace))
+	writeString(&buf, nil) // reserved
+	writeString(&buf, []byte(hashAlgorithm))
+	writeString(&buf, sum[:])
+	return buf.Bytes()
+}
+
+func armor(blob []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var b strings.Builder
+	b.WriteString(BeginArmor + "\n")
+	for len(encoded) > armorWidth {
+		b.WriteString(encoded[:armorWidth] + "\n")
+		encoded = encoded[armorWidth:]
+	}
+	b.WriteString(encoded + "\n")
+	b.WriteString(EndArmor + "\n")
+	return b.String()
+}
+
+func unarmor(armored string) ([]byte, error) {
+	body, ok := strings.CutPrefix(strings.TrimSpace(armored), BeginArmor)
+	if !ok {
+		return nil, fmt.Errorf("missing %s", BeginArmor)
+	}
+	body, ok = strings.CutSuffix(body, EndArmor)
+	if !ok {
+		return nil, fmt.Errorf("missing %s", EndArmor)
+	}
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	return blob, nil
+}
@@ -2,12 +2,13 @@ package staging
 
 import (
 	"bufio"
-	"crypto/sha1"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/HalilFocic/gitgo/internal/blob"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
 	"github.com/HalilFocic/gitgo/internal/repository"
 	"io"
 	"os"
@@ -28,6 +29,8 @@ type Entry struct {
 type Index struct {
 	entries map[string]*Entry
 	root    string
+	format  objectformat.Format
+	objects object.ObjectStore
 }
 
 func New(root string) (*Index, error) {
@@ -41,10 +44,16 @@ func New(root string) (*Index, error) {
 	if !repository.IsRepository(absPath) {
 		return nil, errors.New("not a gitgo repository")
 	}
+	repo, err := repository.Open(absPath)
+	if err != nil {
+		return nil, err
+	}
 	entries := make(map[string]*Entry)
 	idx := &Index{
 		root:    root,
 		entries: entries,
+		format:  repo.ObjectFormat,
+		objects: repo.Objects,
 	}
 	err = idx.Read()
 	if err != nil {
@@ -55,7 +64,6 @@ func New(root string) (*Index, error) {
 
 func (idx *Index) Add(path string) error {
 	absInputPath := filepath.Join(idx.root, filepath.Clean(path))
-	objectsPath := filepath.Join(idx.root, ".gitgo/objects")
 	relPath, err := filepath.Rel(idx.root, absInputPath)
 	if err != nil {
 		return fmt.Errorf("failed to get relative path: %v", err)
@@ -72,22 +80,18 @@ func (idx *Index) Add(path string) error {
 	if fileStat.Mode()&os.ModeSymlink != 0 {
 		return fmt.Errorf("symlinks are not supported")
 	}
-	content, err := os.ReadFile(absInputPath)
+	file, err := os.Open(absInputPath)
 	if err != nil {
 		return err
 	}
-	b, err := blob.New(content)
-	if err != nil {
-		return err
-	}
-
-	err = b.Store(objectsPath)
+	defer file.Close()
+	hash, err := blob.StoreFrom(idx.objects, file, fileStat.Size())
 	if err != nil {
 		return err
 	}
 	entry := Entry{
 		Path:     relPath,
-		Hash:     b.Hash(),
+		Hash:     hash,
 		Mode:     fileStat.Mode(),
 		Size:     fileStat.Size(),
 		Modified: fileStat.ModTime(),
@@ -150,20 +154,23 @@ type IndexEntry struct {
 	Ino       uint32
 	Mode      uint32
 	Size      uint32
-	Hash      [20]byte
+	Hash      []byte // object format sized, 20 bytes for SHA-1
 	Flags     uint16
 	Path      []byte
 }
 
 func (idx *Index) Write() error {
-	indexPath := filepath.Join(idx.root, ".gitgo", "index")
+	if repository.ReadOnly(idx.root) {
+		return fmt.Errorf("failed to write index: %s is read-only", repository.GitDir(idx.root))
+	}
+	indexPath := filepath.Join(repository.GitDir(idx.root), "index")
 	file, err := os.Create(indexPath)
 	if err != nil {
 		return fmt.Errorf("Failed to create index file: %v", err)
 	}
 	defer file.Close()
 
-	hash := sha1.New()
+	hash := idx.format.New()
 	writer := bufio.NewWriter(io.MultiWriter(file, hash))
 
 	header := IndexHeader{
@@ -211,7 +218,10 @@ func (idx *Index) Write() error {
 			return fmt.Errorf("failed to decode hash %v", err)
 		}
 
-		copy(indexEntry.Hash[:], hashBytes)
+		if len(hashBytes) != idx.format.Size {
+			return fmt.Errorf("hash %s is not a %s hash", entry.Hash, idx.format.Name)
+		}
+		indexEntry.Hash = hashBytes
 
 		if err := binary.Write(writer, binary.BigEndian, indexEntry.Ctimesec); err != nil {
 			return fmt.Errorf("failed to write ctime sec: %v", err)
@@ -251,7 +261,7 @@ func (idx *Index) Write() error {
 			return fmt.Errorf("failed to write path terminator: %v", err)
 		}
 
-		padding := 8 - ((62 + len(indexEntry.Path) + 1) % 8)
+		padding := 8 - ((idx.entryHeaderSize() + len(indexEntry.Path) + 1) % 8)
 		if padding < 8 {
 			zeros := make([]byte, padding)
 			if _, err := writer.Write(zeros); err != nil {
@@ -271,7 +281,7 @@ func (idx *Index) Write() error {
 }
 
 func (idx *Index) Read() error {
-	indexPath := filepath.Join(idx.root, ".gitgo", "index")
+	indexPath := filepath.Join(repository.GitDir(idx.root), "index")
 	stat, err := os.Stat(indexPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -306,10 +316,11 @@ func (idx *Index) Read() error {
 	if err := binary.Read(reader, binary.BigEndian, &header.numEntries); err != nil {
 		return fmt.Errorf("failed to read num entries %v", err)
 	}
-	idx.Clear()
+	// Clear would rewrite the file we are still reading from.
+	idx.entries = make(map[string]*Entry)
 
 	for i := uint32(0); i < header.numEntries; i++ {
-		indexEntry := IndexEntry{}
+		indexEntry := IndexEntry{Hash: make([]byte, idx.format.Size)}
 
 		if err := binary.Read(reader, binary.BigEndian, &indexEntry.Ctimesec); err != nil {
 			return fmt.Errorf("failed to read ctimesec %v", err)
@@ -348,7 +359,7 @@ func (idx *Index) Read() error {
 		}
 		path = path[:len(path)-1]
 
-		padding := 8 - ((62 + len(path) + 1) % 8)
+		padding := 8 - ((idx.entryHeaderSize() + len(path) + 1) % 8)
 		if padding < 8 {
 			if _, err := reader.Discard(padding); err != nil {
 				return fmt.Errorf("failed to skip padding %v", err)
@@ -367,6 +378,12 @@ func (idx *Index) Read() error {
 	return nil
 }
 
+// entryHeaderSize is the size of an on-disk entry before its path: ten
+// 32-bit fields, the object hash and the 16-bit flags.
+func (idx *Index) entryHeaderSize() int {
+	return 40 + idx.format.Size + 2
+}
+
 func (idx *Index) Clear() {
 	idx.entries = make(map[string]*Entry)
 	err := idx.Write()
@@ -0,0 +1,168 @@
+package tag
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/HalilFocic/gitgo/internal/commit"
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+// Tag is an annotated tag: a named, signed-off pointer to another object
+// with its own message.
+type Tag struct {
+	Object     string
+	ObjectType string
+	Name       string
+	Tagger     string
+	TaggerDate time.Time
+	// ExtraHeaders holds every header after the tagger, and known headers
+	// out of their usual place, in the order they are written.
+	ExtraHeaders []commit.Header
+	// Message is everything after the blank line ending the headers,
+	// byte for byte. Signed tags carry their signature at its end.
+	Message string
+	// noSeparator is set for tags read without the blank line after the
+	// headers, so that writing them back leaves it out too.
+	noSeparator bool
+	// taggerRaw keeps TaggerDate as it was read.
+	taggerRaw commit.RawDate
+}
+
+func New(objectHash, objectType, name, tagger, message string) (*Tag, error) {
+	format, err := objectformat.ForHash(objectHash)
+	if err != nil || !format.IsValid(objectHash) {
+		return nil, fmt.Errorf("invalid object hash %q", objectHash)
+	}
+	switch objectType {
+	case object.TypeBlob, object.TypeTree, object.TypeCommit, object.TypeTag:
+	default:
+		return nil, fmt.Errorf("unknown object type %q", objectType)
+	}
+	if name == "" || strings.ContainsAny(name, " \n") {
+		return nil, fmt.Errorf("invalid tag name %q", name)
+	}
+	if len(message) == 0 {
+		return nil, fmt.Errorf("tag message cannot be empty")
+	}
+	taggerRegex := regexp.MustCompile(`^([^<]+)\s+<([^>]+)>$`)
+	if !taggerRegex.MatchString(tagger) {
+		return nil, fmt.Errorf("invalid tagger format, must be 'Name <email>'")
+	}
+	return &Tag{
+		Object:     objectHash,
+		ObjectType: objectType,
+		Name:       name,
+		Tagger:     tagger,
+		TaggerDate: time.Now(),
+		Message:    message,
+	}, nil
+}
+
+func (t *Tag) Write(store object.ObjectStore) (string, error) {
+	format := store.Format()
+	if !format.IsValid(t.Object) {
+		return "", fmt.Errorf("object hash %s is not a %s hash", t.Object, format.Name)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "object %s\ntype %s\ntag %s\n", t.Object, t.ObjectType, t.Name)
+	// Very old tags, and tags imported from them, have no tagger.
+	if t.Tagger != "" {
+		fmt.Fprintf(&b, "tagger %s\n", t.taggerRaw.FormatIdent(t.Tagger, t.TaggerDate))
+	}
+	for _, h := range t.ExtraHeaders {
+		b.WriteString(h.Name + " " + strings.ReplaceAll(h.Value, "\n", "\n ") + "\n")
+	}
+	if !t.noSeparator || t.Message != "" {
+		b.WriteString("\n" + t.Message)
+	}
+	return store.Put(object.TypeTag, []byte(b.String()))
+}
+
+func Read(store object.ObjectStore, hash string) (*Tag, error) {
+	obj, err := store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != object.TypeTag {
+		return nil, fmt.Errorf("not a tag object")
+	}
+	return Parse(obj.Data)
+}
+
+// Parse decodes the body of a tag object, without its header. Like
+// commit.Parse it keeps headers it does not interpret and the message as
+// they are, so that writing the tag back gives the same object ID.
+func Parse(content []byte) (*Tag, error) {
+	t := &Tag{}
+	headers, message, found := bytes.Cut(content, []byte("\n\n"))
+	if !found {
+		// A tag with neither a message nor the blank line before it.
+		if headers, found = bytes.CutSuffix(content, []byte{'\n'}); !found {
+			return nil, fmt.Errorf("tag headers must end with a newline")
+		}
+		t.noSeparator = true
+	}
+	t.Message = string(message)
+
+	const (
+		start = iota
+		afterObject
+		afterType
+		afterName
+		inExtra
+	)
+	position := start
+	var extra *commit.Header
+	for _, line := range strings.Split(string(headers), "\n") {
+		if rest, ok := strings.CutPrefix(line, " "); ok {
+			if extra == nil {
+				return nil, fmt.Errorf("continuation line outside of a header")
+			}
+			extra.Value += "\n" + rest
+			continue
+		}
+
+		name, value, found := strings.Cut(line, " ")
+		if !found {
+			return nil, fmt.Errorf("invalid line format")
+		}
+		switch {
+		case name == "object" && position == start:
+			t.Object = value
+			position = afterObject
+		case name == "type" && position == afterObject:
+			t.ObjectType = value
+			position = afterType
+		case name == "tag" && position == afterType:
+			t.Name = value
+			position = afterName
+		case name == "tagger" && position == afterName:
+			var err error
+			if t.Tagger, t.taggerRaw, err = commit.ParseRawIdent(value); err != nil {
+				return nil, fmt.Errorf("invalid tagger line: %v", err)
+			}
+			t.TaggerDate = t.taggerRaw.Date()
+			position = inExtra
+		default:
+			if name == "tagger" {
+				if _, _, err := commit.ParseIdent(value); err != nil {
+					return nil, fmt.Errorf("invalid tagger line: %v", err)
+				}
+			}
+			t.ExtraHeaders = append(t.ExtraHeaders, commit.Header{Name: name, Value: value})
+			extra = &t.ExtraHeaders[len(t.ExtraHeaders)-1]
+			position = inExtra
+		}
+	}
+	// Write always starts with these three, so a tag without them in
+	// that order could not be written back unchanged.
+	if t.Object == "" || t.ObjectType == "" || t.Name == "" {
+		return nil, fmt.Errorf("tag must start with its object, type and name")
+	}
+	return t, nil
+}
@@ -0,0 +1,148 @@
+package tag
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
+)
+
+func TestTagCreation(t *testing.T) {
+	commitHash := "1234567890123456789012345678901234567890"
+	tagger := "John Doe <john@example.com>"
+
+	t.Run("1.1: Valid tag creation", func(t *testing.T) {
+		tg, err := New(commitHash, object.TypeCommit, "v1.0", tagger, "Release 1.0\n")
+		if err != nil {
+			t.Fatalf("Failed to create valid tag: %v", err)
+		}
+		if tg.Object != commitHash || tg.ObjectType != object.TypeCommit || tg.Name != "v1.0" {
+			t.Errorf("Unexpected tag %+v", tg)
+		}
+		if tg.TaggerDate.IsZero() {
+			t.Error("TaggerDate should not be zero")
+		}
+	})
+
+	t.Run("1.2: Invalid input", func(t *testing.T) {
+		cases := []struct {
+			hash, objectType, name, tagger, message string
+			desc                                    string
+		}{
+			{"123", object.TypeCommit, "v1", tagger, "msg", "short hash"},
+			{commitHash, "banana", "v1", tagger, "msg", "unknown type"},
+			{commitHash, object.TypeCommit, "", tagger, "msg", "empty name"},
+			{commitHash, object.TypeCommit, "v 1", tagger, "msg", "space in name"},
+			{commitHash, object.TypeCommit, "v1", "John Doe", "msg", "bad tagger"},
+			{commitHash, object.TypeCommit, "v1", tagger, "", "empty message"},
+		}
+		for _, tc := range cases {
+			if _, err := New(tc.hash, tc.objectType, tc.name, tc.tagger, tc.message); err == nil {
+				t.Errorf("Expected error for %s", tc.desc)
+			}
+		}
+	})
+}
+
+func TestTagStorage(t *testing.T) {
+	t.Run("2.1: Write and read", func(t *testing.T) {
+		store := object.NewMemoryStore(objectformat.SHA1)
+		commitHash := object.Hash(objectformat.SHA1, object.TypeCommit, []byte("commit"))
+		tg, err := New(commitHash, object.TypeCommit, "v1.0", "John Doe <john@example.com>", "Release 1.0\n")
+		if err != nil {
+			t.Fatalf("Failed to create tag: %v", err)
+		}
+		tg.TaggerDate = time.Unix(1700000000, 0).In(time.FixedZone("", 3600))
+
+		hash, err := tg.Write(store)
+		if err != nil {
+			t.Fatalf("Failed to write tag: %v", err)
+		}
+		read, err := Read(store, hash)
+		if err != nil {
+			t.Fatalf("Failed to read tag: %v", err)
+		}
+		if read.Object != tg.Object || read.ObjectType != tg.ObjectType || read.Name != tg.Name ||
+			read.Tagger != tg.Tagger || read.Message != tg.Message || !read.TaggerDate.Equal(tg.TaggerDate) {
+			t.Errorf("Read %+v; want %+v", read, tg)
+		}
+		if _, offset := read.TaggerDate.Zone(); offset != 3600 {
+			t.Errorf("Timezone offset = %d; want 3600", offset)
+		}
+	})
+
+	t.Run("2.2: Stock git tag", func(t *testing.T) {
+		// Written by git tag -a v1 -m "first release".
+		content := "object 8d2ad3e5a4e8f8d3b0b0a44a2d9d1cfe27b4b7a1\n" +
+			"type commit\n" +
+			"tag v1\n" +
+			"tagger A U Thor <author@example.com> 1112912053 -0700\n" +
+			"\n" +
+			"first release\n"
+		tg, err := Parse([]byte(content))
+		if err != nil {
+			t.Fatalf("Failed to parse tag: %v", err)
+		}
+		if tg.Name != "v1" || tg.Tagger != "A U Thor <author@example.com>" || tg.Message != "first release\n" {
+			t.Errorf("Unexpected tag %+v", tg)
+		}
+		if tg.TaggerDate.Unix() != 1112912053 {
+			t.Errorf("TaggerDate = %v", tg.TaggerDate)
+		}
+
+		store := object.NewMemoryStore(objectformat.SHA1)
+		hash, _ := tg.Write(store)
+		if want := object.Hash(objectformat.SHA1, object.TypeTag, []byte(content)); hash != want {
+			t.Errorf("Rewritten tag hash %s; want %s", hash, want)
+		}
+		blobHash, _ := store.Put(object.TypeBlob, []byte("not a tag"))
+		if _, err := Read(store, blobHash); err == nil {
+			t.Error("Expected error reading a non-tag object")
+		}
+	})
+
+	t.Run("2.3: Unknown headers and exact messages round trip", func(t *testing.T) {
+		store := object.NewMemoryStore(objectformat.SHA1)
+		head := "object 8d2ad3e5a4e8f8d3b0b0a44a2d9d1cfe27b4b7a1\ntype commit\ntag v1\n"
+		for _, data := range []string{
+			head + "tagger A  U Thor <author@example.com> 1112912053 -0000\n" +
+				"x-custom one\n two\n \n" +
+				"\n  release\n\n-----BEGIN SSH SIGNATURE-----\nU1NIU0lH\n-----END SSH SIGNATURE-----\n",
+			head + "x-before-tagger 1\ntagger A <a@example.com> 1 +0000\n\nno final newline",
+			head + "tagger A <a@example.com> 1 +0000\n",
+			head + "tagger A <a@example.com> 0123 +0000\n\nleading zero\n",
+			head + "tagger A <a@example.com> +123 +0099\n\nsigned timestamp and odd zone\n",
+			head + "\n",
+			head,
+		} {
+			hash, _ := store.Put(object.TypeTag, []byte(data))
+			tg, err := Read(store, hash)
+			if err != nil {
+				t.Fatalf("Failed to read tag %q: %v", data, err)
+			}
+			again, err := tg.Write(store)
+			if err != nil || again != hash {
+				rewritten, _ := store.Get(again)
+				t.Errorf("Rewritten tag = %q, %v; want %q", rewritten.Data, err, data)
+			}
+		}
+
+		tg, _ := Parse([]byte(head + "tagger A <a@example.com> 1 +0000\nx-custom a\n b\n\nmessage"))
+		if len(tg.ExtraHeaders) != 1 || tg.ExtraHeaders[0].Value != "a\nb" || tg.Message != "message" {
+			t.Errorf("ExtraHeaders = %q, message %q", tg.ExtraHeaders, tg.Message)
+		}
+		for _, data := range []string{
+			"type commit\nobject 8d2ad3e5a4e8f8d3b0b0a44a2d9d1cfe27b4b7a1\ntag v1\n\nswapped",
+			"object 8d2ad3e5a4e8f8d3b0b0a44a2d9d1cfe27b4b7a1\ntype commit\n\nno name",
+			" orphan\n" + head + "\nmessage",
+			head + "tagger A <a@example.com> 1 0100\n\nbad zone",
+			strings.TrimSuffix(head, "\n"),
+		} {
+			if _, err := Parse([]byte(data)); err == nil {
+				t.Errorf("Expected error for tag %q", data)
+			}
+		}
+	})
+}
@@ -2,23 +2,23 @@ package tree
 
 import (
 	"bytes"
-	"compress/zlib"
-	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
-	"io"
-	"os"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"unicode/utf8"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
 )
 
 const (
 	RegularFileMode = 0100644 // Regular file
 	ExecutableMode  = 0100755 // Executable file
 	DirectoryMode   = 0040000 // Directory
+	SymlinkMode     = 0120000 // Symbolic link, the blob holds the target
+	GitlinkMode     = 0160000 // Submodule commit
 )
 
 type TreeEntry struct {
@@ -34,7 +34,16 @@ func (te TreeEntries) Len() int {
 	return len(te)
 }
 func (te TreeEntries) Swap(i, j int)      { te[i], te[j] = te[j], te[i] }
-func (te TreeEntries) Less(i, j int) bool { return te[i].Name < te[j].Name }
+func (te TreeEntries) Less(i, j int) bool { return sortKey(te[i]) < sortKey(te[j]) }
+
+// Git orders directories as if their name ended in '/', so "lib" sorts
+// after "lib.go" but before "lib0". Tree hashes depend on this order.
+func sortKey(entry TreeEntry) string {
+	if entry.Mode == DirectoryMode {
+		return entry.Name + "/"
+	}
+	return entry.Name
+}
 
 type Tree struct {
 	entries []TreeEntry
@@ -47,35 +56,54 @@ func New() *Tree {
 }
 
 func (tree *Tree) AddEntry(name, hash string, filemode int) error {
+	if err := tree.checkEntry(name, hash, filemode); err != nil {
+		return err
+	}
+	for _, tEntry := range tree.entries {
+		if tEntry.Name == name {
+			return fmt.Errorf("Name %s already exists inside this tree", tEntry.Name)
+		}
+	}
+	tree.entries = append(tree.entries, TreeEntry{
+		Name: name,
+		Hash: hash,
+		Mode: filemode,
+	})
+	sort.Sort(TreeEntries(tree.entries))
+	return nil
+}
+
+// checkEntry validates an entry about to be added to tree, apart from
+// its name being unique.
+func (tree *Tree) checkEntry(name, hash string, filemode int) error {
 	if len(name) == 0 {
 		return fmt.Errorf("entry name cannot be empty")
 	}
 	if len(hash) == 0 {
 		return fmt.Errorf("entry hash cannot be empty")
 	}
-	if len(hash) != 40 {
-		return fmt.Errorf("invalid hash length: expected 40 characters, got %d", len(hash))
+	format, err := objectformat.ForHash(hash)
+	if err != nil {
+		return fmt.Errorf("invalid hash length: expected %d or %d characters, got %d",
+			objectformat.SHA1.HexSize(), objectformat.SHA256.HexSize(), len(hash))
+	}
+	if len(tree.entries) > 0 && len(tree.entries[0].Hash) != len(hash) {
+		return fmt.Errorf("hash %s uses a different object format than the other entries", hash)
+	}
+	if !format.IsValid(hash) {
+		return fmt.Errorf("hash must contain only lowercase hex characters")
 	}
 	if !utf8.ValidString(name) {
 		return fmt.Errorf("invalid UTF-8 in filename")
 	}
-	if filemode != RegularFileMode && filemode != ExecutableMode && filemode != DirectoryMode {
+	switch filemode {
+	case RegularFileMode, ExecutableMode, DirectoryMode, SymlinkMode, GitlinkMode:
+	default:
 		return fmt.Errorf("Invalid file mode.")
 	}
 	if strings.Contains(name, "/") {
 		return fmt.Errorf("Entry name cannot contain '/', this should be handled by seperate tree")
 	}
-	for _, tEntry := range tree.entries {
-		if tEntry.Name == name {
-			return fmt.Errorf("Name %s already exists inside this tree", tEntry.Name)
-		}
-	}
-	tree.entries = append(tree.entries, TreeEntry{
-		Name: name,
-		Hash: hash,
-		Mode: filemode,
-	})
-	sort.Sort(TreeEntries(tree.entries))
 	return nil
 }
 
@@ -83,16 +111,10 @@ func (tree *Tree) Entries() []TreeEntry {
 	return tree.entries
 }
 
-func (tree *Tree) Write(objectsPath string) (string, error) {
+func (tree *Tree) Write(store object.ObjectStore) (string, error) {
+	format := store.Format()
 	var buffer bytes.Buffer
 
-	contentSize := 0
-	for _, entry := range tree.entries {
-		contentSize += len(strconv.FormatInt(int64(entry.Mode), 8)) + 1 + len(entry.Name) + 1 + 20
-	}
-
-	fmt.Fprintf(&buffer, "tree %d\x00", contentSize)
-
 	for _, entry := range tree.entries {
 		fmt.Fprintf(&buffer, "%o ", entry.Mode)
 
@@ -103,60 +125,40 @@ func (tree *Tree) Write(objectsPath string) (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("invalid hash %s: %v", entry.Hash, err)
 		}
+		if len(hashBytes) != format.Size {
+			return "", fmt.Errorf("entry %s is not a %s hash", entry.Name, format.Name)
+		}
 		buffer.Write(hashBytes)
 	}
 
-	var compressed bytes.Buffer
-	zWriter := zlib.NewWriter(&compressed)
-	if _, err := zWriter.Write(buffer.Bytes()); err != nil {
-		return "", fmt.Errorf("compression failed: %v", err)
-	}
-	zWriter.Close()
-
-	hash := sha1.Sum(compressed.Bytes())
-	hashString := hex.EncodeToString(hash[:])
-
-	hashPath := filepath.Join(objectsPath, hashString[:2], hashString[2:])
-	if err := os.MkdirAll(filepath.Dir(hashPath), 0755); err != nil {
-		return "", fmt.Errorf("failed to create object directory: %v", err)
-	}
-	if err := os.WriteFile(hashPath, compressed.Bytes(), 0644); err != nil {
-		return "", fmt.Errorf("failed to write object file: %v", err)
-	}
-
-	return hashString, nil
-}
-func Read(objectsPath, hash string) (*Tree, error) {
-	hashPath := filepath.Join(objectsPath, hash[:2], hash[2:])
-	compressed, err := os.ReadFile(hashPath)
+	hash, err := store.Put(object.TypeTree, buffer.Bytes())
 	if err != nil {
-		return nil, fmt.Errorf("failed to read object file: %v", err)
+		return "", err
 	}
-
-	zReader, err := zlib.NewReader(bytes.NewReader(compressed))
+	return hash, nil
+}
+func Read(store object.ObjectStore, hash string) (*Tree, error) {
+	obj, err := store.Get(hash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create zlib reader: %v", err)
+		return nil, err
 	}
-	defer zReader.Close()
-
-	var buffer bytes.Buffer
-
-	if _, err := io.Copy(&buffer, zReader); err != nil {
-		return nil, fmt.Errorf("failed to decompress data: %v", err)
+	if obj.Type != object.TypeTree {
+		return nil, fmt.Errorf("not a tree object")
 	}
-
-	data := buffer.Bytes()
-	header := bytes.SplitN(data, []byte{0}, 2)
-	if len(header) != 2 {
-		return nil, fmt.Errorf("invalid object format")
+	format, err := objectformat.ForHash(hash)
+	if err != nil {
+		return nil, err
 	}
+	return Parse(obj.Data, format)
+}
 
-	headerParts := bytes.Fields(header[0])
-	if len(headerParts) != 2 || string(headerParts[0]) != "tree" {
-		return nil, fmt.Errorf("not a tree object")
-	}
+// Parse decodes the body of a tree object, without its header. Entry
+// hashes are format.Size bytes long. Entries are checked like AddEntry
+// checks them, but in one pass: trees are stored sorted, so they are only
+// sorted again when they are not.
+func Parse(content []byte, format objectformat.Format) (*Tree, error) {
 	tree := New()
-	content := header[1]
+	names := make(map[string]bool)
 	for len(content) > 0 {
 
 		spaceIndex := bytes.IndexByte(content, ' ')
@@ -165,7 +167,9 @@ func Read(objectsPath, hash string) (*Tree, error) {
 		}
 
 		nullIdx := bytes.IndexByte(content[spaceIndex+1:], 0)
-
+		if nullIdx == -1 {
+			return nil, fmt.Errorf("invalid entry format")
+		}
 		nullIdx += spaceIndex + 1
 
 		mode, err := strconv.ParseInt(string(content[:spaceIndex]), 8, 32)
@@ -175,16 +179,25 @@ func Read(objectsPath, hash string) (*Tree, error) {
 
 		name := string(content[spaceIndex+1 : nullIdx])
 
-		if len(content[nullIdx+1:]) < 20 {
+		hashEnd := nullIdx + 1 + format.Size
+		if len(content) < hashEnd {
 			return nil, fmt.Errorf("truncated entry")
 		}
 
-		hash := hex.EncodeToString(content[nullIdx+1 : nullIdx+21])
+		hash := hex.EncodeToString(content[nullIdx+1 : hashEnd])
 
-		if err := tree.AddEntry(name, hash, int(mode)); err != nil {
+		if err := tree.checkEntry(name, hash, int(mode)); err != nil {
 			return nil, fmt.Errorf("failed to add entry: %v", err)
 		}
-		content = content[nullIdx+21:]
+		if names[name] {
+			return nil, fmt.Errorf("failed to add entry: Name %s already exists inside this tree", name)
+		}
+		names[name] = true
+		tree.entries = append(tree.entries, TreeEntry{Name: name, Hash: hash, Mode: int(mode)})
+		content = content[hashEnd:]
+	}
+	if !sort.IsSorted(TreeEntries(tree.entries)) {
+		sort.Sort(TreeEntries(tree.entries))
 	}
 	return tree, nil
 }
@@ -1,9 +1,15 @@
 package tree
 
 import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/HalilFocic/gitgo/internal/object"
+	"github.com/HalilFocic/gitgo/internal/objectformat"
 )
 
 func TestTreeOperations(t *testing.T) {
@@ -71,14 +77,15 @@ func TestTreeOperations(t *testing.T) {
 		testDir := filepath.Join(cwd, "testdata")
 		os.RemoveAll(testDir)
 		os.MkdirAll(filepath.Join(testDir, ".gitgo", "objects"), 0755)
+		objects := object.NewFileStore(filepath.Join(testDir, ".gitgo", "objects"), objectformat.SHA1)
 		defer os.RemoveAll(testDir)
 
-		hash, err := tree.Write(filepath.Join(testDir, ".gitgo", "objects"))
+		hash, err := tree.Write(objects)
 		if err != nil {
 			t.Fatalf("Failed to write tree: %v", err)
 		}
 
-		readTree, err := Read(filepath.Join(testDir, ".gitgo", "objects"), hash)
+		readTree, err := Read(objects, hash)
 		if err != nil {
 			t.Fatalf("Failed to read tree: %v", err)
 		}
@@ -133,14 +140,15 @@ func TestTreeEdgeCases(t *testing.T) {
 		cwd, _ := os.Getwd()
 		testDir := filepath.Join(cwd, "testdata")
 		os.MkdirAll(filepath.Join(testDir, ".gitgo", "objects"), 0755)
+		objects := object.NewFileStore(filepath.Join(testDir, ".gitgo", "objects"), objectformat.SHA1)
 		defer os.RemoveAll(testDir)
 
-		treeHash, err := tree.Write(filepath.Join(testDir, ".gitgo", "objects"))
+		treeHash, err := tree.Write(objects)
 		if err != nil {
 			t.Fatalf("Failed to write tree: %v", err)
 		}
 
-		readTree, err := Read(filepath.Join(testDir, ".gitgo", "objects"), treeHash)
+		readTree, err := Read(objects, treeHash)
 		if err != nil {
 			t.Fatalf("Failed to read tree: %v", err)
 		}
@@ -165,14 +173,15 @@ func TestTreeEdgeCases(t *testing.T) {
 		cwd, _ := os.Getwd()
 		testDir := filepath.Join(cwd, "testdata")
 		os.MkdirAll(filepath.Join(testDir, ".gitgo", "objects"), 0755)
+		objects := object.NewFileStore(filepath.Join(testDir, ".gitgo", "objects"), objectformat.SHA1)
 		defer os.RemoveAll(testDir)
 
-		hash, err := tree.Write(filepath.Join(testDir, ".gitgo", "objects"))
+		hash, err := tree.Write(objects)
 		if err != nil {
 			t.Fatalf("Failed to write empty tree: %v", err)
 		}
 
-		readTree, err := Read(filepath.Join(testDir, ".gitgo", "objects"), hash)
+		readTree, err := Read(objects, hash)
 		if err != nil {
 			t.Fatalf("Failed to read empty tree: %v", err)
 		}
@@ -194,4 +203,48 @@ func TestTreeEdgeCases(t *testing.T) {
 			t.Error("Expected error for invalid UTF-8 filename")
 		}
 	})
+
+	t.Run("2.4: Parse large and unsorted trees", func(t *testing.T) {
+		hash := "1234567890123456789012345678901234567890"
+		raw, _ := hex.DecodeString(hash)
+		entry := func(mode, name string) []byte {
+			return append([]byte(mode+" "+name+"\x00"), raw...)
+		}
+
+		var content bytes.Buffer
+		for i := 0; i < 20000; i++ {
+			content.Write(entry("100644", fmt.Sprintf("file%05d", i)))
+		}
+		tree, err := Parse(content.Bytes(), objectformat.SHA1)
+		if err != nil {
+			t.Fatalf("Failed to parse tree: %v", err)
+		}
+		if entries := tree.Entries(); len(entries) != 20000 || entries[19999].Name != "file19999" {
+			t.Errorf("Parsed %d entries", len(entries))
+		}
+
+		unsorted := append(entry("100644", "lib.go"), entry("40000", "lib")...)
+		unsorted = append(unsorted, entry("100644", "a")...)
+		tree, err = Parse(unsorted, objectformat.SHA1)
+		if err != nil {
+			t.Fatalf("Failed to parse unsorted tree: %v", err)
+		}
+		var names []string
+		for _, e := range tree.Entries() {
+			names = append(names, e.Name)
+		}
+		if fmt.Sprint(names) != "[a lib.go lib]" {
+			t.Errorf("Entries = %v; want them in git order", names)
+		}
+
+		for _, bad := range [][]byte{
+			append(entry("100644", "same"), entry("40000", "same")...),
+			entry("100664", "mode"),
+			entry("100644", string([]byte{0xff})),
+		} {
+			if _, err := Parse(bad, objectformat.SHA1); err == nil {
+				t.Errorf("Expected error for tree %q", bad)
+			}
+		}
+	})
 }